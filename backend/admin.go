@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/envlog"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// requireAdmin guards administrative endpoints with the bearer token from
+// ADMIN_TOKEN. Without a configured token the endpoints are disabled.
+func (s *server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			writeError(w, r, http.StatusForbidden, "admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, r, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// importEnvironments replays an environment log sent as the request body into
+// the store. With ?dry_run=true nothing is written.
+func (s *server) importEnvironments(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "dry_run must be a boolean")
+			return
+		}
+	}
+
+	limit := s.bodyLimit()
+	if r.ContentLength > limit {
+		writeBodyTooLarge(w, r, limit)
+		return
+	}
+
+	// The log is streamed rather than read whole, so lines before the limit
+	// is reached are already imported when it is
+	im := &envlog.Importer{Store: s.environments, Validator: s.validator, DryRun: dryRun}
+	report, err := im.Import(r.Context(), http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeBodyTooLarge(w, r, limit)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "import failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// runImport implements the "import" subcommand: it replays one or more
+// environment logs into the configured store and prints every rejected line.
+// It returns the process exit code: 1 if any line was rejected, 2 if the
+// import could not run.
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dryRun := fs.Bool("dry-run", false, "validate and report without writing to the store")
+	flags := addConfigFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: drifter import [-dry-run] [flags] [log file ...]")
+		fmt.Fprintln(stderr, "Rotated segments ending in .gz are decompressed.")
+		fmt.Fprintln(stderr, "Replays environment logs (default server.log_file) into the configured store.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, _, err := flags.resolve()
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid configuration: %v\n", err)
+		return 2
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{cfg.Server.LogFile}
+	}
+
+	tiles, err := loadTileTypes(cfg.Server)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to load tile types: %v\n", err)
+		return 2
+	}
+	models, err := loadModels(cfg.Server)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to load model manifest: %v\n", err)
+		return 2
+	}
+	environments, err := openEnvironmentStore(cfg.Server)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to open environment store: %v\n", err)
+		return 2
+	}
+	defer environments.Close()
+
+	im := &envlog.Importer{Store: environments, Validator: world.Validator{Tiles: tiles, Models: models}, DryRun: *dryRun}
+	status := 0
+	for _, path := range paths {
+		f, err := envlog.OpenSegment(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		report, err := im.Import(context.Background(), f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return 2
+		}
+
+		for _, inv := range report.Invalid {
+			fmt.Fprintf(stdout, "%s:%d: %s\n", path, inv.Line, inv.Error)
+			for _, v := range inv.Violations {
+				if v.Path == "" {
+					fmt.Fprintf(stdout, "\t%s\n", v.Message)
+				} else {
+					fmt.Fprintf(stdout, "\t%s: %s\n", v.Path, v.Message)
+				}
+			}
+			status = 1
+		}
+		fmt.Fprintf(stdout, "%s: %d imported, %d duplicates, %d invalid\n",
+			path, report.Imported, report.Duplicates, len(report.Invalid))
+	}
+	return status
+}
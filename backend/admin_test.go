@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/envlog"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+const importLog = `{"timestamp":"2025-01-02T03:04:05Z","environment":{"metadata":{"name":"a"},"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[]}}
+{"timestamp":"2025-01-02T03:04:06Z","environment":{"map":{"width":2,"height":2}}}
+`
+
+func TestImportEndpoint(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodPost, "/admin/import", importLog)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "admin endpoints are off without a token")
+
+	s.adminToken = "secret"
+	rr = doRequest(t, h, http.MethodPost, "/admin/import", importLog)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = doRequestWithHeaders(t, h, http.MethodPost, "/admin/import", importLog, map[string]string{"Authorization": "Bearer wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	auth := map[string]string{"Authorization": "Bearer secret"}
+	rr = doRequestWithHeaders(t, h, http.MethodPost, "/admin/import?dry_run=true", importLog, auth)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	records, err := s.environments.List(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	for _, want := range []envlog.Report{
+		{Imported: 1, Duplicates: 0},
+		{Imported: 0, Duplicates: 1},
+	} {
+		rr = doRequestWithHeaders(t, h, http.MethodPost, "/admin/import", importLog, auth)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var report envlog.Report
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+		assert.Equal(t, want.Imported, report.Imported)
+		assert.Equal(t, want.Duplicates, report.Duplicates)
+		require.Len(t, report.Invalid, 1)
+		assert.Equal(t, 2, report.Invalid[0].Line)
+	}
+
+	rr = doRequestWithHeaders(t, h, http.MethodPost, "/admin/import?dry_run=maybe", importLog, auth)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	s.maxBodyBytes = 64
+	rr = doRequestWithHeaders(t, h, http.MethodPost, "/admin/import?dry_run=true", importLog, auth)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "the import body is limited like any other")
+}
+
+func TestRunImport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ENV_STORE", store.BackendFile)
+	t.Setenv("ENV_STORE_PATH", filepath.Join(dir, "store"))
+	logPath := filepath.Join(dir, "environments.log")
+	require.NoError(t, os.WriteFile(logPath, []byte(importLog), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 1, runImport([]string{logPath}, &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), logPath+":2: environment failed validation")
+	assert.Contains(t, stdout.String(), "1 imported, 0 duplicates, 1 invalid")
+
+	st, err := store.NewFile(filepath.Join(dir, "store"))
+	require.NoError(t, err)
+	records, err := st.List(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	stdout.Reset()
+	t.Setenv("ENV_LOG_FILE", logPath)
+	assert.Equal(t, 1, runImport(nil, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "0 imported, 1 duplicates, 1 invalid")
+
+	assert.Equal(t, 2, runImport([]string{filepath.Join(dir, "missing.log")}, &stdout, &stderr))
+	assert.Equal(t, 2, runImport([]string{"-bogus"}, &stdout, &stderr))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/assets"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// assetCacheControl lets clients keep assets indefinitely. Uploads never
+// replace an existing file, so the content behind a URL does not change.
+const assetCacheControl = "public, max-age=31536000, immutable"
+
+// assetTypes covers model formats missing from the system MIME tables
+var assetTypes = map[string]string{
+	".glb":  "model/gltf-binary",
+	".gltf": "model/gltf+json",
+}
+
+// serveAsset serves a file from the asset directory. Range requests and
+// If-None-Match / If-Range are answered by http.ServeContent against the
+// file's strong content ETag.
+func (s *server) serveAsset(w http.ResponseWriter, r *http.Request) {
+	if s.assets == nil {
+		writeError(w, r, http.StatusNotFound, "no asset directory is configured")
+		return
+	}
+	name := mux.Vars(r)["path"]
+	f, err := s.assets.Open(name)
+	if errors.Is(err, assets.ErrInvalidName) {
+		writeError(w, r, http.StatusBadRequest, "invalid asset path")
+		return
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, r, http.StatusNotFound, "asset not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "failed to open asset", err)
+		return
+	}
+	defer f.Close()
+
+	contentType := assetTypes[path.Ext(name)]
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", f.ETag)
+	w.Header().Set("Cache-Control", assetCacheControl)
+	http.ServeContent(w, r, name, f.Info.ModTime(), f)
+}
+
+// uploadModel stores a new model file and registers it. The body is
+// multipart/form-data: a "model" part with the JSON definition, as listed by
+// GET /models, followed by a "file" part with the asset itself.
+func (s *server) uploadModel(w http.ResponseWriter, r *http.Request) {
+	registry := s.validator.Models
+	if s.assets == nil || registry == nil {
+		writeError(w, r, http.StatusConflict, "model uploads need an asset directory and a model manifest")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be multipart/form-data")
+		return
+	}
+
+	part, err := mr.NextPart()
+	if err != nil || part.FormName() != "model" {
+		writeError(w, r, http.StatusBadRequest, `the first part must be "model"`)
+		return
+	}
+	var model world.Model
+	dec := json.NewDecoder(part)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&model); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid model: "+err.Error())
+		return
+	}
+	if err := model.Check(); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if model.Asset == "" {
+		model.Asset = model.Name
+	}
+	if assets.CheckName(model.Asset) != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, fmt.Sprintf("asset %q is not a valid relative path", model.Asset))
+		return
+	}
+
+	// Uploads are serialised so the manifest is never rewritten concurrently
+	s.uploadMu.Lock()
+	defer s.uploadMu.Unlock()
+
+	if _, exists := registry.Lookup(model.Name); exists {
+		writeError(w, r, http.StatusConflict, fmt.Sprintf("model %q already exists", model.Name))
+		return
+	}
+
+	part, err = mr.NextPart()
+	if err != nil || part.FormName() != "file" {
+		writeError(w, r, http.StatusBadRequest, `the "model" part must be followed by a "file" part`)
+		return
+	}
+	n, err := s.assets.Create(model.Asset, part)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeBodyTooLarge(w, r, s.maxBodyBytes)
+		case errors.Is(err, assets.ErrExists):
+			writeError(w, r, http.StatusConflict, fmt.Sprintf("asset %q already exists", model.Asset))
+		default:
+			writeInternalError(w, r, "failed to store asset", err)
+		}
+		return
+	}
+	if n == 0 {
+		s.assets.Remove(model.Asset)
+		writeError(w, r, http.StatusBadRequest, "empty model file")
+		return
+	}
+
+	if err := appendUploadedModel(s.assets.ManifestPath(), model); err != nil {
+		s.assets.Remove(model.Asset)
+		writeInternalError(w, r, "failed to record model", err)
+		return
+	}
+	if err := registry.Register(model); err != nil {
+		writeInternalError(w, r, "failed to register model", err)
+		return
+	}
+
+	registered, _ := registry.Lookup(model.Name)
+	w.Header().Set("Location", "/assets/"+registered.Asset)
+	writeJSON(w, http.StatusCreated, registered)
+}
+
+// appendUploadedModel adds m to the manifest of uploaded models at path. The
+// manifest is replaced atomically so a crash never leaves it half written.
+func appendUploadedModel(manifest string, m world.Model) error {
+	var models []world.Model
+	if _, err := os.Stat(manifest); err == nil {
+		uploaded, err := world.LoadModelRegistry(manifest)
+		if err != nil {
+			return err
+		}
+		models = uploaded.Models()
+	}
+	data, err := world.MarshalModelManifest(append(models, m))
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(manifest), filepath.Base(manifest)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), manifest)
+}
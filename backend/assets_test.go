@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/assets"
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// newAssetServer returns a test server with an empty asset directory, an
+// empty model registry and admin token "secret"
+func newAssetServer(t *testing.T) *server {
+	t.Helper()
+	store, err := assets.Open(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	models, err := world.NewModelRegistry(nil)
+	require.NoError(t, err)
+
+	s := newTestServer()
+	s.assets = store
+	s.validator.Models = models
+	s.adminToken = "secret"
+	return s
+}
+
+// uploadRequest builds a POST /models request with the given parts in order
+func uploadRequest(t *testing.T, parts ...[2]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		fw, err := mw.CreateFormField(p[0])
+		require.NoError(t, err)
+		fw.Write([]byte(p[1]))
+	}
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/models", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer secret")
+	return req
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUploadAndServeModel(t *testing.T) {
+	s := newAssetServer(t)
+	h := createHandler(s)
+
+	rr := serve(h, uploadRequest(t,
+		[2]string{"model", `{"name":"crate.glb","asset":"props/crate.glb","mass":20,"collision":{"shape":"box"}}`},
+		[2]string{"file", "glTF-binary-content"},
+	))
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, "/assets/props/crate.glb", rr.Header().Get("Location"))
+
+	model, ok := s.validator.Models.Lookup("crate.glb")
+	require.True(t, ok, "the upload is registered")
+	assert.Equal(t, 20.0, model.Mass)
+
+	// The upload survives a restart through the manifest in the asset directory
+	uploaded, err := world.LoadModelRegistry(filepath.Join(s.assets.Dir(), assets.ManifestName))
+	require.NoError(t, err)
+	assert.Equal(t, []world.Model{model}, uploaded.Models())
+
+	rr = doRequest(t, h, http.MethodGet, "/assets/props/crate.glb", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "glTF-binary-content", rr.Body.String())
+	assert.Equal(t, "model/gltf-binary", rr.Header().Get("Content-Type"))
+	assert.Equal(t, assetCacheControl, rr.Header().Get("Cache-Control"))
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.NotContains(t, etag, "W/", "asset ETags are strong")
+
+	rr = doRequestWithHeaders(t, h, http.MethodGet, "/assets/props/crate.glb", "", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = doRequestWithHeaders(t, h, http.MethodGet, "/assets/props/crate.glb", "", map[string]string{"Range": "bytes=0-3"})
+	assert.Equal(t, http.StatusPartialContent, rr.Code)
+	assert.Equal(t, "glTF", rr.Body.String())
+	assert.Equal(t, "bytes 0-3/19", rr.Header().Get("Content-Range"))
+
+	rr = doRequestWithHeaders(t, h, http.MethodGet, "/assets/props/crate.glb", "",
+		map[string]string{"Range": "bytes=0-3", "If-Range": `"stale"`})
+	assert.Equal(t, http.StatusOK, rr.Code, "a stale If-Range gets the whole file")
+
+	rr = doRequest(t, h, http.MethodGet, "/models", "")
+	assert.Contains(t, rr.Body.String(), `"name":"crate.glb"`)
+}
+
+func TestUploadModelRejects(t *testing.T) {
+	s := newAssetServer(t)
+	h := createHandler(s)
+	file := [2]string{"file", "data"}
+
+	rr := serve(h, uploadRequest(t, [2]string{"model", `{"name":"a.glb"}`}, file))
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	tests := []struct {
+		name   string
+		parts  [][2]string
+		status int
+	}{
+		{"duplicate model", [][2]string{{"model", `{"name":"a.glb","asset":"b.glb"}`}, file}, http.StatusConflict},
+		{"duplicate asset", [][2]string{{"model", `{"name":"b.glb","asset":"a.glb"}`}, file}, http.StatusConflict},
+		{"file first", [][2]string{file, {"model", `{"name":"c.glb"}`}}, http.StatusBadRequest},
+		{"missing file", [][2]string{{"model", `{"name":"c.glb"}`}}, http.StatusBadRequest},
+		{"empty file", [][2]string{{"model", `{"name":"c.glb"}`}, {"file", ""}}, http.StatusBadRequest},
+		{"unknown field", [][2]string{{"model", `{"name":"c.glb","weight":1}`}, file}, http.StatusBadRequest},
+		{"invalid model", [][2]string{{"model", `{"name":"c.glb","mass":-1}`}, file}, http.StatusUnprocessableEntity},
+		{"traversal", [][2]string{{"model", `{"name":"c.glb","asset":"../c.glb"}`}, file}, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(h, uploadRequest(t, tt.parts...))
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+		})
+	}
+
+	_, ok := s.validator.Models.Lookup("c.glb")
+	assert.False(t, ok)
+	_, err := os.Stat(filepath.Join(s.assets.Dir(), "c.glb"))
+	assert.True(t, os.IsNotExist(err), "rejected uploads leave no file behind")
+
+	req := uploadRequest(t, [2]string{"model", `{"name":"d.glb"}`}, file)
+	req.Header.Del("Authorization")
+	assert.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+}
+
+func TestServeAssetRejects(t *testing.T) {
+	s := newAssetServer(t)
+	h := createHandler(s)
+	require.NoError(t, os.WriteFile(filepath.Join(s.assets.Dir(), assets.ManifestName), []byte("models: {}"), 0o644))
+
+	for path, status := range map[string]int{
+		"/assets/missing.glb":            http.StatusNotFound,
+		"/assets/" + assets.ManifestName: http.StatusBadRequest,
+		"/assets/a%5c..%5csecret":        http.StatusBadRequest,
+	} {
+		rr := doRequest(t, h, http.MethodGet, path, "")
+		assert.Equal(t, status, rr.Code, path)
+	}
+
+	rr := doRequest(t, h, http.MethodGet, "/assets/../main.go", "")
+	assert.NotEqual(t, http.StatusOK, rr.Code, "dot segments never reach the file system")
+}
+
+func TestLoadModelsIncludesUploads(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Server{AssetsDir: dir}
+	manifest := filepath.Join(dir, assets.ManifestName)
+	require.NoError(t, appendUploadedModel(manifest, world.Model{Name: "crate.glb"}))
+
+	models, err := loadModels(cfg)
+	require.NoError(t, err)
+	_, ok := models.Lookup("crate.glb")
+	assert.True(t, ok)
+	_, ok = models.Lookup("rock_large.glb")
+	assert.True(t, ok, "shipped models are still loaded")
+
+	require.NoError(t, appendUploadedModel(manifest, world.Model{Name: "rock_large.glb"}))
+	_, err = loadModels(cfg)
+	assert.ErrorContains(t, err, `model "rock_large.glb" is defined twice`)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+// etag returns the entity tag of a stored environment document: a quoted
+// SHA-256 of its JSON form. It tags the JSON representation and is the tag
+// write responses carry.
+func etag(data []byte) string {
+	return representationETag(data, mediaTypeJSON)
+}
+
+// representationETag returns the entity tag of a document rendered as
+// mediaType. The YAML representation is a different sequence of bytes, so
+// it carries its own strong tag: the JSON hash with a -yaml suffix.
+func representationETag(data []byte, mediaType string) string {
+	sum := sha256.Sum256(data)
+	tag := hex.EncodeToString(sum[:])
+	if mediaType == mediaTypeYAML {
+		tag += "-yaml"
+	}
+	return `"` + tag + `"`
+}
+
+// parseETags splits an If-Match or If-None-Match header into its entity tags.
+// Weak tags keep their W/ prefix.
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// notModified reports whether the request's If-None-Match header matches
+// current, using the weak comparison RFC 9110 prescribes for GET.
+func notModified(r *http.Request, current string) bool {
+	for _, tag := range parseETags(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// requireIfMatch turns the request's If-Match header into a store
+// precondition so the comparison happens atomically with the write. Writes
+// without If-Match are refused with 428 to stop clients from silently
+// overwriting each other's changes. On failure it writes the error response
+// and returns false.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (store.Precondition, bool) {
+	tags := parseETags(r.Header.Get("If-Match"))
+	if len(tags) == 0 {
+		writeError(w, r, http.StatusPreconditionRequired, "If-Match header is required; send the ETag from the last read")
+		return nil, false
+	}
+
+	for _, tag := range tags {
+		if tag == "*" {
+			// Any current representation matches; the store still reports
+			// ErrNotFound if there is none.
+			return nil, true
+		}
+	}
+	return func(current store.Record) bool {
+		// If-Match uses strong comparison, so weak tags never match. The tag
+		// of either representation stands for the current document.
+		for _, tag := range tags {
+			if tag == etag(current.Data) || tag == representationETag(current.Data, mediaTypeYAML) {
+				return true
+			}
+		}
+		return false
+	}, true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentConditionalRequests(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+	rec, err := s.environments.Create(t.Context(), json.RawMessage(namedEnvironment("alpha")))
+	require.NoError(t, err)
+	path := "/environments/" + rec.ID
+	current := etag(rec.Data)
+
+	t.Run("get returns etag", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, current, rr.Header().Get("ETag"))
+
+		// YAML is a different representation, so its strong tag differs
+		rr = doRequestWithHeaders(t, h, http.MethodGet, path, "", map[string]string{"Accept": mediaTypeYAML})
+		yamlTag := rr.Header().Get("ETag")
+		assert.Equal(t, representationETag(rec.Data, mediaTypeYAML), yamlTag)
+		assert.NotEqual(t, current, yamlTag)
+		rr = doRequestWithHeaders(t, h, http.MethodGet, path, "", map[string]string{"Accept": mediaTypeYAML, "If-None-Match": yamlTag})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		rr = doRequestWithHeaders(t, h, http.MethodGet, path, "", map[string]string{"Accept": mediaTypeYAML, "If-None-Match": current})
+		assert.Equal(t, http.StatusOK, rr.Code, "the JSON tag does not validate the YAML representation")
+	})
+
+	t.Run("if-none-match", func(t *testing.T) {
+		for _, header := range []string{current, "W/" + current, `"other", ` + current, "*"} {
+			rr := doRequestWithHeaders(t, h, http.MethodGet, path, "", map[string]string{"If-None-Match": header})
+			assert.Equal(t, http.StatusNotModified, rr.Code, header)
+			assert.Empty(t, rr.Body.String())
+			assert.Equal(t, current, rr.Header().Get("ETag"))
+		}
+
+		rr := doRequestWithHeaders(t, h, http.MethodGet, path, "", map[string]string{"If-None-Match": `"other"`})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("missing if-match", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPut, path, namedEnvironment("beta"))
+		assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+		rr = doRequest(t, h, http.MethodDelete, path, "")
+		assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+	})
+
+	t.Run("stale if-match", func(t *testing.T) {
+		for _, header := range []string{`"stale"`, "W/" + current} {
+			rr := doRequestWithHeaders(t, h, http.MethodPut, path, namedEnvironment("beta"), map[string]string{"If-Match": header})
+			assert.Equal(t, http.StatusPreconditionFailed, rr.Code, header)
+			rr = doRequestWithHeaders(t, h, http.MethodDelete, path, "", map[string]string{"If-Match": header})
+			assert.Equal(t, http.StatusPreconditionFailed, rr.Code, header)
+		}
+
+		got, err := s.environments.Get(t.Context(), rec.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Revision, "rejected writes should not create revisions")
+	})
+
+	t.Run("lost update", func(t *testing.T) {
+		first := doRequestWithHeaders(t, h, http.MethodPut, path, namedEnvironment("beta"), map[string]string{"If-Match": current})
+		require.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, etag([]byte(namedEnvironment("beta"))), first.Header().Get("ETag"))
+
+		// A second editor still holding the original tag is refused
+		second := doRequestWithHeaders(t, h, http.MethodPut, path, namedEnvironment("gamma"), map[string]string{"If-Match": current})
+		assert.Equal(t, http.StatusPreconditionFailed, second.Code)
+
+		rr := doRequest(t, h, http.MethodGet, path, "")
+		assert.JSONEq(t, namedEnvironment("beta"), rr.Body.String())
+	})
+
+	t.Run("if-match with the yaml tag", func(t *testing.T) {
+		rr := doRequestWithHeaders(t, h, http.MethodGet, path, "", map[string]string{"Accept": mediaTypeYAML})
+		rr = doRequestWithHeaders(t, h, http.MethodPut, path, namedEnvironment("delta"), map[string]string{"If-Match": rr.Header().Get("ETag")})
+		assert.Equal(t, http.StatusOK, rr.Code, "either representation's tag names the current document")
+	})
+}
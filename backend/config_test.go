@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+)
+
+// resolveArgs resolves the configuration as main does for the given
+// command line
+func resolveArgs(t *testing.T, args ...string) (config.Config, config.Sources, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("drifter", flag.ContinueOnError)
+	flags := addConfigFlags(fs)
+	require.NoError(t, fs.Parse(args))
+	return flags.resolve()
+}
+
+func TestResolveConfig(t *testing.T) {
+	cfg, sources, err := resolveArgs(t)
+	require.NoError(t, err)
+	assert.Equal(t, "default_simulation", cfg.Simulation.Name, "the shipped config is used")
+	assert.Equal(t, config.Source{Layer: config.LayerFile, Name: defaultConfigFile}, sources["simulation.time_step"])
+	assert.Equal(t, config.Source{Layer: config.LayerDefault}, sources["server.port"])
+
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("simulation: {time_step: 0.5}\nserver: {port: 9000, log_max_age: 1h}\n"), 0o644))
+	t.Setenv("SIMULATION_CONFIG_FILE", path)
+	t.Setenv("PORT", "9001")
+	t.Setenv("DRIFTER_PHYSICS_WIND_GUSTINESS", "0.7")
+	cfg, sources, err = resolveArgs(t, "--server.port=9002", "--logging.console_output=false", "-debug.show_sensor_data")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, cfg.Simulation.TimeStep)
+	assert.Equal(t, time.Hour, cfg.Server.LogMaxAge)
+	assert.Equal(t, 0.7, cfg.Physics.Wind.Gustiness)
+	assert.Equal(t, 9002, cfg.Server.Port, "flags win over the environment")
+	assert.False(t, cfg.Logging.ConsoleOutput)
+	assert.True(t, cfg.Debug.ShowSensorData, "boolean flags need no value")
+	assert.Equal(t, config.Source{Layer: config.LayerFile, Name: path}, sources["simulation.time_step"])
+	assert.Equal(t, config.Source{Layer: config.LayerEnv, Name: "DRIFTER_PHYSICS_WIND_GUSTINESS"}, sources["physics.wind.gustiness"])
+	assert.Equal(t, config.Source{Layer: config.LayerFlag, Name: "--server.port"}, sources["server.port"])
+
+	cfg, sources, err = resolveArgs(t)
+	require.NoError(t, err)
+	assert.Equal(t, 9001, cfg.Server.Port, "older variables are still read")
+	assert.Equal(t, config.Source{Layer: config.LayerEnv, Name: "PORT"}, sources["server.port"])
+	t.Setenv("DRIFTER_SERVER_PORT", "9003")
+	cfg, _, err = resolveArgs(t)
+	require.NoError(t, err)
+	assert.Equal(t, 9003, cfg.Server.Port, "DRIFTER_ variables win over older ones")
+
+	other := filepath.Join(t.TempDir(), "other.yaml")
+	require.NoError(t, os.WriteFile(other, []byte("simulation: {time_step: 2}\n"), 0o644))
+	cfg, _, err = resolveArgs(t, "-config", other)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, cfg.Simulation.TimeStep, "-config wins over the environment")
+}
+
+func TestResolveConfigReportsEveryLayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("simulation:\n  time_step: -1\n"), 0o644))
+	t.Setenv(config.FileEnv, path)
+	t.Setenv("ENV_LOG_MAX_AGE", "daily")
+	t.Setenv("DRIFTER_SERVER_PROT", "80")
+
+	_, _, err := resolveArgs(t, "--server.max_body_mb=0")
+	assert.EqualError(t, err, path+": line 2: simulation.time_step: must be positive, got -1; "+
+		`ENV_LOG_MAX_AGE: server.log_max_age: invalid duration "daily"; `+
+		"DRIFTER_SERVER_PROT: unknown setting; "+
+		"--server.max_body_mb: server.max_body_mb: must be at least 1, got 0")
+}
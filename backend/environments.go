@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/envlog"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// savedResponse is returned after an environment has been created or updated
+type savedResponse struct {
+	Status   string            `json:"status"`
+	ID       string            `json:"id"`
+	Revision int               `json:"revision"`
+	Warnings []world.Violation `json:"warnings,omitempty"`
+}
+
+func (s *server) saveEnvironment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, _, warnings, ok := s.readValidEnvironment(w, r)
+	if !ok {
+		return
+	}
+
+	// Open the log first so a submission that cannot be recorded is not
+	// stored either
+	if err := s.envLog.Open(); err != nil {
+		writeInternalError(w, r, "failed to open log", err)
+		return
+	}
+	rec, err := s.environments.Create(r.Context(), body)
+	if err != nil {
+		writeInternalError(w, r, "failed to store environment", err)
+		return
+	}
+	// Only stored environments are logged, since importing the log stores
+	// every entry again. One that cannot be logged is taken back out.
+	entry := envlog.Entry{Timestamp: time.Now().UTC().Truncate(time.Second), Environment: body}
+	if err := s.envLog.WriteEntry(entry); err != nil {
+		if derr := s.environments.Delete(r.Context(), rec.ID, nil); derr != nil {
+			err = fmt.Errorf("%w; removing environment %s: %v", err, rec.ID, derr)
+		}
+		writeInternalError(w, r, "failed to write log", err)
+		return
+	}
+
+	w.Header().Set("Location", "/environments/"+rec.ID)
+	w.Header().Set("ETag", etag(rec.Data))
+	writeJSON(w, http.StatusCreated, savedResponse{Status: "saved", ID: rec.ID, Revision: rec.Revision, Warnings: warnings})
+}
+
+// listEnvironments returns one page of environment summaries, filtered and
+// sorted by the query string. The created and updated sorts order by when an
+// environment was first and last stored, not by its metadata.created, which
+// clients may set to anything.
+func (s *server) listEnvironments(w http.ResponseWriter, r *http.Request) {
+	lq, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stored, err := s.environments.Summaries(r.Context())
+	if err != nil {
+		writeInternalError(w, r, "failed to list environments", err)
+		return
+	}
+
+	summaries, next := lq.page(stored)
+	resp := map[string]interface{}{"environments": summaries}
+	if next != "" {
+		resp["next_cursor"] = next
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) getEnvironment(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.environments.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeEnvironment(w, r, rec.Data, rec.UpdatedAt)
+}
+
+// writeEnvironment writes an environment document in the representation
+// negotiated from the Accept header, or 304 if the client's If-None-Match
+// already names the current ETag
+func writeEnvironment(w http.ResponseWriter, r *http.Request, data json.RawMessage, modified time.Time) {
+	mediaType := negotiateMediaType(r)
+	tag := representationETag(data, mediaType)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	if notModified(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := []byte(data)
+	if mediaType == mediaTypeYAML {
+		var err error
+		if body, err = world.JSONToYAML(data); err != nil {
+			writeInternalError(w, r, "failed to encode environment as YAML", err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (s *server) updateEnvironment(w http.ResponseWriter, r *http.Request) {
+	pre, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	body, _, warnings, ok := s.readValidEnvironment(w, r)
+	if !ok {
+		return
+	}
+
+	rec, err := s.environments.Update(r.Context(), mux.Vars(r)["id"], body, pre)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(rec.Data))
+	writeJSON(w, http.StatusOK, savedResponse{Status: "updated", ID: rec.ID, Revision: rec.Revision, Warnings: warnings})
+}
+
+// validateEnvironment is a dry run of saveEnvironment: it reports every
+// violation without storing anything
+func (s *server) validateEnvironment(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.readEnvironmentBody(w, r)
+	if !ok {
+		return
+	}
+
+	_, violations := s.validator.Validate(body)
+	if msg, ok := syntaxError(violations); ok {
+		writeError(w, r, http.StatusBadRequest, "invalid JSON: "+msg)
+		return
+	}
+	if violations == nil {
+		violations = []world.Violation{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":      !world.HasErrors(violations),
+		"violations": violations,
+	})
+}
+
+func (s *server) deleteEnvironment(w http.ResponseWriter, r *http.Request) {
+	pre, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	if err := s.environments.Delete(r.Context(), mux.Vars(r)["id"], pre); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readValidEnvironment reads an environment definition from the request body
+// and runs schema and semantic validation on it. Any violations that remain
+// are warnings. On failure it writes the error response and returns false.
+func (s *server) readValidEnvironment(w http.ResponseWriter, r *http.Request) (json.RawMessage, *world.EnvironmentSchemaJson, []world.Violation, bool) {
+	body, ok := s.readEnvironmentBody(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	env, violations, ok := s.checkEnvironment(w, r, body)
+	return body, env, violations, ok
+}
+
+// checkEnvironment runs schema and semantic validation on body. Any
+// violations it returns are warnings. On failure it writes the error response
+// and returns false.
+func (s *server) checkEnvironment(w http.ResponseWriter, r *http.Request, body json.RawMessage) (*world.EnvironmentSchemaJson, []world.Violation, bool) {
+	env, violations := s.validator.Validate(body)
+	if msg, ok := syntaxError(violations); ok {
+		writeError(w, r, http.StatusBadRequest, "invalid JSON: "+msg)
+		return nil, nil, false
+	}
+	if world.HasErrors(violations) {
+		writeValidationError(w, r, violations)
+		return nil, nil, false
+	}
+	return env, violations, true
+}
+
+// readEnvironmentBody reads a JSON or YAML object from the request body and
+// returns it as JSON. The body is only checked to start like an object; full
+// syntax checking happens in the streaming validator so the document is never
+// decoded into a generic tree. On failure it writes the error response and
+// returns false.
+func (s *server) readEnvironmentBody(w http.ResponseWriter, r *http.Request) (json.RawMessage, bool) {
+	// Check Content-Type header
+	mediaType := requestMediaType(r)
+	if mediaType == "" {
+		writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json or application/yaml")
+		return nil, false
+	}
+
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	// Normalise YAML into JSON so everything downstream sees one format
+	if mediaType == mediaTypeYAML {
+		var err error
+		if body, err = world.YAMLToJSON(body); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid YAML: "+err.Error())
+			return nil, false
+		}
+	}
+
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] != '{' {
+		writeError(w, r, http.StatusBadRequest, "invalid JSON: environment must be a JSON object")
+		return nil, false
+	}
+	return body, true
+}
+
+// bodyLimit is the largest request body accepted, in bytes
+func (s *server) bodyLimit() int64 {
+	if s.maxBodyBytes <= 0 {
+		return defaultMaxBodyBytes
+	}
+	return s.maxBodyBytes
+}
+
+// readBody reads the whole request body, refusing bodies larger than
+// s.maxBodyBytes with 413. On failure it writes the error response and
+// returns false.
+func (s *server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	limit := s.bodyLimit()
+	if r.ContentLength > limit {
+		writeBodyTooLarge(w, r, limit)
+		return nil, false
+	}
+
+	// Size the buffer up front when the client says how much is coming, so a
+	// large map is not copied through a series of growing buffers
+	var buf bytes.Buffer
+	if r.ContentLength > 0 {
+		buf.Grow(int(r.ContentLength))
+	}
+	if _, err := buf.ReadFrom(http.MaxBytesReader(w, r.Body, limit)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyTooLarge(w, r, limit)
+			return nil, false
+		}
+		writeError(w, r, http.StatusBadRequest, "failed to read request body")
+		return nil, false
+	}
+	if buf.Len() == 0 {
+		writeError(w, r, http.StatusBadRequest, "empty request body")
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	p := newProblem(r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", limit))
+	p.Type = problemBodyTooLarge
+	writeProblem(w, p)
+}
+
+// syntaxError returns the message of a "syntax" violation. Such a body is
+// not JSON at all, which is a bad request rather than an invalid environment.
+func syntaxError(violations []world.Violation) (string, bool) {
+	for _, v := range violations {
+		if v.Rule == "syntax" {
+			return v.Message, true
+		}
+	}
+	return "", false
+}
+
+// writeJSON encodes v as the JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
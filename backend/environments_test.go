@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/envlog"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// validEnvironment is a minimal environment definition that passes validation
+const validEnvironment = `{"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[]}`
+
+// namedEnvironment returns a valid environment definition with the given name
+func namedEnvironment(name string) string {
+	return `{"metadata":{"name":"` + name + `"},"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[]}`
+}
+
+// newTestServer returns a server backed by an empty in-memory store that logs
+// to ENV_LOG_FILE without rotation
+func newTestServer() *server {
+	path := os.Getenv("ENV_LOG_FILE")
+	if path == "" {
+		path = config.Default().Server.LogFile
+	}
+	return newServer(store.NewMemory(), envlog.NewWriter(path, envlog.Options{}))
+}
+
+// doRequest sends a request through the full handler chain
+func doRequest(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequestWithHeaders(t, h, method, path, body, nil)
+}
+
+// doRequestWithHeaders is doRequest with extra request headers
+func doRequestWithHeaders(t *testing.T, h http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestEnvironmentCRUD(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	// Create
+	rr := doRequest(t, h, http.MethodPost, "/environments", namedEnvironment("alpha"))
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var created map[string]interface{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	id := created["id"].(string)
+	require.NotEmpty(t, id, "response should contain the new ID")
+	assert.Equal(t, "/environments/"+id, rr.Header().Get("Location"))
+
+	// Read
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, namedEnvironment("alpha"), rr.Body.String())
+	assert.NotEmpty(t, rr.Header().Get("Last-Modified"))
+	tag := rr.Header().Get("ETag")
+	require.NotEmpty(t, tag)
+
+	// List
+	rr = doRequest(t, h, http.MethodGet, "/environments", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Environments []environmentSummary `json:"environments"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Environments, 1)
+	assert.Equal(t, id, list.Environments[0].ID)
+
+	// Update
+	rr = doRequestWithHeaders(t, h, http.MethodPut, "/environments/"+id, namedEnvironment("beta"), map[string]string{"If-Match": tag})
+	require.Equal(t, http.StatusOK, rr.Code)
+	tag = rr.Header().Get("ETag")
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id, "")
+	assert.JSONEq(t, namedEnvironment("beta"), rr.Body.String())
+	assert.Equal(t, tag, rr.Header().Get("ETag"))
+
+	// Delete
+	rr = doRequestWithHeaders(t, h, http.MethodDelete, "/environments/"+id, "", map[string]string{"If-Match": tag})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestEnvironmentNotFound(t *testing.T) {
+	h := createHandler(newTestServer())
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{name: "get", method: http.MethodGet},
+		{name: "update", method: http.MethodPut, body: validEnvironment},
+		{name: "delete", method: http.MethodDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequestWithHeaders(t, h, tt.method, "/environments/missing", tt.body, map[string]string{"If-Match": "*"})
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			assert.Contains(t, rr.Body.String(), "environment not found")
+		})
+	}
+}
+
+func TestUpdateEnvironmentInvalidBody(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+	rec, err := s.environments.Create(t.Context(), json.RawMessage(validEnvironment))
+	require.NoError(t, err)
+
+	rr := doRequestWithHeaders(t, h, http.MethodPut, "/environments/"+rec.ID, `{`, map[string]string{"If-Match": etag(rec.Data)})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "invalid JSON")
+
+	var body problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body), "error body should be valid JSON")
+	assert.Equal(t, http.StatusBadRequest, body.Status)
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodPost, "/environments", validEnvironment)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	location := rr.Header().Get("Location")
+
+	s.maxBodyBytes = int64(len(validEnvironment))
+	oversized := namedEnvironment(strings.Repeat("x", 64))
+
+	t.Run("POST", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPost, "/environments", oversized)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+	t.Run("PUT", func(t *testing.T) {
+		rr := doRequestWithHeaders(t, h, http.MethodPut, location, oversized, map[string]string{"If-Match": "*"})
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+	t.Run("PATCH", func(t *testing.T) {
+		rr := doRequestWithHeaders(t, h, http.MethodPatch, location, `{"metadata":{"name":"`+strings.Repeat("x", 64)+`"}}`,
+			map[string]string{"If-Match": "*", "Content-Type": mediaTypeMergePatch})
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+	t.Run("without Content-Length", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(oversized))
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = -1
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+	t.Run("at the limit", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPost, "/environments", validEnvironment)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+}
+
+func TestSaveEnvironmentNotAnObject(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	for _, body := range []string{`[]`, `"map"`, `{"map":{}} {}`} {
+		rr := doRequest(t, h, http.MethodPost, "/environments", body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		assert.Contains(t, rr.Body.String(), "invalid JSON", body)
+	}
+}
+
+// failingStore rejects every new environment
+type failingStore struct {
+	store.EnvironmentStore
+}
+
+func (failingStore) Create(ctx context.Context, data json.RawMessage) (store.Record, error) {
+	return store.Record{}, errors.New("disk full")
+}
+
+func TestSaveEnvironmentNotStoredIsNotLogged(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "env.log")
+	s := newServer(failingStore{store.NewMemory()}, envlog.NewWriter(logPath, envlog.Options{}))
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodPost, "/environments", validEnvironment)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, s.envLog.Close())
+	data, err := os.ReadFile(logPath)
+	if !os.IsNotExist(err) {
+		require.NoError(t, err)
+	}
+	assert.Empty(t, data, "the importer must not bring back what was never stored")
+}
+
+func TestEnvironmentSchemaValidation(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodPost, "/environments",
+		`{"map":{"width":0,"height":2,"tiles":[{"x":1.5,"y":0}]},"objects":[],"agents":[]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	var resp problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, problemInvalidEnvironment, resp.Type)
+	assert.Equal(t, "environment failed validation", resp.Detail)
+	assert.ElementsMatch(t, []world.Violation{
+		{Severity: world.SeverityError, Path: "/map/width", Rule: "minimum", Message: "must be >= 1"},
+		{Severity: world.SeverityError, Path: "/map/tiles/0/type", Rule: "required", Message: "type is required"},
+		{Severity: world.SeverityError, Path: "/map/tiles/0/x", Rule: "type", Message: "must be an integer, got number"},
+	}, resp.Errors)
+
+	list, err := s.environments.List(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, list, "invalid environments must not be persisted")
+}
+
+func TestOpenEnvironmentStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "memory", backend: "memory", want: &store.Memory{}},
+		{name: "file", backend: "file", path: filepath.Join(t.TempDir(), "envs"), want: &store.File{}},
+		{name: "sqlite", backend: "sqlite", path: filepath.Join(t.TempDir(), "envs.db"), want: &store.SQLite{}},
+		{name: "unknown", backend: "etcd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := openEnvironmentStore(config.Server{Store: tt.backend, StorePath: tt.path})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer s.Close()
+			assert.IsType(t, tt.want, s)
+		})
+	}
+}
+
+func TestOpenEnvironmentStoreDefaults(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	s, err := openEnvironmentStore(config.Default().Server)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.IsType(t, &store.File{}, s, "file store should be the default")
+	assert.DirExists(t, filepath.Join("data", "environments"))
+}
+
+func TestOpenEnvironmentLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.log")
+	cfg := config.Default().Server
+	cfg.LogFile = path
+
+	w := openEnvironmentLog(cfg)
+	assert.Equal(t, path, w.Path())
+	require.NoError(t, w.Close())
+}
+
+func TestEnvironmentSemanticValidation(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	t.Run("errors reject the environment", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPost, "/environments",
+			`{"map":{"width":2,"height":2,"tiles":[{"x":5,"y":0,"type":"grass"}]},"objects":[],"agents":[]}`)
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"rule":"bounds"`)
+	})
+
+	t.Run("warnings are returned with the saved environment", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPost, "/environments",
+			`{"map":{"width":2,"height":2,"tiles":[]},"objects":[{"id":"o","model":"m","position":{"x":9,"y":9}}],"agents":[]}`)
+		require.Equal(t, http.StatusCreated, rr.Code)
+
+		var resp savedResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Len(t, resp.Warnings, 1)
+		assert.Equal(t, world.SeverityWarning, resp.Warnings[0].Severity)
+		assert.Equal(t, "/objects/0/position", resp.Warnings[0].Path)
+	})
+}
+
+func TestValidateEnvironmentEndpoint(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+		valid      bool
+		violations int
+	}{
+		{name: "valid", body: validEnvironment, statusCode: http.StatusOK, valid: true},
+		{name: "schema errors", body: `{}`, statusCode: http.StatusOK, valid: false, violations: 3},
+		{
+			name:       "semantic errors",
+			body:       `{"map":{"width":1,"height":1,"tiles":[{"x":0,"y":0,"type":"lava"}]},"objects":[],"agents":[]}`,
+			statusCode: http.StatusOK,
+			valid:      false,
+			violations: 1,
+		},
+		{name: "malformed JSON", body: `{`, statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, h, http.MethodPost, "/environments/validate", tt.body)
+			require.Equal(t, tt.statusCode, rr.Code)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Valid      bool              `json:"valid"`
+				Violations []world.Violation `json:"violations"`
+			}
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			assert.Equal(t, tt.valid, resp.Valid)
+			assert.Len(t, resp.Violations, tt.violations)
+		})
+	}
+
+	list, err := s.environments.List(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, list, "dry runs must not persist anything")
+}
+
+func TestEnvironmentYAML(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	yamlEnv := "metadata:\n  name: alpha\nmap:\n  width: 2\n  height: 2\n  tiles:\n    - {x: 0, y: 0, type: grass}\nobjects: []\nagents: []\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(yamlEnv))
+	req.Header.Set("Content-Type", "application/x-yaml")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var created map[string]interface{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	id := created["id"].(string)
+
+	// Stored as JSON
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"metadata":{"name":"alpha"},"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"grass"}]},"objects":[],"agents":[]}`,
+		rr.Body.String())
+
+	// Served as YAML on request
+	req = httptest.NewRequest(http.MethodGet, "/environments/"+id, nil)
+	req.Header.Set("Accept", "application/yaml")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/yaml", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+	assert.Contains(t, rr.Body.String(), "name: alpha")
+
+	// YAML is validated like JSON
+	req = httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader("map: {}\n"))
+	req.Header.Set("Content-Type", "application/yaml")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader("map: ["))
+	req.Header.Set("Content-Type", "application/yaml")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "invalid YAML")
+}
@@ -0,0 +1,235 @@
+// Package assets stores the model files viewers download, such as the .glb
+// files named by objects[].model, under a single directory.
+package assets
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidName is returned for names that are not a plain relative
+	// path inside the store.
+	ErrInvalidName = errors.New("invalid asset name")
+	// ErrExists is returned when creating an asset that already exists.
+	// Assets are never overwritten, which lets clients cache them forever.
+	ErrExists = errors.New("asset already exists")
+)
+
+// ManifestName is the file in the store's directory that records models
+// uploaded through the API. The leading dot keeps it from being served.
+const ManifestName = ".models.yaml"
+
+// Store serves and accepts files beneath a directory. Lookups cannot escape
+// the directory, through ".." or through symbolic links. A Store is safe for
+// concurrent use.
+type Store struct {
+	dir  string
+	root *os.Root
+
+	mu sync.Mutex // serialises Create
+	// etags caches content hashes by name; entries are checked against the
+	// file's size and modification time before use
+	etags sync.Map
+}
+
+type cachedETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// Open returns a Store for dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, root: root}, nil
+}
+
+// Dir returns the directory the store serves.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// ManifestPath returns the path of the uploaded model manifest.
+func (s *Store) ManifestPath() string {
+	return filepath.Join(s.dir, ManifestName)
+}
+
+// Close releases the directory handle.
+func (s *Store) Close() error {
+	return s.root.Close()
+}
+
+// CheckName reports whether name can identify an asset: a slash separated
+// relative path without empty, "." or ".." elements. Elements starting with
+// a dot are reserved for the store's own files.
+func CheckName(name string) error {
+	if name == "" || strings.Contains(name, "\\") || path.Clean(name) != name || !filepath.IsLocal(name) {
+		return ErrInvalidName
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") {
+			return ErrInvalidName
+		}
+	}
+	return nil
+}
+
+// File is an open asset. The caller must close it.
+type File struct {
+	*os.File
+	Info fs.FileInfo
+	// ETag is a strong validator derived from the file content.
+	ETag string
+}
+
+// Open opens the asset called name. Missing assets and directories are
+// reported as fs.ErrNotExist.
+func (s *Store) Open(name string) (*File, error) {
+	if err := CheckName(name); err != nil {
+		return nil, err
+	}
+	f, err := s.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fs.ErrNotExist
+	}
+
+	tag, err := s.etag(name, f, info)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &File{File: f, Info: info, ETag: tag}, nil
+}
+
+// etag returns the content hash of f, hashing it only when the file changed
+// since it was last seen. f is left positioned at its start.
+func (s *Store) etag(name string, f *os.File, info fs.FileInfo) (string, error) {
+	if v, ok := s.etags.Load(name); ok {
+		c := v.(cachedETag)
+		if c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+			return c.etag, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	tag := `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+	s.etags.Store(name, cachedETag{size: info.Size(), modTime: info.ModTime(), etag: tag})
+	return tag, nil
+}
+
+// Create stores the content of r as a new asset called name, creating parent
+// directories as needed. The asset only appears once it is complete. It
+// returns ErrExists if the name is taken and leaves nothing behind on error.
+func (s *Store) Create(name string, r io.Reader) (int64, error) {
+	if err := CheckName(name); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.root.Lstat(name); err == nil {
+		return 0, ErrExists
+	}
+	dir := path.Dir(name)
+	if err := s.mkdirAll(dir); err != nil {
+		return 0, err
+	}
+
+	tmp := path.Join(dir, ".upload-"+randomSuffix())
+	f, err := s.root.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer s.root.Remove(tmp)
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	if err := f.Close(); err != nil {
+		return n, err
+	}
+
+	// mkdirAll checked that dir resolves inside the root, and name has no
+	// links of its own yet, so the plain paths below stay inside it. Link
+	// rather than rename so an asset that appeared meanwhile is not replaced.
+	if err := os.Link(filepath.Join(s.dir, tmp), filepath.Join(s.dir, name)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return n, ErrExists
+		}
+		return n, err
+	}
+	return n, nil
+}
+
+// mkdirAll creates dir and its parents beneath the root and checks that it
+// resolves to a directory inside it.
+func (s *Store) mkdirAll(dir string) error {
+	if dir == "." {
+		return nil
+	}
+	elems := strings.Split(dir, "/")
+	for i := range elems {
+		err := s.root.Mkdir(strings.Join(elems[:i+1], "/"), 0o755)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	info, err := s.root.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: %w", dir, ErrInvalidName)
+	}
+	return nil
+}
+
+// Remove deletes the asset called name.
+func (s *Store) Remove(name string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	s.etags.Delete(name)
+	return s.root.Remove(name)
+}
+
+func randomSuffix() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,105 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"rock.glb", "trees/oak.glb", "a/b/c.gltf"} {
+		assert.NoError(t, CheckName(name), name)
+	}
+	for _, name := range []string{
+		"", ".", "..", "../secret", "a/../../b", "/etc/passwd", "a//b", "a/./b", "a/",
+		`a\b`, ".hidden", "dir/.upload-1", ManifestName,
+	} {
+		assert.ErrorIs(t, CheckName(name), ErrInvalidName, name)
+	}
+}
+
+func TestStoreCreateAndOpen(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	n, err := s.Create("trees/oak.glb", strings.NewReader("glTF"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	f, err := s.Open("trees/oak.glb")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "glTF", string(data))
+	sum := sha256.Sum256([]byte("glTF"))
+	assert.Equal(t, `"`+hex.EncodeToString(sum[:])+`"`, f.ETag)
+
+	_, err = s.Create("trees/oak.glb", strings.NewReader("other"))
+	assert.ErrorIs(t, err, ErrExists, "assets are never overwritten")
+
+	_, err = s.Open("trees")
+	assert.ErrorIs(t, err, fs.ErrNotExist, "directories are not assets")
+	_, err = s.Open("missing.glb")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	entries, err := os.ReadDir(filepath.Join(s.Dir(), "trees"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+}
+
+func TestStoreETagTracksContent(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.Create("a.glb", strings.NewReader("one"))
+	require.NoError(t, err)
+	_, err = s.Create("b.glb", strings.NewReader("one"))
+	require.NoError(t, err)
+
+	etag := func(name string) string {
+		f, err := s.Open(name)
+		require.NoError(t, err)
+		f.Close()
+		return f.ETag
+	}
+	first := etag("a.glb")
+	assert.Equal(t, first, etag("b.glb"), "same content, same tag")
+
+	// Replace the file behind the store's back
+	path := filepath.Join(s.Dir(), "a.glb")
+	require.NoError(t, os.WriteFile(path, []byte("two"), 0o644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.NotEqual(t, first, etag("a.glb"))
+}
+
+func TestStoreRejectsEscapes(t *testing.T) {
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.glb"), []byte("secret"), 0o644))
+
+	dir := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	s, err := Open(dir)
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.Open("link/secret.glb")
+	assert.Error(t, err, "symlinks out of the directory are not followed")
+
+	_, err = s.Create("link/new.glb", strings.NewReader("x"))
+	assert.Error(t, err)
+	_, statErr := os.Stat(filepath.Join(outside, "new.glb"))
+	assert.ErrorIs(t, statErr, fs.ErrNotExist)
+}
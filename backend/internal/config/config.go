@@ -0,0 +1,271 @@
+// Package config reads simulation_config.yaml into typed settings. Omitted
+// settings take their defaults, and every invalid setting is reported at
+// once with its line in the file.
+package config
+
+import (
+	"time"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/sim"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+// Config is the whole of simulation_config.yaml, plus the server settings
+// that are usually given through the environment or flags.
+type Config struct {
+	Simulation  sim.Config  `yaml:"simulation" json:"simulation"`
+	Physics     Physics     `yaml:"physics" json:"physics"`
+	Vessel      Vessel      `yaml:"vessel" json:"vessel"`
+	Sensors     Sensors     `yaml:"sensors" json:"sensors"`
+	Environment Environment `yaml:"environment" json:"environment"`
+	Logging     Logging     `yaml:"logging" json:"logging"`
+	Debug       Debug       `yaml:"debug" json:"debug"`
+	Network     Network     `yaml:"network" json:"network"`
+	Performance Performance `yaml:"performance" json:"performance"`
+	// Server is kept out of JSON so it never reaches API clients.
+	Server Server `yaml:"server" json:"-"`
+}
+
+// Physics holds the constants of the simulated world.
+type Physics struct {
+	Gravity      float64 `yaml:"gravity" json:"gravity"`             // m/s²
+	AirDensity   float64 `yaml:"air_density" json:"air_density"`     // kg/m³
+	WaterDensity float64 `yaml:"water_density" json:"water_density"` // kg/m³
+	Wind         Wind    `yaml:"wind" json:"wind"`
+}
+
+// Wind is the prevailing wind.
+type Wind struct {
+	Speed float64 `yaml:"speed" json:"speed"` // m/s
+	// Direction is in degrees: 0 is north, 90 east.
+	Direction float64 `yaml:"direction" json:"direction"`
+	// Gustiness is the randomness of the wind, from 0 to 1.
+	Gustiness float64 `yaml:"gustiness" json:"gustiness"`
+}
+
+// Vessel describes the simulated craft.
+type Vessel struct {
+	Mass            float64 `yaml:"mass" json:"mass"`     // kg
+	Length          float64 `yaml:"length" json:"length"` // m
+	Width           float64 `yaml:"width" json:"width"`   // m
+	Height          float64 `yaml:"height" json:"height"` // m
+	DragCoefficient float64 `yaml:"drag_coefficient" json:"drag_coefficient"`
+	MaxSpeed        float64 `yaml:"max_speed" json:"max_speed"`               // m/s
+	MaxAcceleration float64 `yaml:"max_acceleration" json:"max_acceleration"` // m/s²
+	Thrust          Thrust  `yaml:"thrust" json:"thrust"`
+}
+
+// Thrust describes the vessel's propulsion.
+type Thrust struct {
+	MaxForward   float64 `yaml:"max_forward" json:"max_forward"`     // N
+	MaxReverse   float64 `yaml:"max_reverse" json:"max_reverse"`     // N
+	ResponseTime float64 `yaml:"response_time" json:"response_time"` // s
+}
+
+// Sensors holds the sensor models.
+type Sensors struct {
+	GPS         GPS         `yaml:"gps" json:"gps"`
+	IMU         IMU         `yaml:"imu" json:"imu"`
+	DepthSensor DepthSensor `yaml:"depth_sensor" json:"depth_sensor"`
+}
+
+// GPS is a position sensor.
+type GPS struct {
+	UpdateRate    float64 `yaml:"update_rate" json:"update_rate"`       // Hz
+	PositionError float64 `yaml:"position_error" json:"position_error"` // m
+	VelocityError float64 `yaml:"velocity_error" json:"velocity_error"` // m/s
+}
+
+// IMU is an inertial sensor.
+type IMU struct {
+	UpdateRate        float64 `yaml:"update_rate" json:"update_rate"`               // Hz
+	AccelerationError float64 `yaml:"acceleration_error" json:"acceleration_error"` // m/s²
+	GyroError         float64 `yaml:"gyro_error" json:"gyro_error"`                 // rad/s
+}
+
+// DepthSensor measures depth below the surface.
+type DepthSensor struct {
+	UpdateRate float64 `yaml:"update_rate" json:"update_rate"` // Hz
+	Error      float64 `yaml:"error" json:"error"`             // m
+}
+
+// Environment holds the weather and water conditions.
+type Environment struct {
+	Current Current `yaml:"current" json:"current"`
+	Waves   Waves   `yaml:"waves" json:"waves"`
+	Weather Weather `yaml:"weather" json:"weather"`
+}
+
+// Current is the water current.
+type Current struct {
+	Speed          float64 `yaml:"speed" json:"speed"`                     // m/s
+	Direction      float64 `yaml:"direction" json:"direction"`             // degrees
+	DepthVariation float64 `yaml:"depth_variation" json:"depth_variation"` // m
+}
+
+// Waves is the surface swell.
+type Waves struct {
+	Enable    bool    `yaml:"enable" json:"enable"`
+	Height    float64 `yaml:"height" json:"height"`       // m
+	Period    float64 `yaml:"period" json:"period"`       // s
+	Direction float64 `yaml:"direction" json:"direction"` // degrees
+}
+
+// Weather is the atmospheric conditions.
+type Weather struct {
+	// Visibility is how far sensors and agents are meant to see, in
+	// meters. Nothing applies it yet; GET .../view takes its range as a
+	// query parameter.
+	Visibility    float64 `yaml:"visibility" json:"visibility"`
+	Precipitation float64 `yaml:"precipitation" json:"precipitation"` // mm/h
+	Temperature   float64 `yaml:"temperature" json:"temperature"`     // °C
+}
+
+// Logging configures the simulation log and data recording.
+type Logging struct {
+	Level           string  `yaml:"level" json:"level"`
+	FilePath        string  `yaml:"file_path" json:"file_path"`
+	ConsoleOutput   bool    `yaml:"console_output" json:"console_output"`
+	RecordData      bool    `yaml:"record_data" json:"record_data"`
+	DataDirectory   string  `yaml:"data_directory" json:"data_directory"`
+	DataFormat      string  `yaml:"data_format" json:"data_format"`
+	DataCompression bool    `yaml:"data_compression" json:"data_compression"`
+	MaxFileSizeMB   float64 `yaml:"max_file_size_mb" json:"max_file_size_mb"`
+}
+
+// Debug configures visualisation aids.
+type Debug struct {
+	EnableVisualization bool `yaml:"enable_visualization" json:"enable_visualization"`
+	VisualizationFPS    int  `yaml:"visualization_fps" json:"visualization_fps"`
+	ShowCollisionBoxes  bool `yaml:"show_collision_boxes" json:"show_collision_boxes"`
+	ShowSensorData      bool `yaml:"show_sensor_data" json:"show_sensor_data"`
+	ShowDebugInfo       bool `yaml:"show_debug_info" json:"show_debug_info"`
+}
+
+// Network configures the simulation's client listener.
+type Network struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled"`
+	Host       string `yaml:"host" json:"host"`
+	Port       int    `yaml:"port" json:"port"`
+	Protocol   string `yaml:"protocol" json:"protocol"`
+	MaxClients int    `yaml:"max_clients" json:"max_clients"`
+}
+
+// Performance holds resource limits.
+type Performance struct {
+	MaxThreads      int  `yaml:"max_threads" json:"max_threads"`
+	UseGPU          bool `yaml:"use_gpu" json:"use_gpu"`
+	CacheSize       int  `yaml:"cache_size" json:"cache_size"`
+	EnableProfiling bool `yaml:"enable_profiling" json:"enable_profiling"`
+}
+
+// Server configures the HTTP server itself.
+type Server struct {
+	Port int `yaml:"port" json:"port"`
+	// Store is the environment store backend: memory, file or sqlite.
+	Store string `yaml:"store" json:"store"`
+	// StorePath is where the store keeps its data. Empty picks a path under
+	// data/ suited to the backend.
+	StorePath string `yaml:"store_path" json:"store_path"`
+	// LogFile is the environment log every submission is appended to.
+	LogFile string `yaml:"log_file" json:"log_file"`
+	// LogMaxSizeMB rotates the log once it reaches this size; 0 disables it.
+	LogMaxSizeMB int64 `yaml:"log_max_size_mb" json:"log_max_size_mb"`
+	// LogMaxAge rotates the log once its oldest entry is this old; 0
+	// disables it.
+	LogMaxAge   time.Duration `yaml:"log_max_age" json:"log_max_age"`
+	LogCompress bool          `yaml:"log_compress" json:"log_compress"`
+	// LogRetain is the number of rotated segments kept; 0 keeps all.
+	LogRetain int `yaml:"log_retain" json:"log_retain"`
+	// MaxBodyMB caps environment, patch and upload bodies.
+	MaxBodyMB int64 `yaml:"max_body_mb" json:"max_body_mb"`
+	// TileTypesFile and ModelsFile default to the shipped files under
+	// configs/ when they exist.
+	TileTypesFile string `yaml:"tile_types_file" json:"tile_types_file"`
+	ModelsFile    string `yaml:"models_file" json:"models_file"`
+	AssetsDir     string `yaml:"assets_dir" json:"assets_dir"`
+	// ProfilesDir holds the named configuration profiles simulations can
+	// start from. It defaults to configs/profiles when that exists.
+	ProfilesDir string `yaml:"profiles_dir" json:"profiles_dir"`
+	// ReloadInterval is how often the configuration file and profiles are
+	// checked for changes; 0 disables reloading.
+	ReloadInterval time.Duration `yaml:"reload_interval" json:"reload_interval"`
+	// AdminToken is the bearer token for /admin endpoints; empty disables
+	// them. It is never printed.
+	AdminToken string `yaml:"admin_token" json:"-" secret:"true"`
+}
+
+// Default returns the settings of the shipped configs/simulation_config.yaml
+// and the server's defaults. They apply to anything left unset.
+func Default() Config {
+	return Config{
+		Simulation: sim.DefaultConfig,
+		Physics: Physics{
+			Gravity:      9.81,
+			AirDensity:   1.225,
+			WaterDensity: 1025,
+			Wind:         Wind{Speed: 5, Direction: 0, Gustiness: 0.2},
+		},
+		Vessel: Vessel{
+			Mass:            1000,
+			Length:          10,
+			Width:           3,
+			Height:          2,
+			DragCoefficient: 0.82,
+			MaxSpeed:        10,
+			MaxAcceleration: 2,
+			Thrust:          Thrust{MaxForward: 5000, MaxReverse: 2000, ResponseTime: 1},
+		},
+		Sensors: Sensors{
+			GPS:         GPS{UpdateRate: 1, PositionError: 1, VelocityError: 0.1},
+			IMU:         IMU{UpdateRate: 100, AccelerationError: 0.1, GyroError: 0.01},
+			DepthSensor: DepthSensor{UpdateRate: 10, Error: 0.05},
+		},
+		Environment: Environment{
+			Current: Current{Speed: 0.5, Direction: 45, DepthVariation: 0.2},
+			Waves:   Waves{Enable: true, Height: 0.5, Period: 5, Direction: 0},
+			Weather: Weather{Visibility: 10000, Precipitation: 0, Temperature: 20},
+		},
+		Logging: Logging{
+			Level:           "INFO",
+			FilePath:        "./logs/simulation.log",
+			ConsoleOutput:   true,
+			RecordData:      true,
+			DataDirectory:   "./data",
+			DataFormat:      "csv",
+			DataCompression: true,
+			MaxFileSizeMB:   100,
+		},
+		Debug: Debug{
+			EnableVisualization: true,
+			VisualizationFPS:    30,
+			ShowCollisionBoxes:  true,
+			ShowSensorData:      false,
+			ShowDebugInfo:       true,
+		},
+		Network: Network{
+			Enabled:    false,
+			Host:       "localhost",
+			Port:       5000,
+			Protocol:   "tcp",
+			MaxClients: 5,
+		},
+		Performance: Performance{
+			MaxThreads:      4,
+			UseGPU:          false,
+			CacheSize:       1000,
+			EnableProfiling: false,
+		},
+		Server: Server{
+			Port:           8080,
+			Store:          store.BackendFile,
+			LogFile:        "logs/environments.log",
+			LogMaxSizeMB:   100,
+			LogCompress:    true,
+			LogRetain:      10,
+			MaxBodyMB:      64,
+			AssetsDir:      "assets",
+			ReloadInterval: 2 * time.Second,
+		},
+	}
+}
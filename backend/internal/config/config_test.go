@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShippedConfigMatchesDefaults(t *testing.T) {
+	cfg, err := Load("../../configs/simulation_config.yaml")
+	require.NoError(t, err)
+	cfg.Simulation.Description, cfg.Simulation.Version = "", ""
+	assert.Equal(t, Default(), cfg)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+simulation:
+  time_step: 0.5
+physics:
+  wind:
+    gustiness: 0.9
+logging:
+  data_format: hdf5
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, cfg.Simulation.TimeStep)
+	assert.Equal(t, 0.9, cfg.Physics.Wind.Gustiness)
+	assert.Equal(t, "hdf5", cfg.Logging.DataFormat)
+
+	want := Default()
+	assert.Equal(t, want.Simulation.MaxDurationSeconds, cfg.Simulation.MaxDurationSeconds, "omitted settings keep their defaults")
+	assert.Equal(t, want.Physics.Wind.Speed, cfg.Physics.Wind.Speed)
+	assert.Equal(t, want.Vessel, cfg.Vessel)
+}
+
+func TestParseReportsEveryError(t *testing.T) {
+	_, err := Parse([]byte(`simulation:
+  time_step: 0
+physics:
+  wind:
+    gustiness: 1.5
+    blowing: true
+vessel:
+  mass: heavy
+logging:
+  data_format: xml
+network:
+  port: 70000
+`))
+	var errs Errors
+	require.True(t, errors.As(err, &errs), "got %v", err)
+	require.Len(t, errs, 6)
+
+	assert.Equal(t, FieldError{Line: 2, Path: "simulation.time_step", Message: "must be positive, got 0"}, errs[0])
+	assert.Equal(t, FieldError{Line: 5, Path: "physics.wind.gustiness", Message: "must be between 0 and 1, got 1.5"}, errs[1])
+	assert.Equal(t, FieldError{Line: 6, Path: "physics.wind.blowing", Message: "unknown setting"}, errs[2])
+	assert.Equal(t, "line 8: vessel.mass: cannot unmarshal !!str `heavy` into float64", errs[3].Error())
+	assert.Equal(t, "line 10: logging.data_format: must be one of [csv json hdf5], got \"xml\"", errs[4].Error())
+	assert.Equal(t, "line 12: network.port: must be between 1 and 65535, got 70000", errs[5].Error())
+	assert.Contains(t, err.Error(), "; line 5: physics.wind.gustiness:")
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"empty", ``, "empty config"},
+		{"not a mapping", `- simulation`, "line 1: config must be a mapping of sections"},
+		{"unknown section", `weather: {}`, "line 1: weather: unknown setting"},
+		{"negative direction", `environment: {current: {direction: -10}}`, "line 1: environment.current.direction: must be between 0 and 360, got -10"},
+		{"log level", `logging: {level: verbose}`, `line 1: logging.level: must be one of [DEBUG INFO WARNING ERROR CRITICAL], got "verbose"`},
+		{"protocol", `network: {protocol: http}`, `line 1: network.protocol: must be one of [tcp udp], got "http"`},
+		{"threads", `performance: {max_threads: 0}`, "line 1: performance.max_threads: must be at least 1, got 0"},
+		{"bad yaml", `simulation: [`, "yaml:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("sensors:\n  gps:\n    update_rate: 0\n"), 0o644))
+	_, err := Load(path)
+	assert.EqualError(t, err, path+": line 3: sensors.gps.update_rate: must be positive, got 0")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Network.Enabled = true
+	cfg.Network.Host = ""
+	cfg.Debug.VisualizationFPS = 0
+	assert.EqualError(t, cfg.Validate(), "debug.visualization_fps: must be at least 1, got 0; network.host: must be set")
+}
+
+func TestSet(t *testing.T) {
+	cfg := Default()
+	require.NoError(t, cfg.Set("physics.wind.gustiness", "0.4"))
+	require.NoError(t, cfg.Set("server.log_max_age", "24h"))
+	require.NoError(t, cfg.Set("network.enabled", "true"))
+	require.NoError(t, cfg.Set("simulation.random_seed", "7"))
+	assert.Equal(t, 0.4, cfg.Physics.Wind.Gustiness)
+	assert.Equal(t, 24*time.Hour, cfg.Server.LogMaxAge)
+	assert.True(t, cfg.Network.Enabled)
+	assert.Equal(t, int64(7), cfg.Simulation.RandomSeed)
+
+	assert.EqualError(t, cfg.Set("physics.wind", "1"), `unknown setting "physics.wind"`)
+	assert.EqualError(t, cfg.Set("network.port", "high"), `invalid integer "high"`)
+	assert.EqualError(t, cfg.Set("waves.enable", "yes"), `unknown setting "waves.enable"`)
+	assert.EqualError(t, cfg.Set("environment.waves.enable", "yes"), `invalid boolean "yes"`)
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+	assert.Equal(t, "simulation.name", keys[0])
+	assert.Contains(t, keys, "physics.wind.gustiness")
+	assert.Contains(t, keys, "sensors.depth_sensor.error")
+	assert.Equal(t, "DRIFTER_SENSORS_DEPTH_SENSOR_ERROR", EnvName("sensors.depth_sensor.error"))
+}
+
+func TestAddFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	values := AddFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-vessel.mass=2000", "--network.enabled", "rest"}))
+	assert.Equal(t, map[string]string{"vessel.mass": "2000", "network.enabled": "true"}, values)
+	assert.Equal(t, []string{"rest"}, fs.Args())
+	assert.Equal(t, "default 1000, env DRIFTER_VESSEL_MASS", fs.Lookup("vessel.mass").Usage)
+}
+
+func TestPrint(t *testing.T) {
+	cfg := Default()
+	cfg.Server.AdminToken = "hunter2"
+	sources := Sources{
+		"simulation.time_step": {Layer: LayerFile, Name: "sim.yaml"},
+		"server.port":          {Layer: LayerEnv, Name: "PORT"},
+		"server.admin_token":   {Layer: LayerFlag, Name: "--server.admin_token"},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Print(&buf, cfg, sources))
+	out := buf.String()
+	assert.Contains(t, out, "  time_step: 0.1 # file sim.yaml\n")
+	assert.Contains(t, out, "  port: 8080 # env PORT\n")
+	assert.Contains(t, out, "  log_max_age: 0s\n")
+	assert.Contains(t, out, "  admin_token: <redacted> # flag --server.admin_token\n")
+	assert.NotContains(t, out, "hunter2")
+
+	cfg.Server.AdminToken = ""
+	buf.Reset()
+	require.NoError(t, Print(&buf, cfg, nil))
+	back, err := Parse(buf.Bytes())
+	require.NoError(t, err, "printed configs read back")
+	assert.Equal(t, cfg, back)
+}
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variable of every setting.
+const EnvPrefix = "DRIFTER_"
+
+// FileEnv names the configuration file in the environment. It is read by
+// the caller choosing Layers.File rather than by Resolve.
+const FileEnv = EnvPrefix + "CONFIG"
+
+// Layer names, from lowest to highest precedence.
+const (
+	LayerDefault = "default"
+	LayerFile    = "file"
+	LayerEnv     = "env"
+	LayerFlag    = "flag"
+	// LayerOverride is a setting overridden for one simulation run.
+	LayerOverride = "override"
+)
+
+// Source is where a setting's value came from.
+type Source struct {
+	Layer string
+	// Name is the file, environment variable or flag that set the value.
+	// It is empty for defaults.
+	Name string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return s.Layer
+	}
+	return s.Layer + " " + s.Name
+}
+
+// rank orders sources by precedence, so errors read in the order the
+// layers are applied.
+func (s Source) rank() int {
+	switch s.Layer {
+	case LayerFile:
+		return 1
+	case LayerEnv:
+		return 2
+	case LayerFlag:
+		return 3
+	case LayerOverride:
+		return 4
+	}
+	return 0
+}
+
+// Sources maps every setting's key to where its value came from.
+type Sources map[string]Source
+
+// Layers are what Resolve merges over Default, in rising precedence.
+type Layers struct {
+	// File is a configuration file such as configs/simulation_config.yaml.
+	// Empty skips it.
+	File string
+	// Environ is the environment in os.Environ form. A setting is read from
+	// its EnvName, such as DRIFTER_PHYSICS_WIND_GUSTINESS. Empty values are
+	// ignored.
+	Environ []string
+	// Aliases maps older environment variable names to the keys they set.
+	// The DRIFTER_ variable wins when both are set.
+	Aliases map[string]string
+	// Flags maps keys to values given on the command line, as collected by
+	// AddFlags.
+	Flags map[string]string
+}
+
+// key is one setting of Config.
+type key struct {
+	path   string
+	index  []int
+	secret bool
+	isBool bool
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// keys lists every setting of Config in file order.
+var keys = collectKeys(reflect.TypeOf(Config{}), "", nil)
+
+func collectKeys(t reflect.Type, prefix string, index []int) []key {
+	var out []key
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		idx := append(append([]int(nil), index...), i)
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, collectKeys(f.Type, path, idx)...)
+			continue
+		}
+		out = append(out, key{
+			path:   path,
+			index:  idx,
+			secret: f.Tag.Get("secret") == "true",
+			isBool: f.Type.Kind() == reflect.Bool,
+		})
+	}
+	return out
+}
+
+func lookupKey(path string) (key, bool) {
+	for _, k := range keys {
+		if k.path == path {
+			return k, true
+		}
+	}
+	return key{}, false
+}
+
+// Keys returns the key of every setting, such as physics.wind.gustiness, in
+// file order.
+func Keys() []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = k.path
+	}
+	return out
+}
+
+// EnvName returns the environment variable that sets key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Set parses value into the setting named by key. Durations are written as
+// time.ParseDuration reads them, such as 24h.
+func (c *Config) Set(key, value string) error {
+	k, ok := lookupKey(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return setValue(reflect.ValueOf(c).Elem().FieldByIndex(k.index), value)
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// formatValue writes v as Set reads it.
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Resolve merges the layers over Default and reports where each setting
+// came from. Every problem in every layer is reported at once as Errors.
+func Resolve(l Layers) (Config, Sources, error) {
+	cfg := Default()
+	sources := make(Sources, len(keys))
+	for _, k := range keys {
+		sources[k.path] = Source{Layer: LayerDefault}
+	}
+
+	var lines map[string]int
+	var errs Errors
+	if l.File != "" {
+		data, err := os.ReadFile(l.File)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		var fileErrs Errors
+		lines, fileErrs, err = decode(data, &cfg)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("%s: %w", l.File, err)
+		}
+		src := Source{Layer: LayerFile, Name: l.File}
+		for path := range lines {
+			sources[path] = src
+		}
+		for _, fe := range fileErrs {
+			fe.Source = src
+			errs = append(errs, fe)
+		}
+	}
+
+	env := make(map[string]string)
+	for _, kv := range l.Environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && value != "" {
+			env[name] = value
+		}
+	}
+	aliases := make(map[string][]string)
+	for name, path := range l.Aliases {
+		aliases[path] = append(aliases[path], name)
+	}
+	apply := func(k key, value string, src Source) {
+		if err := setValue(reflect.ValueOf(&cfg).Elem().FieldByIndex(k.index), value); err != nil {
+			errs = append(errs, FieldError{Source: src, Path: k.path, Message: err.Error()})
+			return
+		}
+		sources[k.path] = src
+	}
+	known := map[string]bool{FileEnv: true}
+	for _, k := range keys {
+		names := aliases[k.path]
+		sort.Strings(names)
+		names = append(names, EnvName(k.path))
+		for _, name := range names {
+			known[name] = true
+			if v, ok := env[name]; ok {
+				apply(k, v, Source{Layer: LayerEnv, Name: name})
+			}
+		}
+	}
+	for _, name := range sortedKeys(env) {
+		if strings.HasPrefix(name, EnvPrefix) && !known[name] {
+			errs = append(errs, FieldError{Source: Source{Layer: LayerEnv, Name: name}, Message: "unknown setting"})
+		}
+	}
+
+	for _, path := range sortedKeys(l.Flags) {
+		src := Source{Layer: LayerFlag, Name: "--" + path}
+		k, ok := lookupKey(path)
+		if !ok {
+			errs = append(errs, FieldError{Source: src, Message: "unknown setting"})
+			continue
+		}
+		apply(k, l.Flags[path], src)
+	}
+
+	errs = append(errs, cfg.validate(lines, sources)...)
+	if len(errs) > 0 {
+		errs.sort()
+		return Config{}, nil, errs
+	}
+	return cfg, sources, nil
+}
+
+// flagValue collects one setting's flag for Layers.Flags.
+type flagValue struct {
+	key  key
+	seen map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f.seen == nil {
+		return ""
+	}
+	return f.seen[f.key.path]
+}
+
+func (f *flagValue) Set(s string) error {
+	f.seen[f.key.path] = s
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.key.isBool
+}
+
+// AddFlags defines a flag on fs for every setting, named by its key, such as
+// -physics.wind.gustiness. The returned map collects the flags given when
+// fs is parsed and is meant for Layers.Flags; values are checked by Resolve.
+func AddFlags(fs *flag.FlagSet) map[string]string {
+	seen := make(map[string]string)
+	def := reflect.ValueOf(Default())
+	for _, k := range keys {
+		usage := "env " + EnvName(k.path)
+		if v := def.FieldByIndex(k.index); !k.secret && !v.IsZero() {
+			usage = fmt.Sprintf("default %s, %s", formatValue(v), usage)
+		}
+		fs.Var(&flagValue{key: k, seen: seen}, k.path, usage)
+	}
+	return seen
+}
+
+// Print writes c as YAML that Parse reads back, with each setting commented
+// with its source when sources is given. Secrets that are set print as
+// <redacted>.
+func Print(w io.Writer, c Config, sources Sources) error {
+	var doc yaml.Node
+	if err := doc.Encode(c); err != nil {
+		return err
+	}
+	annotate(&doc, reflect.TypeOf(c), "", sources)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// annotate walks the encoded form of a value of type t.
+func annotate(n *yaml.Node, t reflect.Type, prefix string, sources Sources) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		field, ok := fieldByTag(t, k.Value)
+		if !ok {
+			continue
+		}
+		path := k.Value
+		if prefix != "" {
+			path = prefix + "." + k.Value
+		}
+		if v.Kind == yaml.MappingNode {
+			annotate(v, field.Type, path, sources)
+			continue
+		}
+		if field.Tag.Get("secret") == "true" && v.Value != "" {
+			v.Value, v.Style = "<redacted>", 0
+		}
+		if src, ok := sources[path]; ok {
+			v.LineComment = src.String()
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is one problem with a setting.
+type FieldError struct {
+	// Source is the layer that set the bad value. It is empty for Parse.
+	Source Source
+	// Line is the setting's line in the file, or 0 if the value did not
+	// come from a file.
+	Line int
+	// Path names the setting, such as physics.wind.gustiness.
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	msg := e.Message
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	if e.Source.Name != "" {
+		msg = e.Source.Name + ": " + msg
+	}
+	return msg
+}
+
+// Errors is every problem found in a configuration, in the order the layers
+// apply and then in file order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e Errors) sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		if ri, rj := e[i].Source.rank(), e[j].Source.rank(); ri != rj {
+			return ri < rj
+		}
+		if e[i].Source.Layer == LayerFile && e[i].Source.Name != e[j].Source.Name {
+			return e[i].Source.Name < e[j].Source.Name
+		}
+		return e[i].Line < e[j].Line
+	})
+}
+
+// Parse reads a configuration file such as configs/simulation_config.yaml.
+// Omitted settings keep their Default value. Unknown settings, values of
+// the wrong type and values out of range are all reported together as
+// Errors.
+func Parse(data []byte) (Config, error) {
+	cfg := Default()
+	lines, errs, err := decode(data, &cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	errs = append(errs, cfg.validate(lines, nil)...)
+	if len(errs) > 0 {
+		errs.sort()
+		return Config{}, errs
+	}
+	return cfg, nil
+}
+
+// decode reads the document in data over cfg. It returns the line of every
+// value set and the settings that could not be set; err is reserved for
+// documents that cannot be read at all.
+func decode(data []byte, cfg *Config) (lines map[string]int, errs Errors, err error) {
+	root, err := readRoot(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeRoot(root, cfg)
+}
+
+// readRoot returns the mapping at the top of the document in data.
+func readRoot(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("empty config")
+		}
+		return nil, err
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, Errors{{Line: root.Line, Message: "config must be a mapping of sections"}}
+	}
+	return root, nil
+}
+
+// decodeRoot is decode for a document already read by readRoot.
+func decodeRoot(root *yaml.Node, cfg *Config) (lines map[string]int, errs Errors, err error) {
+	lines = make(map[string]int)
+	walk(root, reflect.TypeOf(*cfg), "", lines, &errs)
+	if err := root.Decode(cfg); err != nil {
+		var te *yaml.TypeError
+		if !errors.As(err, &te) {
+			return nil, nil, err
+		}
+		for _, msg := range te.Errors {
+			errs = append(errs, typeError(msg, lines))
+		}
+	}
+	return lines, errs, nil
+}
+
+// Load reads the configuration file at path.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks every setting of c, as Parse does for a file.
+func (c Config) Validate() error {
+	if errs := c.validate(nil, nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// walk records the line of every value in n under its dotted path and
+// reports keys that t, a struct type, has no field for. Sections are not
+// recorded, as they share their first setting's line.
+func walk(n *yaml.Node, t reflect.Type, prefix string, lines map[string]int, errs *Errors) {
+	if n.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+		field, ok := fieldByTag(t, key.Value)
+		if !ok {
+			*errs = append(*errs, FieldError{Line: key.Line, Path: path, Message: "unknown setting"})
+			continue
+		}
+		if value.Kind == yaml.MappingNode {
+			walk(value, field.Type, path, lines, errs)
+			continue
+		}
+		lines[path] = value.Line
+	}
+}
+
+// fieldByTag finds the field of t whose yaml tag is name.
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// typeError turns one of yaml's "line N: cannot unmarshal ..." messages into
+// a FieldError, naming the setting when it is the only one on that line.
+func typeError(msg string, lines map[string]int) FieldError {
+	var line int
+	if _, err := fmt.Sscanf(msg, "line %d:", &line); err != nil {
+		return FieldError{Message: msg}
+	}
+	_, msg, _ = strings.Cut(msg, ": ")
+	var path string
+	for p, l := range lines {
+		if l != line {
+			continue
+		}
+		if path != "" {
+			return FieldError{Line: line, Message: msg}
+		}
+		path = p
+	}
+	return FieldError{Line: line, Path: path, Message: msg}
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named variation of the base configuration, such as calm_sea
+// or storm.
+type Profile struct {
+	Name string `json:"name"`
+	// Extends is the profile this one inherits from. Empty inherits from the
+	// base configuration.
+	Extends string `json:"extends,omitempty"`
+	// Config is the base with this profile, and those it extends, applied.
+	Config Config `json:"config"`
+}
+
+// Profiles are the profiles of a directory, resolved over a base
+// configuration.
+type Profiles struct {
+	byName map[string]Profile
+}
+
+// profileFile is a profile as read, before it is resolved.
+type profileFile struct {
+	path        string
+	extends     string
+	extendsLine int
+	root        *yaml.Node
+}
+
+// LoadProfiles reads every .yaml file in dir as a profile named after the
+// file. A profile holds settings laid out like simulation_config.yaml and
+// applies them over base, or over the profile named by its top-level
+// extends key. Server settings cannot be set in a profile. Every problem in
+// every profile is reported at once as Errors.
+func LoadProfiles(dir string, base Config) (*Profiles, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*profileFile)
+	var errs Errors
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ext)
+		path := filepath.Join(dir, e.Name())
+		src := Source{Layer: LayerFile, Name: path}
+		if _, dup := files[name]; dup {
+			errs = append(errs, FieldError{Source: src, Message: fmt.Sprintf("profile %q is defined twice", name)})
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, ferrs := readProfile(path, data)
+		for _, fe := range ferrs {
+			fe.Source = src
+			errs = append(errs, fe)
+		}
+		if f != nil {
+			files[name] = f
+		}
+	}
+
+	p := &Profiles{byName: make(map[string]Profile)}
+	failed := make(map[string]bool)
+	var resolve func(name string, chain []string) (Config, bool)
+	resolve = func(name string, chain []string) (Config, bool) {
+		if prof, ok := p.byName[name]; ok {
+			return prof.Config, true
+		}
+		if failed[name] {
+			return Config{}, false
+		}
+		f := files[name]
+		src := Source{Layer: LayerFile, Name: f.path}
+		chain = append(chain, name)
+		fail := func(fe FieldError) (Config, bool) {
+			fe.Source = src
+			errs = append(errs, fe)
+			failed[name] = true
+			return Config{}, false
+		}
+
+		cfg := base
+		if f.extends != "" {
+			if _, ok := files[f.extends]; !ok {
+				return fail(FieldError{Line: f.extendsLine, Path: "extends", Message: fmt.Sprintf("unknown profile %q", f.extends)})
+			}
+			if slices.Contains(chain, f.extends) {
+				return fail(FieldError{Line: f.extendsLine, Path: "extends", Message: "profiles extend each other: " + strings.Join(append(chain, f.extends), " -> ")})
+			}
+			parent, ok := resolve(f.extends, chain)
+			if !ok {
+				// The parent's own errors are reported already
+				failed[name] = true
+				return Config{}, false
+			}
+			cfg = parent
+		}
+
+		lines, ferrs, err := decodeRoot(f.root, &cfg)
+		if err != nil {
+			return fail(FieldError{Message: err.Error()})
+		}
+		sources := make(Sources, len(lines))
+		for path := range lines {
+			sources[path] = src
+		}
+		ferrs = append(ferrs, cfg.validate(lines, sources)...)
+		if len(ferrs) > 0 {
+			for _, fe := range ferrs {
+				fe.Source = src
+				errs = append(errs, fe)
+			}
+			failed[name] = true
+			return Config{}, false
+		}
+		p.byName[name] = Profile{Name: name, Extends: f.extends, Config: cfg}
+		return cfg, true
+	}
+	for _, name := range sortedKeys(files) {
+		resolve(name, nil)
+	}
+
+	if len(errs) > 0 {
+		errs.sort()
+		return nil, errs
+	}
+	return p, nil
+}
+
+// readProfile reads a profile's document and takes out its extends key.
+// It returns nil if the document cannot be read at all.
+func readProfile(path string, data []byte) (*profileFile, Errors) {
+	root, err := readRoot(data)
+	if err != nil {
+		var errs Errors
+		if errors.As(err, &errs) {
+			return nil, errs
+		}
+		return nil, Errors{{Message: err.Error()}}
+	}
+
+	f := &profileFile{path: path, root: root}
+	var errs Errors
+	for i := 0; i+1 < len(root.Content); {
+		k, v := root.Content[i], root.Content[i+1]
+		switch k.Value {
+		case "extends":
+			if v.Kind != yaml.ScalarNode || v.Value == "" {
+				errs = append(errs, FieldError{Line: k.Line, Path: "extends", Message: "must name a profile"})
+			}
+			f.extends, f.extendsLine = v.Value, k.Line
+		case "server":
+			errs = append(errs, FieldError{Line: k.Line, Path: "server", Message: "server settings cannot be set in a profile"})
+		default:
+			i += 2
+			continue
+		}
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return f, nil
+}
+
+// Get returns the profile called name.
+func (p *Profiles) Get(name string) (Profile, bool) {
+	if p == nil {
+		return Profile{}, false
+	}
+	prof, ok := p.byName[name]
+	return prof, ok
+}
+
+// List returns every profile, ordered by name.
+func (p *Profiles) List() []Profile {
+	if p == nil {
+		return nil
+	}
+	out := make([]Profile, 0, len(p.byName))
+	for _, name := range sortedKeys(p.byName) {
+		out = append(out, p.byName[name])
+	}
+	return out
+}
+
+// Override returns c with the settings in overrides applied and checked.
+// Overrides are keyed like Keys, such as physics.wind.speed, and their
+// values are strings, numbers or booleans as decoded from JSON. Server
+// settings cannot be overridden. name says where the overrides came from,
+// such as "environment", and prefixes their errors.
+func (c Config) Override(name string, overrides map[string]interface{}) (Config, error) {
+	src := Source{Layer: LayerOverride, Name: name}
+	sources := make(Sources, len(overrides))
+	var errs Errors
+	for _, path := range sortedKeys(overrides) {
+		fail := func(msg string) {
+			errs = append(errs, FieldError{Source: src, Path: path, Message: msg})
+		}
+		k, ok := lookupKey(path)
+		switch {
+		case !ok:
+			fail("unknown setting")
+			continue
+		case strings.HasPrefix(path, "server."):
+			fail("server settings cannot be overridden")
+			continue
+		}
+		value, ok := scalarString(overrides[path])
+		if !ok {
+			fail("must be a string, number or boolean")
+			continue
+		}
+		if err := setValue(reflect.ValueOf(&c).Elem().FieldByIndex(k.index), value); err != nil {
+			fail(err.Error())
+			continue
+		}
+		sources[path] = src
+	}
+	errs = append(errs, c.validate(nil, sources)...)
+	if len(errs) > 0 {
+		errs.sort()
+		return Config{}, errs
+	}
+	return c, nil
+}
+
+// scalarString returns v as Set reads it.
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case json.Number:
+		return v.String(), true
+	}
+	return "", false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProfiles writes each document to dir/<name>.yaml and returns dir
+func writeProfiles(t *testing.T, docs map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, doc := range docs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(doc), 0o644))
+	}
+	return dir
+}
+
+func TestShippedProfiles(t *testing.T) {
+	base, err := Load("../../configs/simulation_config.yaml")
+	require.NoError(t, err)
+	profiles, err := LoadProfiles("../../configs/profiles", base)
+	require.NoError(t, err)
+
+	var names []string
+	for _, p := range profiles.List() {
+		names = append(names, p.Name)
+		assert.Equal(t, p.Name, p.Config.Simulation.Name)
+	}
+	assert.Equal(t, []string{"calm_sea", "storm"}, names)
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir := writeProfiles(t, map[string]string{
+		"storm": "physics:\n  wind: {speed: 20, gustiness: 0.7}\n",
+		"gale":  "extends: storm\nphysics:\n  wind: {speed: 30}\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a profile"), 0o644))
+	base := Default()
+	base.Simulation.TimeStep = 0.5
+
+	profiles, err := LoadProfiles(dir, base)
+	require.NoError(t, err)
+	require.Len(t, profiles.List(), 2)
+
+	gale, ok := profiles.Get("gale")
+	require.True(t, ok)
+	assert.Equal(t, "storm", gale.Extends)
+	assert.Equal(t, 30.0, gale.Config.Physics.Wind.Speed)
+	assert.Equal(t, 0.7, gale.Config.Physics.Wind.Gustiness, "settings are inherited from the extended profile")
+	assert.Equal(t, 0.5, gale.Config.Simulation.TimeStep, "and from the base")
+
+	_, ok = profiles.Get("calm")
+	assert.False(t, ok)
+	var none *Profiles
+	_, ok = none.Get("storm")
+	assert.False(t, ok, "a nil Profiles has no profiles")
+}
+
+func TestLoadProfilesReportsEveryError(t *testing.T) {
+	dir := writeProfiles(t, map[string]string{
+		"a":      "extends: b\n",
+		"b":      "extends: a\n",
+		"child":  "extends: broken\n",
+		"broken": "physics:\n  wind:\n    gustiness: 2\n",
+		"ghost":  "extends: nobody\n",
+		"root":   "server:\n  port: 1\n",
+	})
+	_, err := LoadProfiles(dir, Default())
+	assert.EqualError(t, err, filepath.Join(dir, "b.yaml")+": line 1: extends: profiles extend each other: a -> b -> a; "+
+		filepath.Join(dir, "broken.yaml")+": line 3: physics.wind.gustiness: must be between 0 and 1, got 2; "+
+		filepath.Join(dir, "ghost.yaml")+`: line 1: extends: unknown profile "nobody"; `+
+		filepath.Join(dir, "root.yaml")+": line 1: server: server settings cannot be set in a profile")
+}
+
+func TestOverride(t *testing.T) {
+	cfg, err := Default().Override("request", map[string]interface{}{
+		"physics.wind.speed":       12.0,
+		"environment.waves.enable": false,
+		"logging.data_format":      "json",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, cfg.Physics.Wind.Speed)
+	assert.False(t, cfg.Environment.Waves.Enable)
+	assert.Equal(t, "json", cfg.Logging.DataFormat)
+
+	same, err := cfg.Override("request", nil)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, same)
+
+	_, err = Default().Override("environment", map[string]interface{}{
+		"physics.wind.gustiness": 1.5,
+		"physics.wind.speed":     "fast",
+		"server.admin_token":     "x",
+		"vessel.colour":          "red",
+		"vessel.mass":            []interface{}{1},
+	})
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "environment: physics.wind.speed: invalid number \"fast\"; "+
+		"environment: server.admin_token: server settings cannot be overridden; "+
+		"environment: vessel.colour: unknown setting; "+
+		"environment: vessel.mass: must be a string, number or boolean; "+
+		"environment: physics.wind.gustiness: must be between 0 and 1, got 1.5", err.Error())
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// tunablePrefixes are the settings a running simulation takes up at its
+// next tick. The rest shape the run when it starts, so changing them needs a
+// new run.
+var tunablePrefixes = []string{"physics.wind.", "environment.", "debug."}
+
+// TunableSettings describes the settings Tunable accepts, for messages.
+const TunableSettings = "physics.wind.*, environment.* and debug.*"
+
+// Tunable reports whether the setting named by key can change while a
+// simulation runs.
+func Tunable(key string) bool {
+	for _, p := range tunablePrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the value of the setting named by key as Set reads it.
+func (c Config) Get(key string) (string, bool) {
+	k, ok := lookupKey(key)
+	if !ok {
+		return "", false
+	}
+	return formatValue(reflect.ValueOf(c).FieldByIndex(k.index)), true
+}
+
+// Diff returns the keys of the settings whose values differ between a and
+// b, in file order.
+func Diff(a, b Config) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var out []string
+	for _, k := range keys {
+		if !va.FieldByIndex(k.index).Equal(vb.FieldByIndex(k.index)) {
+			out = append(out, k.path)
+		}
+	}
+	return out
+}
+
+// Retune returns c with the tunable settings that differ in next applied.
+// It also returns the keys of the other settings that differ, which a
+// running simulation cannot take up. Server settings are ignored.
+func (c Config) Retune(next Config) (Config, []string) {
+	cv, nv := reflect.ValueOf(&c).Elem(), reflect.ValueOf(next)
+	var fixed []string
+	for _, path := range Diff(c, next) {
+		switch {
+		case strings.HasPrefix(path, "server."):
+		case Tunable(path):
+			k, _ := lookupKey(path)
+			cv.FieldByIndex(k.index).Set(nv.FieldByIndex(k.index))
+		default:
+			fixed = append(fixed, path)
+		}
+	}
+	return c, fixed
+}
+
+// Watch calls fn each time one of paths changes, checking every interval
+// until ctx is done. A directory changes when a file in it is added,
+// removed or modified. Changes are spotted by modification time and size,
+// so fn may run for a file rewritten with the same contents.
+func Watch(ctx context.Context, interval time.Duration, fn func(), paths ...string) {
+	last := fingerprint(paths)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if now := fingerprint(paths); now != last {
+			last = now
+			fn()
+		}
+	}
+}
+
+// fingerprint summarises the names, sizes and modification times of paths
+// and, for directories, the files in them. Missing paths count as empty.
+func fingerprint(paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s missing\n", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		if !info.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if fi, err := e.Info(); err == nil {
+				fmt.Fprintf(&b, "  %s %d %d\n", e.Name(), fi.Size(), fi.ModTime().UnixNano())
+			}
+		}
+	}
+	return b.String()
+}
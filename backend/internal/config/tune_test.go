@@ -0,0 +1,93 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTunable(t *testing.T) {
+	for _, key := range []string{"physics.wind.speed", "environment.waves.height", "environment.current.direction", "debug.visualization_fps"} {
+		assert.True(t, Tunable(key), key)
+	}
+	for _, key := range []string{"simulation.time_step", "physics.gravity", "vessel.mass", "server.port"} {
+		assert.False(t, Tunable(key), key)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := Default()
+	assert.Empty(t, Diff(a, a))
+
+	b := a
+	b.Debug.ShowDebugInfo = !a.Debug.ShowDebugInfo
+	b.Simulation.TimeStep = 0.5
+	b.Server.LogMaxAge = time.Hour
+	assert.Equal(t, []string{"simulation.time_step", "debug.show_debug_info", "server.log_max_age"}, Diff(a, b), "keys come in file order")
+
+	v, ok := b.Get("server.log_max_age")
+	require.True(t, ok)
+	assert.Equal(t, "1h0m0s", v)
+	_, ok = b.Get("vessel.colour")
+	assert.False(t, ok)
+}
+
+func TestRetune(t *testing.T) {
+	cur := Default()
+	next := cur
+	next.Physics.Wind.Speed = 25
+	next.Environment.Waves.Height = 4
+	next.Simulation.TimeStep = 0.5
+	next.Vessel.Mass = 1
+	next.Server.Port = 9000
+
+	got, fixed := cur.Retune(next)
+	assert.Equal(t, []string{"simulation.time_step", "vessel.mass"}, fixed)
+	assert.Equal(t, 25.0, got.Physics.Wind.Speed)
+	assert.Equal(t, 4.0, got.Environment.Waves.Height)
+	assert.Equal(t, cur.Simulation.TimeStep, got.Simulation.TimeStep, "structural settings are kept")
+	assert.Equal(t, cur.Server, got.Server, "server settings are ignored")
+	assert.Equal(t, Default(), cur, "the receiver is not changed")
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sim.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("a"), 0o644))
+	profiles := filepath.Join(dir, "profiles")
+	require.NoError(t, os.Mkdir(profiles, 0o755))
+
+	changed := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Watch(ctx, 5*time.Millisecond, func() { changed <- struct{}{} }, file, profiles)
+	}()
+	// Let Watch take its first look before anything changes
+	time.Sleep(50 * time.Millisecond)
+	wait := func(msg string) {
+		t.Helper()
+		select {
+		case <-changed:
+		case <-time.After(2 * time.Second):
+			t.Fatal(msg)
+		}
+	}
+
+	require.NoError(t, os.WriteFile(file, []byte("ab"), 0o644))
+	wait("a changed file is noticed")
+	require.NoError(t, os.WriteFile(filepath.Join(profiles, "storm.yaml"), []byte("x"), 0o644))
+	wait("a file added to a directory is noticed")
+	require.NoError(t, os.WriteFile(filepath.Join(profiles, "storm.yaml"), []byte("xy"), 0o644))
+	wait("a file changed in a directory is noticed")
+
+	cancel()
+	<-done
+	assert.Empty(t, changed, "nothing else changed")
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+// LogLevels are the accepted logging.level values.
+var LogLevels = []string{"DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL"}
+
+// DataFormats are the accepted logging.data_format values.
+var DataFormats = []string{"csv", "json", "hdf5"}
+
+// Protocols are the accepted network.protocol values.
+var Protocols = []string{"tcp", "udp"}
+
+// Stores are the accepted server.store values.
+var Stores = []string{store.BackendMemory, store.BackendFile, store.BackendSQLite}
+
+// checker collects range errors, placing each on its setting's line or
+// naming the variable or flag that set it.
+type checker struct {
+	lines   map[string]int
+	sources Sources
+	errs    Errors
+}
+
+func (c *checker) failf(path, format string, args ...interface{}) {
+	fe := FieldError{Path: path, Message: fmt.Sprintf(format, args...)}
+	switch src := c.sources[path]; src.Layer {
+	case LayerEnv, LayerFlag, LayerOverride:
+		fe.Source = src
+	case LayerFile:
+		fe.Source, fe.Line = src, c.lines[path]
+	default:
+		if c.sources == nil {
+			fe.Line = c.lines[path]
+		}
+	}
+	c.errs = append(c.errs, fe)
+}
+
+func (c *checker) positive(path string, v float64) {
+	if !(v > 0) || math.IsInf(v, 0) {
+		c.failf(path, "must be positive, got %v", v)
+	}
+}
+
+func (c *checker) nonNegative(path string, v float64) {
+	if !(v >= 0) || math.IsInf(v, 0) {
+		c.failf(path, "must not be negative, got %v", v)
+	}
+}
+
+func (c *checker) between(path string, v, lo, hi float64) {
+	if !(v >= lo && v <= hi) {
+		c.failf(path, "must be between %v and %v, got %v", lo, hi, v)
+	}
+}
+
+func (c *checker) direction(path string, v float64) {
+	c.between(path, v, 0, 360)
+}
+
+func (c *checker) oneOf(path, v string, options []string) {
+	for _, o := range options {
+		if v == o {
+			return
+		}
+	}
+	c.failf(path, "must be one of %v, got %q", options, v)
+}
+
+func (c *checker) required(path, v string) {
+	if v == "" {
+		c.failf(path, "must be set")
+	}
+}
+
+// validate returns every out-of-range setting of c. lines maps settings to
+// their line in the file and sources to their layer; either may be nil.
+func (c Config) validate(lines map[string]int, sources Sources) Errors {
+	ck := &checker{lines: lines, sources: sources}
+
+	s := c.Simulation
+	ck.positive("simulation.time_step", s.TimeStep)
+	ck.nonNegative("simulation.real_time_factor", s.RealTimeFactor)
+	ck.nonNegative("simulation.max_duration_seconds", s.MaxDurationSeconds)
+
+	p := c.Physics
+	ck.nonNegative("physics.gravity", p.Gravity)
+	ck.positive("physics.air_density", p.AirDensity)
+	ck.positive("physics.water_density", p.WaterDensity)
+	ck.nonNegative("physics.wind.speed", p.Wind.Speed)
+	ck.direction("physics.wind.direction", p.Wind.Direction)
+	ck.between("physics.wind.gustiness", p.Wind.Gustiness, 0, 1)
+
+	v := c.Vessel
+	ck.positive("vessel.mass", v.Mass)
+	ck.positive("vessel.length", v.Length)
+	ck.positive("vessel.width", v.Width)
+	ck.positive("vessel.height", v.Height)
+	ck.nonNegative("vessel.drag_coefficient", v.DragCoefficient)
+	ck.positive("vessel.max_speed", v.MaxSpeed)
+	ck.positive("vessel.max_acceleration", v.MaxAcceleration)
+	ck.nonNegative("vessel.thrust.max_forward", v.Thrust.MaxForward)
+	ck.nonNegative("vessel.thrust.max_reverse", v.Thrust.MaxReverse)
+	ck.nonNegative("vessel.thrust.response_time", v.Thrust.ResponseTime)
+
+	sn := c.Sensors
+	ck.positive("sensors.gps.update_rate", sn.GPS.UpdateRate)
+	ck.nonNegative("sensors.gps.position_error", sn.GPS.PositionError)
+	ck.nonNegative("sensors.gps.velocity_error", sn.GPS.VelocityError)
+	ck.positive("sensors.imu.update_rate", sn.IMU.UpdateRate)
+	ck.nonNegative("sensors.imu.acceleration_error", sn.IMU.AccelerationError)
+	ck.nonNegative("sensors.imu.gyro_error", sn.IMU.GyroError)
+	ck.positive("sensors.depth_sensor.update_rate", sn.DepthSensor.UpdateRate)
+	ck.nonNegative("sensors.depth_sensor.error", sn.DepthSensor.Error)
+
+	e := c.Environment
+	ck.nonNegative("environment.current.speed", e.Current.Speed)
+	ck.direction("environment.current.direction", e.Current.Direction)
+	ck.nonNegative("environment.current.depth_variation", e.Current.DepthVariation)
+	ck.nonNegative("environment.waves.height", e.Waves.Height)
+	ck.positive("environment.waves.period", e.Waves.Period)
+	ck.direction("environment.waves.direction", e.Waves.Direction)
+	ck.nonNegative("environment.weather.visibility", e.Weather.Visibility)
+	ck.nonNegative("environment.weather.precipitation", e.Weather.Precipitation)
+	ck.between("environment.weather.temperature", e.Weather.Temperature, -273.15, 100)
+
+	l := c.Logging
+	ck.oneOf("logging.level", l.Level, LogLevels)
+	ck.oneOf("logging.data_format", l.DataFormat, DataFormats)
+	if l.RecordData {
+		ck.required("logging.data_directory", l.DataDirectory)
+	}
+	ck.nonNegative("logging.max_file_size_mb", l.MaxFileSizeMB)
+
+	if c.Debug.EnableVisualization && c.Debug.VisualizationFPS < 1 {
+		ck.failf("debug.visualization_fps", "must be at least 1, got %d", c.Debug.VisualizationFPS)
+	}
+
+	n := c.Network
+	if n.Port < 1 || n.Port > 65535 {
+		ck.failf("network.port", "must be between 1 and 65535, got %d", n.Port)
+	}
+	ck.oneOf("network.protocol", n.Protocol, Protocols)
+	if n.Enabled {
+		ck.required("network.host", n.Host)
+	}
+	if n.MaxClients < 1 {
+		ck.failf("network.max_clients", "must be at least 1, got %d", n.MaxClients)
+	}
+
+	pf := c.Performance
+	if pf.MaxThreads < 1 {
+		ck.failf("performance.max_threads", "must be at least 1, got %d", pf.MaxThreads)
+	}
+	if pf.CacheSize < 0 {
+		ck.failf("performance.cache_size", "must not be negative, got %d", pf.CacheSize)
+	}
+
+	sv := c.Server
+	if sv.Port < 1 || sv.Port > 65535 {
+		ck.failf("server.port", "must be between 1 and 65535, got %d", sv.Port)
+	}
+	ck.oneOf("server.store", sv.Store, Stores)
+	ck.required("server.log_file", sv.LogFile)
+	if sv.LogMaxSizeMB < 0 {
+		ck.failf("server.log_max_size_mb", "must not be negative, got %d", sv.LogMaxSizeMB)
+	}
+	if sv.LogMaxAge < 0 {
+		ck.failf("server.log_max_age", "must not be negative, got %v", sv.LogMaxAge)
+	}
+	if sv.LogRetain < 0 {
+		ck.failf("server.log_retain", "must not be negative, got %d", sv.LogRetain)
+	}
+	if sv.MaxBodyMB < 1 {
+		ck.failf("server.max_body_mb", "must be at least 1, got %d", sv.MaxBodyMB)
+	}
+	ck.required("server.assets_dir", sv.AssetsDir)
+	if sv.ReloadInterval < 0 {
+		ck.failf("server.reload_interval", "must not be negative, got %v", sv.ReloadInterval)
+	}
+	return ck.errs
+}
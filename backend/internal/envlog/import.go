@@ -0,0 +1,177 @@
+// Package envlog handles the JSON Lines environment log written by the API:
+// one {"timestamp": ..., "environment": ...} object per saved environment.
+package envlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// Entry is one line of the environment log.
+type Entry struct {
+	Timestamp   time.Time       `json:"timestamp"`
+	Environment json.RawMessage `json:"environment"`
+}
+
+// LineError describes a log line that could not be imported.
+type LineError struct {
+	Line       int               `json:"line"`
+	Error      string            `json:"error"`
+	Violations []world.Violation `json:"violations,omitempty"`
+}
+
+// Report summarises an import run.
+type Report struct {
+	// Imported counts environments created in the store.
+	Imported int `json:"imported"`
+	// Duplicates counts lines whose environment was already stored, either
+	// by an earlier run or by an earlier line of the same log.
+	Duplicates int `json:"duplicates"`
+	// Invalid lists the lines that were rejected, in log order.
+	Invalid []LineError `json:"invalid"`
+}
+
+// Importer replays environment logs into a store.
+type Importer struct {
+	Store     store.EnvironmentStore
+	Validator world.Validator
+	// DryRun validates and deduplicates without writing to the store.
+	DryRun bool
+}
+
+// Import reads a log from r and creates one environment per valid line.
+// Environments whose content is already stored, in any revision, are skipped,
+// so re-running an import is harmless. Imported environments are stamped with
+// the import time; the log timestamps are not preserved. A returned error
+// means the import stopped early; rejected lines are reported, not returned.
+func (im *Importer) Import(ctx context.Context, r io.Reader) (Report, error) {
+	report := Report{Invalid: []LineError{}}
+
+	seen, err := im.storedHashes(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return report, fmt.Errorf("read line %d: %w", line, err)
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			rejected, err := im.importLine(ctx, raw, seen, &report)
+			if err != nil {
+				return report, fmt.Errorf("line %d: %w", line, err)
+			}
+			if rejected != nil {
+				rejected.Line = line
+				report.Invalid = append(report.Invalid, *rejected)
+			}
+		}
+		if err == io.EOF {
+			return report, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+}
+
+// importLine imports a single non-blank log line. It returns a LineError if
+// the line is rejected and an error if the store fails.
+func (im *Importer) importLine(ctx context.Context, raw []byte, seen map[string]bool, report *Report) (*LineError, error) {
+	var entry Entry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return &LineError{Error: "invalid log entry: " + err.Error()}, nil
+	}
+	if len(entry.Environment) == 0 || string(entry.Environment) == "null" {
+		return &LineError{Error: "missing environment"}, nil
+	}
+
+	env, violations := im.Validator.Validate(entry.Environment)
+	if env == nil || world.HasErrors(violations) {
+		return &LineError{Error: "environment failed validation", Violations: errorsOnly(violations)}, nil
+	}
+
+	hash, err := ContentHash(entry.Environment)
+	if err != nil {
+		return &LineError{Error: "invalid environment: " + err.Error()}, nil
+	}
+	if seen[hash] {
+		report.Duplicates++
+		return nil, nil
+	}
+	if !im.DryRun {
+		if _, err := im.Store.Create(ctx, entry.Environment); err != nil {
+			return nil, fmt.Errorf("store environment: %w", err)
+		}
+	}
+	seen[hash] = true
+	report.Imported++
+	return nil, nil
+}
+
+// storedHashes returns the content hash of every revision in the store.
+func (im *Importer) storedHashes(ctx context.Context) (map[string]bool, error) {
+	records, err := im.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list environments: %w", err)
+	}
+
+	seen := make(map[string]bool, len(records))
+	for _, rec := range records {
+		revs, err := im.Store.Revisions(ctx, rec.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue // deleted since List
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list revisions of %s: %w", rec.ID, err)
+		}
+		for _, rev := range revs {
+			// Revisions that are not valid JSON cannot match a log entry
+			if hash, err := ContentHash(rev.Data); err == nil {
+				seen[hash] = true
+			}
+		}
+	}
+	return seen, nil
+}
+
+// ContentHash returns a hash of a JSON document that ignores formatting,
+// object key order and string escaping, so the compacted copy written to the
+// log hashes the same as the document that was stored.
+func ContentHash(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func errorsOnly(violations []world.Violation) []world.Violation {
+	var out []world.Violation
+	for _, v := range violations {
+		if v.Severity == world.SeverityError {
+			out = append(out, v)
+		}
+	}
+	return out
+}
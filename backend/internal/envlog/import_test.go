@@ -0,0 +1,105 @@
+package envlog
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+const testLog = `{"timestamp":"2025-01-02T03:04:05Z","environment":{"metadata":{"name":"a"},"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[]}}
+not json
+
+{"timestamp":"2025-01-02T03:04:06Z","environment":{"map":{"width":2,"height":2,"tiles":[{"x":9,"y":9,"type":"grass"}]},"objects":[],"agents":[]}}
+{"timestamp":"2025-01-02T03:04:07Z"}
+{"timestamp":"2025-01-02T03:04:08Z","environment":{"metadata":{"name":"a"},"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[]}}
+{"timestamp":"2025-01-02T03:04:09Z","environment":{"metadata":{"name":"b"},"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[]}}`
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	im := &Importer{Store: st}
+
+	report, err := im.Import(ctx, strings.NewReader(testLog))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, 1, report.Duplicates, "line 6 repeats line 1")
+
+	lines := make([]int, 0, len(report.Invalid))
+	for _, inv := range report.Invalid {
+		lines = append(lines, inv.Line)
+	}
+	assert.Equal(t, []int{2, 4, 5}, lines)
+	assert.Contains(t, report.Invalid[0].Error, "invalid log entry")
+	require.Len(t, report.Invalid[1].Violations, 1)
+	assert.Equal(t, "bounds", report.Invalid[1].Violations[0].Rule)
+	assert.Equal(t, "missing environment", report.Invalid[2].Error)
+
+	records, err := st.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// Re-running is a no-op
+	report, err = im.Import(ctx, strings.NewReader(testLog))
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, 3, report.Duplicates)
+	records, err = st.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestImportMatchesEarlierRevisions(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+
+	// Stored with different formatting and key order, then edited
+	rec, err := st.Create(ctx, json.RawMessage(`{
+		"objects": [], "agents": [],
+		"map": {"tiles": [], "height": 2, "width": 2},
+		"metadata": {"name": "a"}
+	}`))
+	require.NoError(t, err)
+	_, err = st.Update(ctx, rec.ID, json.RawMessage(`{"map":{"width":3,"height":3,"tiles":[]},"objects":[],"agents":[]}`), nil)
+	require.NoError(t, err)
+
+	report, err := (&Importer{Store: st}).Import(ctx, strings.NewReader(strings.SplitN(testLog, "\n", 2)[0]))
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, 1, report.Duplicates)
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+
+	report, err := (&Importer{Store: st, DryRun: true}).Import(ctx, strings.NewReader(testLog))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Len(t, report.Invalid, 3)
+
+	records, err := st.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestContentHash(t *testing.T) {
+	a, err := ContentHash([]byte(`{"a": "<b>", "n": 1.50}`))
+	require.NoError(t, err)
+	b, err := ContentHash([]byte(`{"n":1.50,"a":"<b>"}`))
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	c, err := ContentHash([]byte(`{"n":1.5,"a":"<b>"}`))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c, "number notation is part of the content")
+
+	_, err = ContentHash([]byte(`{`))
+	assert.Error(t, err)
+}
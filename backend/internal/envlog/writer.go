@@ -0,0 +1,331 @@
+package envlog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix is the timestamp layout appended to rotated segments. It
+// sorts chronologically as a string.
+const rotatedSuffix = "20060102T150405.000000000Z"
+
+// Options control when a Writer rotates and what it keeps.
+type Options struct {
+	// MaxBytes rotates the log before a write would take it past this
+	// size. Zero disables size-based rotation.
+	MaxBytes int64
+	// MaxAge rotates the log once the current segment is this old. Zero
+	// disables time-based rotation.
+	MaxAge time.Duration
+	// Compress gzips rotated segments.
+	Compress bool
+	// Retain is the number of rotated segments to keep; older ones are
+	// deleted. Zero keeps every segment.
+	Retain int
+}
+
+// Writer appends entries to an environment log, rotating it as configured.
+// It keeps one file handle open and serialises writes, so concurrent callers
+// never interleave partial lines. The file is opened on first use. A Writer
+// is safe for concurrent use.
+type Writer struct {
+	path string
+	opts Options
+	now  func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// background tracks segments being compressed and pruned
+	background sync.WaitGroup
+	pruneMu    sync.Mutex // guards compressing
+	// compressing holds the segments still being gzipped, which prune
+	// leaves alone: until compression ends the segment and its .gz can
+	// both exist and would be counted twice
+	compressing map[string]bool
+}
+
+// NewWriter returns a Writer for the log at path. Nothing is opened until
+// the first write.
+func NewWriter(path string, opts Options) *Writer {
+	return &Writer{path: path, opts: opts, now: time.Now}
+}
+
+// Path returns the path of the active log file.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Open opens the log file, creating it and its directory if needed. Calling
+// it is optional; writes open the file themselves. It lets callers tell a
+// log that cannot be opened apart from a failed write.
+func (w *Writer) Open() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.open()
+}
+
+// open opens the active file if it is not open yet. The caller must hold w.mu.
+func (w *Writer) open() error {
+	if w.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return fmt.Errorf("create log directory: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	// The age of a segment that predates this process is unknown, so it is
+	// counted from when it was reopened.
+	w.opened = w.now()
+	return nil
+}
+
+// WriteEntry appends entry to the log as a single line.
+func (w *Writer) WriteEntry(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// Write appends p to the log in a single write, rotating first if p would
+// take the segment past its limits. p should be one or more whole lines.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.open(); err != nil {
+		return 0, err
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// shouldRotate reports whether writing n more bytes calls for a new segment.
+// An empty segment is never rotated, so an oversized line still gets written.
+// The caller must hold w.mu.
+func (w *Writer) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxBytes > 0 && w.size+n > w.opts.MaxBytes {
+		return true
+	}
+	return w.opts.MaxAge > 0 && w.now().Sub(w.opened) >= w.opts.MaxAge
+}
+
+// Rotate closes the current segment and starts a new one.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if _, err := os.Stat(w.path); err != nil {
+			return nil // nothing to rotate
+		}
+	}
+	return w.rotate()
+}
+
+// rotate renames the active file aside and hands it to the background
+// compressor. The next write reopens the log. The caller must hold w.mu.
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("close log: %w", err)
+		}
+		w.file = nil
+		w.size = 0
+	}
+
+	rotated := w.path + "." + w.now().UTC().Format(rotatedSuffix)
+	if err := os.Rename(w.path, rotated); err != nil {
+		return fmt.Errorf("rotate log: %w", err)
+	}
+
+	if w.opts.Compress {
+		w.pruneMu.Lock()
+		if w.compressing == nil {
+			w.compressing = make(map[string]bool)
+		}
+		w.compressing[rotated] = true
+		w.pruneMu.Unlock()
+	}
+	w.background.Add(1)
+	go func() {
+		defer w.background.Done()
+		if w.opts.Compress {
+			// A failed compression leaves the plain segment in place,
+			// which retention still counts
+			compressFile(rotated)
+			w.pruneMu.Lock()
+			delete(w.compressing, rotated)
+			w.pruneMu.Unlock()
+		}
+		w.prune()
+	}()
+	return nil
+}
+
+// Segments returns the rotated segments of the log, oldest first.
+func (w *Writer) Segments() ([]string, error) {
+	dir, base := filepath.Split(w.path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".gz")
+		if _, err := time.Parse(rotatedSuffix, stamp); err != nil {
+			continue
+		}
+		out = append(out, filepath.Join(dir, name))
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// prune deletes the oldest segments beyond the retention count. Segments
+// still being compressed are neither counted nor deleted; the compressor
+// prunes again when it finishes.
+func (w *Writer) prune() {
+	if w.opts.Retain <= 0 {
+		return
+	}
+	w.pruneMu.Lock()
+	defer w.pruneMu.Unlock()
+
+	all, err := w.Segments()
+	if err != nil {
+		return
+	}
+	var segments []string
+	for _, seg := range all {
+		if !w.compressing[strings.TrimSuffix(seg, ".gz")] {
+			segments = append(segments, seg)
+		}
+	}
+	for len(segments) > w.opts.Retain {
+		os.Remove(segments[0])
+		segments = segments[1:]
+	}
+}
+
+// Close closes the log and waits for background compression to finish.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.background.Wait()
+	return err
+}
+
+// compressFile gzips path into path.gz and removes the original. The
+// compressed file is written under a temporary name so a crash never leaves
+// a truncated .gz behind.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// OpenSegment opens a log file for reading, transparently decompressing
+// rotated segments that end in .gz.
+func OpenSegment(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &gzipFile{Reader: zr, file: f}, nil
+}
+
+// gzipFile closes both the decompressor and the underlying file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
@@ -0,0 +1,175 @@
+package envlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLines returns the lines of a log file or rotated segment
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := OpenSegment(path)
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestWriterRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "env.log")
+	w := NewWriter(path, Options{MaxBytes: 20})
+
+	for _, line := range []string{"aaaaaaaaa\n", "bbbbbbbbb\n", "ccccccccc\n"} {
+		_, err := w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	segments, err := w.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, []string{"aaaaaaaaa", "bbbbbbbbb"}, readLines(t, segments[0]))
+	assert.Equal(t, []string{"ccccccccc"}, readLines(t, path))
+}
+
+func TestWriterRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.log")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWriter(path, Options{MaxAge: time.Hour})
+	w.now = func() time.Time { return now }
+
+	_, err := w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	now = now.Add(30 * time.Minute)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	now = now.Add(30 * time.Minute)
+	_, err = w.Write([]byte("third\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	segments, err := w.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, path+".20250101T010000.000000000Z", segments[0])
+	assert.Equal(t, []string{"first", "second"}, readLines(t, segments[0]))
+	assert.Equal(t, []string{"third"}, readLines(t, path))
+}
+
+func TestWriterCompressesAndRetains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.log")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWriter(path, Options{MaxBytes: 1, Compress: true, Retain: 2})
+	w.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for _, line := range []string{"1\n", "2\n", "3\n", "4\n", "5\n"} {
+		_, err := w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	segments, err := w.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 2, "only the newest segments are retained")
+	for _, seg := range segments {
+		assert.True(t, strings.HasSuffix(seg, ".gz"), seg)
+	}
+	assert.Equal(t, []string{"3"}, readLines(t, segments[0]))
+	assert.Equal(t, []string{"4"}, readLines(t, segments[1]))
+	assert.Equal(t, []string{"5"}, readLines(t, path))
+
+	// Nothing but the active log and retained segments is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestWriterPruneSkipsSegmentsBeingCompressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.log")
+	w := NewWriter(path, Options{Compress: true, Retain: 2})
+	seg := func(n int) string {
+		return path + "." + time.Date(2025, 1, 1, 0, 0, n, 0, time.UTC).Format(rotatedSuffix)
+	}
+	// The third segment is mid-compression: its .gz is in place but the
+	// plain file is not yet removed
+	for _, name := range []string{seg(1), seg(2), seg(3), seg(3) + ".gz"} {
+		require.NoError(t, os.WriteFile(name, []byte("x\n"), 0o644))
+	}
+	w.compressing = map[string]bool{seg(3): true}
+
+	w.prune()
+	segments, err := w.Segments()
+	require.NoError(t, err)
+	assert.Equal(t, []string{seg(1), seg(2), seg(3), seg(3) + ".gz"}, segments, "a segment being compressed is not counted")
+
+	require.NoError(t, os.Remove(seg(3)))
+	delete(w.compressing, seg(3))
+	w.prune()
+	segments, err = w.Segments()
+	require.NoError(t, err)
+	assert.Equal(t, []string{seg(2), seg(3) + ".gz"}, segments)
+}
+
+func TestWriterConcurrentEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.log")
+	w := NewWriter(path, Options{MaxBytes: 4 << 10, Compress: true})
+
+	const writers, perWriter = 8, 50
+	env := json.RawMessage(`{"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[],"pad":"` + strings.Repeat("x", 200) + `"}`)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				assert.NoError(t, w.WriteEntry(Entry{Timestamp: time.Now().UTC(), Environment: env}))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, w.Close())
+
+	segments, err := w.Segments()
+	require.NoError(t, err)
+	require.NotEmpty(t, segments)
+
+	total := 0
+	for _, file := range append(segments, path) {
+		f, err := OpenSegment(file)
+		require.NoError(t, err)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry Entry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "every line should be a whole entry")
+			total++
+		}
+		require.NoError(t, scanner.Err())
+		f.Close()
+	}
+	assert.Equal(t, writers*perWriter, total)
+}
+
+func TestWriterOpenFailure(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+
+	w := NewWriter(filepath.Join(blocker, "env.log"), Options{})
+	assert.Error(t, w.Open())
+	_, err := w.Write([]byte("x\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package pathfinding
+
+import (
+	"container/heap"
+	"math"
+)
+
+// FlowField holds, for every cell, the cheapest remaining cost to a goal and
+// the neighbouring cell to move to next. Agents sharing a goal can share one
+// field instead of planning a path each.
+type FlowField struct {
+	goal          Cell
+	width, height int
+	cost          []float64
+	// next is the index of the cell to move to, or -1 at the goal and at
+	// cells that cannot reach it
+	next []int32
+}
+
+// flowField runs Dijkstra's algorithm outwards from goal over reversed
+// moves, so each cell's cost is that of moving from it to the goal.
+func (g *grid) flowField(goal Cell) *FlowField {
+	ff := &FlowField{
+		goal:   goal,
+		width:  g.width,
+		height: g.height,
+		cost:   make([]float64, len(g.cost)),
+		next:   make([]int32, len(g.cost)),
+	}
+	for i := range ff.cost {
+		ff.cost[i] = math.Inf(1)
+		ff.next[i] = -1
+	}
+	start := g.index(goal)
+	ff.cost[start] = 0
+	open := &queue{{cell: start}}
+
+	for open.Len() > 0 {
+		item := heap.Pop(open).(queueItem)
+		if item.priority > ff.cost[item.cell] {
+			continue
+		}
+		g.neighbours(g.cell(item.cell), true, func(n Cell, step float64) {
+			i := g.index(n)
+			if next := item.priority + step; next < ff.cost[i] {
+				ff.cost[i] = next
+				ff.next[i] = int32(item.cell)
+				heap.Push(open, queueItem{cell: i, priority: next})
+			}
+		})
+	}
+	return ff
+}
+
+// Goal returns the cell the field leads to.
+func (ff *FlowField) Goal() Cell {
+	return ff.goal
+}
+
+func (ff *FlowField) index(c Cell) (int, bool) {
+	if c.X < 0 || c.X >= ff.width || c.Y < 0 || c.Y >= ff.height {
+		return 0, false
+	}
+	return c.Y*ff.width + c.X, true
+}
+
+// Cost returns the cost of the cheapest route from c to the goal, or false
+// if there is none.
+func (ff *FlowField) Cost(c Cell) (float64, bool) {
+	i, ok := ff.index(c)
+	if !ok || math.IsInf(ff.cost[i], 1) {
+		return 0, false
+	}
+	return ff.cost[i], true
+}
+
+// Next returns the cell to move to from c. It returns false at the goal and
+// at cells that cannot reach it.
+func (ff *FlowField) Next(c Cell) (Cell, bool) {
+	i, ok := ff.index(c)
+	if !ok || ff.next[i] < 0 {
+		return Cell{}, false
+	}
+	n := int(ff.next[i])
+	return Cell{n % ff.width, n / ff.width}, true
+}
+
+// Path follows the field from c to the goal.
+func (ff *FlowField) Path(from Cell) (Path, error) {
+	cost, ok := ff.Cost(from)
+	if !ok {
+		return Path{}, ErrNoPath
+	}
+	cells := []Cell{from}
+	for c, ok := ff.Next(from); ok; c, ok = ff.Next(c) {
+		cells = append(cells, c)
+	}
+	return Path{Cells: cells, Cost: cost}, nil
+}
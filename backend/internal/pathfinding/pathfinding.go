@@ -0,0 +1,413 @@
+// Package pathfinding plans routes across the tile grid of a world.World. A
+// Finder answers single queries with A* and builds flow fields that steer
+// any number of agents towards a shared goal. Both are cached until the
+// world's terrain changes.
+package pathfinding
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// ErrNoPath is returned when the goal cannot be reached. Errors for goals off
+// the map or on a blocked cell wrap it.
+var ErrNoPath = errors.New("no path")
+
+const (
+	// DefaultMaxStep is the largest height difference one move may cross
+	// unless Options says otherwise.
+	DefaultMaxStep = 0.5
+	// DefaultCacheSize is the number of paths a Finder keeps unless Options
+	// says otherwise.
+	DefaultCacheSize = 1024
+	// flowCacheSize is the number of flow fields a Finder keeps. Each holds
+	// a value for every cell, so far fewer are kept than paths.
+	flowCacheSize = 16
+)
+
+// Cell is a position on the tile grid.
+type Cell struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (c Cell) String() string {
+	return fmt.Sprintf("(%d,%d)", c.X, c.Y)
+}
+
+// CellOf returns the cell containing a position.
+func CellOf(p world.Vec3) Cell {
+	x, y := world.CellOf(p.X, p.Y)
+	return Cell{x, y}
+}
+
+// Path is a route from its first cell to its last.
+type Path struct {
+	Cells []Cell `json:"cells"`
+	// Cost is the sum of the step costs along the path.
+	Cost float64 `json:"cost"`
+}
+
+// Options control how routes are planned.
+type Options struct {
+	Movement world.Movement
+	// Diagonal allows moves to all eight neighbouring cells. A diagonal move
+	// costs √2 times a straight one and may not cut the corner of a cell
+	// that cannot be entered.
+	Diagonal bool
+	// MaxStep is the largest height difference one move may cross; zero
+	// means DefaultMaxStep. Flying ignores heights.
+	MaxStep float64
+	// ClimbCost is added for each unit of height climbed. Descending is
+	// free.
+	ClimbCost float64
+	// CacheSize is the number of paths to keep; zero means
+	// DefaultCacheSize and a negative value disables the cache.
+	CacheSize int
+}
+
+// Finder plans routes across one world. Results are cached until the world's
+// TerrainVersion changes. A Finder is safe for concurrent use as long as the
+// world is not modified at the same time.
+type Finder struct {
+	world *world.World
+	opts  Options
+
+	mu    sync.Mutex
+	grid  *grid
+	paths *fifo[pathKey, pathResult]
+	flows *fifo[Cell, *FlowField]
+}
+
+type pathKey struct{ from, to Cell }
+
+type pathResult struct {
+	path Path
+	err  error
+}
+
+// NewFinder returns a Finder for w.
+func NewFinder(w *world.World, opts Options) *Finder {
+	if opts.MaxStep <= 0 {
+		opts.MaxStep = DefaultMaxStep
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
+	return &Finder{
+		world: w,
+		opts:  opts,
+		paths: newFIFO[pathKey, pathResult](opts.CacheSize),
+		flows: newFIFO[Cell, *FlowField](flowCacheSize),
+	}
+}
+
+// Invalidate drops every cached result. It is only needed after changes
+// TerrainVersion does not track, such as toggling an object's collision.
+func (f *Finder) Invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.grid = nil
+	f.paths.clear()
+	f.flows.clear()
+}
+
+// refresh rebuilds the grid if the terrain changed since it was built. The
+// caller holds f.mu.
+func (f *Finder) refresh() *grid {
+	if f.grid == nil || f.grid.version != f.world.TerrainVersion() {
+		f.grid = newGrid(f.world, f.opts)
+		f.paths.clear()
+		f.flows.clear()
+	}
+	return f.grid
+}
+
+// Path returns the cheapest route from one cell to another using A*. The
+// start cell only needs to be on the map, so an agent standing somewhere it
+// could not have entered can still leave.
+func (f *Finder) Path(from, to Cell) (Path, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g := f.refresh()
+
+	key := pathKey{from, to}
+	res, ok := f.paths.get(key)
+	if !ok {
+		res.path, res.err = g.astar(from, to)
+		f.paths.put(key, res)
+	}
+	if res.err != nil {
+		return Path{}, res.err
+	}
+	return Path{Cells: append([]Cell(nil), res.path.Cells...), Cost: res.path.Cost}, nil
+}
+
+// FlowField returns a field leading every cell that can reach goal towards
+// it. The field is shared and must not be modified.
+func (f *Finder) FlowField(goal Cell) (*FlowField, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g := f.refresh()
+
+	if ff, ok := f.flows.get(goal); ok {
+		return ff, nil
+	}
+	if err := g.checkGoal(goal); err != nil {
+		return nil, err
+	}
+	ff := g.flowField(goal)
+	f.flows.put(goal, ff)
+	return ff, nil
+}
+
+// grid is a snapshot of the terrain as one movement sees it
+type grid struct {
+	width, height int
+	version       uint64
+	opts          Options
+	// cost is the cost of entering each cell, +Inf if it cannot be entered
+	cost      []float64
+	elevation []float64
+	// minCost is the cheapest cell cost, which keeps the A* heuristic
+	// admissible
+	minCost float64
+}
+
+func newGrid(w *world.World, opts Options) *grid {
+	g := &grid{
+		width:     w.Width,
+		height:    w.Height,
+		version:   w.TerrainVersion(),
+		opts:      opts,
+		cost:      make([]float64, w.Width*w.Height),
+		elevation: make([]float64, w.Width*w.Height),
+	}
+	g.minCost = math.Inf(1)
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			i := y*w.Width + x
+			tile, _ := w.TileAt(x, y)
+			g.elevation[i] = tile.Height
+			cost, ok := w.MoveCost(x, y, opts.Movement)
+			if !ok {
+				g.cost[i] = math.Inf(1)
+				continue
+			}
+			g.cost[i] = cost
+			g.minCost = math.Min(g.minCost, cost)
+		}
+	}
+	for _, o := range w.Objects() {
+		if !w.Collidable(o) {
+			continue
+		}
+		if c := CellOf(o.Position); g.inBounds(c) {
+			g.cost[g.index(c)] = math.Inf(1)
+		}
+	}
+	if math.IsInf(g.minCost, 1) {
+		g.minCost = 0
+	}
+	return g
+}
+
+func (g *grid) inBounds(c Cell) bool {
+	return c.X >= 0 && c.X < g.width && c.Y >= 0 && c.Y < g.height
+}
+
+func (g *grid) index(c Cell) int {
+	return c.Y*g.width + c.X
+}
+
+func (g *grid) cell(i int) Cell {
+	return Cell{i % g.width, i / g.width}
+}
+
+func (g *grid) checkGoal(goal Cell) error {
+	if !g.inBounds(goal) {
+		return fmt.Errorf("%w: goal %v is off the map", ErrNoPath, goal)
+	}
+	if math.IsInf(g.cost[g.index(goal)], 1) {
+		return fmt.Errorf("%w: goal %v cannot be entered", ErrNoPath, goal)
+	}
+	return nil
+}
+
+var (
+	straight = []Cell{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	diagonal = []Cell{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+// neighbours calls fn with every cell next to c on the map and the cost of
+// moving between them: from c to the neighbour, or from the neighbour to c
+// if reverse is set.
+func (g *grid) neighbours(c Cell, reverse bool, fn func(n Cell, cost float64)) {
+	visit := func(d Cell, diag bool) {
+		n := Cell{c.X + d.X, c.Y + d.Y}
+		if !g.inBounds(n) {
+			return
+		}
+		from, to := c, n
+		if reverse {
+			from, to = n, c
+		}
+		if cost, ok := g.step(from, to, diag); ok {
+			fn(n, cost)
+		}
+	}
+	for _, d := range straight {
+		visit(d, false)
+	}
+	if g.opts.Diagonal {
+		for _, d := range diagonal {
+			visit(d, true)
+		}
+	}
+}
+
+// step returns the cost of moving from one cell to an adjacent one.
+func (g *grid) step(from, to Cell, diag bool) (float64, bool) {
+	cost := g.cost[g.index(to)]
+	if math.IsInf(cost, 1) {
+		return 0, false
+	}
+	if diag {
+		// Both cells beside the diagonal must be enterable
+		if math.IsInf(g.cost[g.index(Cell{to.X, from.Y})], 1) || math.IsInf(g.cost[g.index(Cell{from.X, to.Y})], 1) {
+			return 0, false
+		}
+		cost *= math.Sqrt2
+	}
+	if g.opts.Movement != world.Fly {
+		climb := g.elevation[g.index(to)] - g.elevation[g.index(from)]
+		if math.Abs(climb) > g.opts.MaxStep {
+			return 0, false
+		}
+		if climb > 0 {
+			cost += climb * g.opts.ClimbCost
+		}
+	}
+	return cost, true
+}
+
+// estimate is the A* heuristic: the distance between a and b at the
+// cheapest cell cost.
+func (g *grid) estimate(a, b Cell) float64 {
+	dx, dy := math.Abs(float64(a.X-b.X)), math.Abs(float64(a.Y-b.Y))
+	if !g.opts.Diagonal {
+		return (dx + dy) * g.minCost
+	}
+	return (math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)) * g.minCost
+}
+
+func (g *grid) astar(from, to Cell) (Path, error) {
+	if !g.inBounds(from) {
+		return Path{}, fmt.Errorf("%w: start %v is off the map", ErrNoPath, from)
+	}
+	if err := g.checkGoal(to); err != nil {
+		return Path{}, err
+	}
+	if from == to {
+		return Path{Cells: []Cell{from}}, nil
+	}
+
+	cost := make([]float64, len(g.cost))
+	for i := range cost {
+		cost[i] = math.Inf(1)
+	}
+	parent := make([]int32, len(g.cost))
+	start, goal := g.index(from), g.index(to)
+	cost[start] = 0
+	open := &queue{{cell: start, priority: g.estimate(from, to)}}
+
+	for open.Len() > 0 {
+		item := heap.Pop(open).(queueItem)
+		if item.cell == goal {
+			return g.trace(parent, start, goal, cost[goal]), nil
+		}
+		c := g.cell(item.cell)
+		// Skip stale entries left behind when a cheaper route was found
+		if item.priority > cost[item.cell]+g.estimate(c, to) {
+			continue
+		}
+		g.neighbours(c, false, func(n Cell, step float64) {
+			i := g.index(n)
+			if next := cost[item.cell] + step; next < cost[i] {
+				cost[i] = next
+				parent[i] = int32(item.cell)
+				heap.Push(open, queueItem{cell: i, priority: next + g.estimate(n, to)})
+			}
+		})
+	}
+	return Path{}, fmt.Errorf("%w from %v to %v", ErrNoPath, from, to)
+}
+
+func (g *grid) trace(parent []int32, start, goal int, cost float64) Path {
+	var cells []Cell
+	for i := goal; i != start; i = int(parent[i]) {
+		cells = append(cells, g.cell(i))
+	}
+	cells = append(cells, g.cell(start))
+	for i, j := 0, len(cells)-1; i < j; i, j = i+1, j-1 {
+		cells[i], cells[j] = cells[j], cells[i]
+	}
+	return Path{Cells: cells, Cost: cost}
+}
+
+// queue is a min-heap of cells by priority
+type queue []queueItem
+
+type queueItem struct {
+	cell     int
+	priority float64
+}
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// fifo is a bounded cache that evicts its oldest entry first
+type fifo[K comparable, V any] struct {
+	size  int
+	items map[K]V
+	order []K
+}
+
+func newFIFO[K comparable, V any](size int) *fifo[K, V] {
+	return &fifo[K, V]{size: size, items: make(map[K]V)}
+}
+
+func (c *fifo[K, V]) get(k K) (V, bool) {
+	v, ok := c.items[k]
+	return v, ok
+}
+
+func (c *fifo[K, V]) put(k K, v V) {
+	if c.size <= 0 {
+		return
+	}
+	if len(c.items) >= c.size {
+		delete(c.items, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.items[k] = v
+	c.order = append(c.order, k)
+}
+
+func (c *fifo[K, V]) clear() {
+	c.items = make(map[K]V)
+	c.order = nil
+}
@@ -0,0 +1,268 @@
+package pathfinding
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// newWorld builds a world from rows of a text map, top row first:
+// '.' grass, '#' stone, '~' water, '^' grass one unit high and 'R' a
+// collidable rock on grass.
+func newWorld(t *testing.T, rows ...string) *world.World {
+	t.Helper()
+	env := world.EnvironmentSchemaJson{Map: world.EnvironmentSchemaJsonMap{Width: len(rows[0]), Height: len(rows)}}
+	w, err := world.New(&env, nil, nil)
+	require.NoError(t, err)
+
+	for y, row := range rows {
+		for x, c := range row {
+			var tile world.Tile
+			switch c {
+			case '.':
+				continue
+			case '#':
+				tile = world.Tile{Type: "stone"}
+			case '~':
+				tile = world.Tile{Type: "water"}
+			case '^':
+				tile = world.Tile{Type: "grass", Height: 1}
+			case 'R':
+				require.NoError(t, w.AddObject(&world.Object{
+					ID:         fmt.Sprintf("rock-%d-%d", x, y),
+					Position:   world.Vec3{X: float64(x) + 0.5, Y: float64(y) + 0.5},
+					Properties: map[string]interface{}{"collision": true},
+				}))
+				continue
+			default:
+				t.Fatalf("unknown map character %q", c)
+			}
+			require.NoError(t, w.SetTile(x, y, tile))
+		}
+	}
+	return w
+}
+
+func TestPathAroundWater(t *testing.T) {
+	w := newWorld(t,
+		".~...",
+		".~.~.",
+		"...~.",
+	)
+	f := NewFinder(w, Options{Movement: world.Walk})
+
+	path, err := f.Path(Cell{0, 0}, Cell{4, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []Cell{{0, 0}, {0, 1}, {0, 2}, {1, 2}, {2, 2}, {2, 1}, {2, 0}, {3, 0}, {4, 0}}, path.Cells)
+	assert.Equal(t, 8.0, path.Cost)
+
+	swim := NewFinder(w, Options{Movement: world.Swim})
+	_, err = swim.Path(Cell{0, 0}, Cell{4, 0})
+	assert.ErrorIs(t, err, ErrNoPath, "grass cannot be swum")
+
+	fly := NewFinder(w, Options{Movement: world.Fly})
+	path, err = fly.Path(Cell{0, 0}, Cell{4, 0})
+	require.NoError(t, err)
+	assert.Len(t, path.Cells, 5, "flyers go straight over water")
+}
+
+func TestPathModelCollision(t *testing.T) {
+	models, err := world.NewModelRegistry([]world.Model{
+		{Name: "boulder", Properties: map[string]interface{}{"collision": true}},
+	})
+	require.NoError(t, err)
+	env := world.EnvironmentSchemaJson{
+		Map: world.EnvironmentSchemaJsonMap{Width: 3, Height: 2},
+		Objects: []world.EnvironmentSchemaJsonObjectsElem{
+			{Id: "boulder", Model: "boulder", Position: world.EnvironmentSchemaJsonObjectsElemPosition{X: 1.5, Y: 0.5}},
+		},
+	}
+	w, err := world.New(&env, nil, models)
+	require.NoError(t, err)
+
+	path, err := NewFinder(w, Options{Movement: world.Walk}).Path(Cell{0, 0}, Cell{2, 0})
+	require.NoError(t, err)
+	assert.NotContains(t, path.Cells, Cell{1, 0}, "the model makes the boulder collidable")
+}
+
+func TestPathCosts(t *testing.T) {
+	w := newWorld(t,
+		".#####.",
+		".......",
+	)
+	f := NewFinder(w, Options{Movement: world.Walk})
+
+	path, err := f.Path(Cell{0, 0}, Cell{6, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []Cell{{0, 0}, {0, 1}, {1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}, {6, 1}, {6, 0}}, path.Cells, "stone costs more than the detour")
+	assert.Equal(t, 8.0, path.Cost)
+
+	path, err = f.Path(Cell{1, 0}, Cell{3, 0})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, path.Cost, "entering stone costs 1.5")
+}
+
+func TestPathDiagonal(t *testing.T) {
+	w := newWorld(t,
+		"..R",
+		"...",
+		"...",
+	)
+	f := NewFinder(w, Options{Movement: world.Walk, Diagonal: true})
+
+	_, err := f.Path(Cell{0, 2}, Cell{2, 0})
+	assert.ErrorIs(t, err, ErrNoPath, "the rock's cell is blocked")
+
+	path, err := f.Path(Cell{0, 2}, Cell{2, 1})
+	require.NoError(t, err)
+	assert.Len(t, path.Cells, 3)
+	assert.InDelta(t, 1+math.Sqrt2, path.Cost, 1e-9)
+
+	path, err = f.Path(Cell{1, 0}, Cell{2, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Cell{{1, 0}, {1, 1}, {2, 1}}, path.Cells, "moves may not cut the rock's corner")
+}
+
+func TestPathSlopes(t *testing.T) {
+	w := newWorld(t,
+		"...",
+		".^.",
+		"...",
+	)
+
+	f := NewFinder(w, Options{Movement: world.Walk})
+	_, err := f.Path(Cell{0, 1}, Cell{1, 1})
+	assert.ErrorIs(t, err, ErrNoPath, "the step is higher than DefaultMaxStep")
+
+	f = NewFinder(w, Options{Movement: world.Walk, MaxStep: 1, ClimbCost: 2})
+	path, err := f.Path(Cell{0, 1}, Cell{1, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, path.Cost, "one unit climbed at two per unit")
+
+	path, err = f.Path(Cell{0, 1}, Cell{2, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, path.Cost, "going around is cheaper than over")
+
+	fly := NewFinder(w, Options{Movement: world.Fly})
+	path, err = fly.Path(Cell{0, 1}, Cell{2, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, path.Cost, "flyers ignore heights")
+}
+
+func TestPathErrors(t *testing.T) {
+	w := newWorld(t,
+		"..~.",
+		"..~.",
+	)
+	f := NewFinder(w, Options{Movement: world.Walk})
+
+	for _, tt := range []struct {
+		from, to Cell
+		want     string
+	}{
+		{Cell{0, 0}, Cell{3, 0}, "no path from (0,0) to (3,0)"},
+		{Cell{0, 0}, Cell{2, 0}, "no path: goal (2,0) cannot be entered"},
+		{Cell{0, 0}, Cell{4, 0}, "no path: goal (4,0) is off the map"},
+		{Cell{-1, 0}, Cell{1, 0}, "no path: start (-1,0) is off the map"},
+	} {
+		_, err := f.Path(tt.from, tt.to)
+		assert.ErrorIs(t, err, ErrNoPath)
+		assert.EqualError(t, err, tt.want)
+	}
+
+	path, err := f.Path(Cell{2, 1}, Cell{1, 1})
+	require.NoError(t, err, "agents may leave a cell they could not enter")
+	assert.Equal(t, 1.0, path.Cost)
+
+	_, err = f.Path(Cell{1, 1}, Cell{1, 1})
+	assert.NoError(t, err)
+}
+
+func TestPathCacheInvalidation(t *testing.T) {
+	w := newWorld(t,
+		"....",
+		"....",
+	)
+	f := NewFinder(w, Options{Movement: world.Walk})
+
+	path, err := f.Path(Cell{0, 0}, Cell{3, 0})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, path.Cost)
+
+	path.Cells[0] = Cell{9, 9}
+	again, err := f.Path(Cell{0, 0}, Cell{3, 0})
+	require.NoError(t, err)
+	assert.Equal(t, Cell{0, 0}, again.Cells[0], "callers cannot corrupt the cache")
+
+	require.NoError(t, w.SetTile(1, 0, world.Tile{Type: "water"}))
+	path, err = f.Path(Cell{0, 0}, Cell{3, 0})
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, path.Cost, "the changed tile is seen")
+
+	require.NoError(t, w.AddObject(&world.Object{ID: "rock", Position: world.Vec3{X: 1.5, Y: 1.5},
+		Properties: map[string]interface{}{"collision": true}}))
+	_, err = f.Path(Cell{0, 0}, Cell{3, 0})
+	assert.ErrorIs(t, err, ErrNoPath, "the new rock is seen")
+
+	require.True(t, w.MoveObject("rock", world.Vec3{X: 3.5, Y: 1.5}))
+	path, err = f.Path(Cell{0, 0}, Cell{3, 0})
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, path.Cost)
+
+	flow, err := f.FlowField(Cell{3, 0})
+	require.NoError(t, err)
+	require.True(t, w.RemoveObject("rock"))
+	fresh, err := f.FlowField(Cell{3, 0})
+	require.NoError(t, err)
+	assert.NotSame(t, flow, fresh)
+}
+
+func TestFlowField(t *testing.T) {
+	w := newWorld(t,
+		"..~....",
+		".R~.^#.",
+		"..~.~..",
+		"....~.~",
+		".~~.~.~",
+	)
+	for _, opts := range []Options{
+		{Movement: world.Walk},
+		{Movement: world.Walk, Diagonal: true, MaxStep: 1, ClimbCost: 0.5},
+	} {
+		f := NewFinder(w, opts)
+		goal := Cell{6, 0}
+		flow, err := f.FlowField(goal)
+		require.NoError(t, err)
+		assert.Equal(t, goal, flow.Goal())
+
+		_, ok := flow.Next(goal)
+		assert.False(t, ok, "the goal has no next cell")
+
+		// Every cell gets the same answer from the field as from A*
+		for y := 0; y < w.Height; y++ {
+			for x := 0; x < w.Width; x++ {
+				from := Cell{x, y}
+				want, err := f.Path(from, goal)
+				got, ferr := flow.Path(from)
+				if err != nil {
+					assert.ErrorIs(t, ferr, ErrNoPath, "%v", from)
+					_, ok := flow.Cost(from)
+					assert.False(t, ok)
+					continue
+				}
+				require.NoError(t, ferr, "%v", from)
+				assert.InDelta(t, want.Cost, got.Cost, 1e-9, "%v", from)
+				assert.Equal(t, goal, got.Cells[len(got.Cells)-1])
+			}
+		}
+	}
+
+	f := NewFinder(w, Options{Movement: world.Walk})
+	_, err := f.FlowField(Cell{2, 0})
+	assert.ErrorIs(t, err, ErrNoPath)
+}
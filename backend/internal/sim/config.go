@@ -0,0 +1,45 @@
+package sim
+
+import "fmt"
+
+// Config is the simulation section of simulation_config.yaml, which the
+// config package reads.
+type Config struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Version     string `yaml:"version" json:"version,omitempty"`
+	// MaxDurationSeconds is the simulated time after which a run finishes.
+	// Zero means it runs until stopped.
+	MaxDurationSeconds float64 `yaml:"max_duration_seconds" json:"max_duration_seconds"`
+	// TimeStep is the simulated seconds advanced by each tick.
+	TimeStep float64 `yaml:"time_step" json:"time_step"`
+	// RealTimeFactor is simulated seconds per wall-clock second. Zero runs
+	// as fast as possible.
+	RealTimeFactor float64 `yaml:"real_time_factor" json:"real_time_factor"`
+	// RandomSeed seeds the random source systems draw from, so runs of the
+	// same world are reproducible.
+	RandomSeed int64 `yaml:"random_seed" json:"random_seed"`
+}
+
+// DefaultConfig matches the shipped configs/simulation_config.yaml.
+var DefaultConfig = Config{
+	Name:               "default_simulation",
+	MaxDurationSeconds: 3600,
+	TimeStep:           0.1,
+	RealTimeFactor:     1,
+	RandomSeed:         42,
+}
+
+// Check reports whether c describes a runnable simulation.
+func (c Config) Check() error {
+	if !(c.TimeStep > 0) {
+		return fmt.Errorf("time_step must be positive, got %v", c.TimeStep)
+	}
+	if !(c.RealTimeFactor >= 0) {
+		return fmt.Errorf("real_time_factor must not be negative, got %v", c.RealTimeFactor)
+	}
+	if !(c.MaxDurationSeconds >= 0) {
+		return fmt.Errorf("max_duration_seconds must not be negative, got %v", c.MaxDurationSeconds)
+	}
+	return nil
+}
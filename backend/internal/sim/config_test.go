@@ -0,0 +1,26 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigCheck(t *testing.T) {
+	assert.NoError(t, DefaultConfig.Check())
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"zero step", Config{}, "time_step must be positive, got 0"},
+		{"negative factor", Config{TimeStep: 1, RealTimeFactor: -1}, "real_time_factor must not be negative"},
+		{"negative duration", Config{TimeStep: 1, MaxDurationSeconds: -5}, "max_duration_seconds must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.cfg.Check(), tt.want)
+		})
+	}
+}
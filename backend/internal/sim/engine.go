@@ -0,0 +1,226 @@
+// Package sim advances a world.World through time in fixed steps.
+package sim
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// State is where an Engine is in its lifecycle.
+type State string
+
+const (
+	// StateIdle is an engine that has not been run.
+	StateIdle State = "idle"
+	// StateRunning is an engine inside Run.
+	StateRunning State = "running"
+	// StateFinished is an engine that reached its maximum duration.
+	StateFinished State = "finished"
+	// StateStopped is an engine whose run was cancelled. It can be run
+	// again and continues where it stopped.
+	StateStopped State = "stopped"
+)
+
+// Tick is what a System sees while one step is computed.
+type Tick struct {
+	World *world.World
+	// Number counts ticks from 1.
+	Number uint64
+	// Time is the simulated time in seconds at the start of the tick.
+	Time float64
+	// Step is the simulated seconds the tick advances.
+	Step float64
+	// Rand is the engine's seeded random source.
+	Rand *rand.Rand
+	// Params are the parameters last given to SetParams when the tick
+	// started, or nil. They hold for the whole tick.
+	Params interface{}
+}
+
+// System updates the world once per tick, such as a behavior driving agents.
+type System interface {
+	Update(t *Tick)
+}
+
+// SystemFunc adapts a function to a System.
+type SystemFunc func(t *Tick)
+
+// Update calls f(t).
+func (f SystemFunc) Update(t *Tick) {
+	f(t)
+}
+
+// Status is a snapshot of an engine's progress.
+type Status struct {
+	State State  `json:"state"`
+	Tick  uint64 `json:"tick"`
+	// SimTime is the simulated time in seconds.
+	SimTime float64 `json:"sim_time"`
+}
+
+// Engine runs systems over a world in fixed time steps. An Engine is safe
+// for concurrent use; the world must only be touched through systems and
+// View while it runs.
+type Engine struct {
+	cfg     Config
+	world   *world.World
+	systems []System
+
+	mu    sync.Mutex // guards everything below and the world
+	rng   *rand.Rand
+	tick  uint64
+	state State
+	// params are passed to systems in Tick.Params
+	params interface{}
+}
+
+// New returns an idle engine for w. It fails if cfg is invalid.
+func New(w *world.World, cfg Config, systems ...System) (*Engine, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	return &Engine{
+		cfg:     cfg,
+		world:   w,
+		systems: systems,
+		rng:     rand.New(rand.NewSource(cfg.RandomSeed)),
+		state:   StateIdle,
+	}, nil
+}
+
+// Config returns the engine's configuration.
+func (e *Engine) Config() Config {
+	return e.cfg
+}
+
+// SetParams replaces the parameters systems see in Tick.Params, such as the
+// settings of a run that can be tuned while it runs. They apply from the
+// next tick. p should not be changed afterwards.
+func (e *Engine) SetParams(p interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.params = p
+}
+
+// Status returns the engine's current progress.
+func (e *Engine) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Status{State: e.state, Tick: e.tick, SimTime: e.simTime()}
+}
+
+// View calls fn with the world between ticks.
+func (e *Engine) View(fn func(w *world.World)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn(e.world)
+}
+
+// simTime is computed from the tick count rather than accumulated, so it
+// does not drift however long the run. The caller holds e.mu.
+func (e *Engine) simTime() float64 {
+	return float64(e.tick) * e.cfg.TimeStep
+}
+
+// maxTicks returns the tick count at which the run finishes, or false if it
+// has no limit.
+func (e *Engine) maxTicks() (uint64, bool) {
+	if e.cfg.MaxDurationSeconds == 0 {
+		return 0, false
+	}
+	// Allow for steps such as 0.1 that floats cannot represent exactly
+	return uint64(math.Floor(e.cfg.MaxDurationSeconds/e.cfg.TimeStep + 1e-9)), true
+}
+
+// Step advances the world by one tick and reports whether the run can
+// continue. It does nothing once the maximum duration is reached.
+func (e *Engine) Step() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.step()
+}
+
+// step is Step with e.mu held.
+func (e *Engine) step() bool {
+	limit, limited := e.maxTicks()
+	if limited && e.tick >= limit {
+		e.state = StateFinished
+		return false
+	}
+	t := &Tick{
+		World:  e.world,
+		Number: e.tick + 1,
+		Time:   e.simTime(),
+		Step:   e.cfg.TimeStep,
+		Rand:   e.rng,
+		Params: e.params,
+	}
+	for _, s := range e.systems {
+		s.Update(t)
+	}
+	e.tick++
+	if limited && e.tick >= limit {
+		e.state = StateFinished
+		return false
+	}
+	return true
+}
+
+// Run steps the world until the maximum duration is reached or ctx is
+// cancelled, pacing ticks to the real-time factor. A run that falls behind
+// steps without waiting until it catches up. Run returns nil when the run
+// finishes and ctx's error when it is cancelled.
+func (e *Engine) Run(ctx context.Context) error {
+	e.mu.Lock()
+	if e.state == StateFinished {
+		e.mu.Unlock()
+		return nil
+	}
+	e.state = StateRunning
+	e.mu.Unlock()
+
+	start := time.Now()
+	var ticks int64
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		if err := ctx.Err(); err != nil {
+			e.setState(StateStopped)
+			return err
+		}
+		e.mu.Lock()
+		more := e.step()
+		e.mu.Unlock()
+		if !more {
+			return nil
+		}
+		ticks++
+
+		if e.cfg.RealTimeFactor == 0 {
+			continue
+		}
+		due := start.Add(time.Duration(float64(ticks) * e.cfg.TimeStep / e.cfg.RealTimeFactor * float64(time.Second)))
+		wait := time.Until(due)
+		if wait <= 0 {
+			continue
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			e.setState(StateStopped)
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (e *Engine) setState(s State) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state = s
+}
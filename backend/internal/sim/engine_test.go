@@ -0,0 +1,143 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+func newTestWorld(t *testing.T) *world.World {
+	t.Helper()
+	w, err := world.New(&world.EnvironmentSchemaJson{
+		Map:    world.EnvironmentSchemaJsonMap{Width: 10, Height: 10},
+		Agents: []world.EnvironmentSchemaJsonAgentsElem{{Id: "walker"}},
+	}, nil, nil)
+	require.NoError(t, err)
+	return w
+}
+
+// walk moves the walker one tile per simulated second along X
+var walk = SystemFunc(func(t *Tick) {
+	a, _ := t.World.AgentByID("walker")
+	p := a.Position
+	p.X += t.Step
+	t.World.MoveAgent(a.ID, p)
+})
+
+func TestEngineStep(t *testing.T) {
+	cfg := Config{TimeStep: 0.1, MaxDurationSeconds: 1}
+	var ticks []Tick
+	record := SystemFunc(func(t *Tick) { ticks = append(ticks, *t) })
+	e, err := New(newTestWorld(t), cfg, walk, record)
+	require.NoError(t, err)
+	assert.Equal(t, Status{State: StateIdle}, e.Status())
+
+	for i := 0; i < 9; i++ {
+		require.True(t, e.Step())
+	}
+	assert.False(t, e.Step(), "the tenth tick reaches max_duration_seconds")
+	assert.False(t, e.Step())
+
+	status := e.Status()
+	assert.Equal(t, StateFinished, status.State)
+	assert.Equal(t, uint64(10), status.Tick)
+	assert.InDelta(t, 1.0, status.SimTime, 1e-9)
+
+	require.Len(t, ticks, 10)
+	assert.Equal(t, uint64(1), ticks[0].Number)
+	assert.Equal(t, 0.0, ticks[0].Time)
+	assert.InDelta(t, 0.9, ticks[9].Time, 1e-9)
+
+	e.View(func(w *world.World) {
+		a, _ := w.AgentByID("walker")
+		assert.InDelta(t, 1.0, a.Position.X, 1e-9)
+		assert.Len(t, w.AgentsWithin(world.Vec3{X: 1}, 0.01), 1, "moves go through the spatial index")
+	})
+}
+
+func TestEngineRunAsFastAsPossible(t *testing.T) {
+	e, err := New(newTestWorld(t), Config{TimeStep: 0.1, MaxDurationSeconds: 3600}, walk)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, e.Run(context.Background()))
+	assert.Less(t, time.Since(start), 5*time.Second, "an hour of simulated time does not wait for the clock")
+	assert.Equal(t, Status{State: StateFinished, Tick: 36000, SimTime: 3600}, roundStatus(e.Status()))
+
+	require.NoError(t, e.Run(context.Background()), "a finished run stays finished")
+	assert.Equal(t, uint64(36000), e.Status().Tick)
+}
+
+func TestEngineRunPaced(t *testing.T) {
+	e, err := New(newTestWorld(t), Config{TimeStep: 0.02, MaxDurationSeconds: 0.4, RealTimeFactor: 2})
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, e.Run(context.Background()))
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 190*time.Millisecond, "0.4s of simulated time at twice real time")
+	assert.Less(t, elapsed, 2*time.Second)
+	assert.Equal(t, uint64(20), e.Status().Tick)
+}
+
+func TestEngineRunCancelled(t *testing.T) {
+	e, err := New(newTestWorld(t), Config{TimeStep: 0.01, RealTimeFactor: 1}, walk)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- e.Run(ctx) }()
+	require.Eventually(t, func() bool { return e.Status().Tick >= 3 }, 2*time.Second, time.Millisecond)
+	assert.Equal(t, StateRunning, e.Status().State)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	stopped := e.Status()
+	assert.Equal(t, StateStopped, stopped.State)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped.Tick, e.Status().Tick, "no ticks after Run returns")
+}
+
+func TestEngineSeed(t *testing.T) {
+	draws := func() []int {
+		var out []int
+		e, err := New(newTestWorld(t), Config{TimeStep: 1, MaxDurationSeconds: 5, RandomSeed: 7},
+			SystemFunc(func(t *Tick) { out = append(out, t.Rand.Intn(1000)) }))
+		require.NoError(t, err)
+		require.NoError(t, e.Run(context.Background()))
+		return out
+	}
+	first := draws()
+	assert.Len(t, first, 5)
+	assert.Equal(t, first, draws(), "the same seed gives the same run")
+}
+
+func TestEngineParams(t *testing.T) {
+	var seen []interface{}
+	e, err := New(newTestWorld(t), Config{TimeStep: 1},
+		SystemFunc(func(t *Tick) { seen = append(seen, t.Params) }))
+	require.NoError(t, err)
+
+	e.Step()
+	e.SetParams("calm")
+	e.Step()
+	e.Step()
+	e.SetParams("storm")
+	e.Step()
+	assert.Equal(t, []interface{}{nil, "calm", "calm", "storm"}, seen, "params apply from the next tick")
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	_, err := New(newTestWorld(t), Config{})
+	assert.ErrorContains(t, err, "time_step must be positive")
+}
+
+func roundStatus(s Status) Status {
+	s.SimTime = float64(int64(s.SimTime*1e6+0.5)) / 1e6
+	return s
+}
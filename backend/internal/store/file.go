@@ -0,0 +1,373 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	fileMeta     = "meta.json"
+	dirRevisions = "revisions"
+)
+
+// fileMetaRecord is the on-disk form of a record's bookkeeping fields.
+type fileMetaRecord struct {
+	ID        string             `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Revisions []fileMetaRevision `json:"revisions"`
+	// Details are those of the latest revision, so listing reads only
+	// meta.json
+	Details Details `json:"details"`
+}
+
+// fileMetaRevision records when a revision was written.
+type fileMetaRevision struct {
+	Number    int       `json:"revision"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// File stores each environment in its own directory below a root directory:
+//
+//	<root>/<id>/revisions/<n>.json  every revision, never rewritten
+//	<root>/<id>/meta.json           timestamps, the revision index and the
+//	                                listing details
+//
+// The latest definition is the last revision meta.json lists. Files are
+// replaced atomically and meta.json is written last, so a crash never
+// exposes a half-written revision, and one that interrupts a write leaves
+// the previous revision current.
+type File struct {
+	mu   sync.RWMutex
+	root string
+	now  func() time.Time
+}
+
+// NewFile returns a file store rooted at root, creating the directory if needed.
+func NewFile(root string) (*File, error) {
+	if root == "" {
+		return nil, errors.New("file store requires a root directory")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create store directory: %w", err)
+	}
+	return &File{root: root, now: utcNow}, nil
+}
+
+// Create stores data as revision 1 of a newly generated ID.
+func (f *File) Create(ctx context.Context, data json.RawMessage) (Record, error) {
+	id, err := NewID()
+	if err != nil {
+		return Record{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	meta := fileMetaRecord{
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Revisions: []fileMetaRevision{{Number: 1, CreatedAt: now}},
+		Details:   readDetails(data),
+	}
+	if err := f.write(meta, data); err != nil {
+		os.RemoveAll(f.dir(id))
+		return Record{}, err
+	}
+	return recordFromMeta(meta, clone(data)), nil
+}
+
+// Get returns the environment stored under id.
+func (f *File) Get(ctx context.Context, id string) (Record, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	meta, err := f.readMeta(id)
+	if err != nil {
+		return Record{}, err
+	}
+	return f.readRecord(meta)
+}
+
+// List returns every stored environment ordered by creation time.
+func (f *File) List(ctx context.Context) ([]Record, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(f.root)
+	if err != nil {
+		return nil, fmt.Errorf("read store directory: %w", err)
+	}
+
+	out := make([]Record, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !validID(entry.Name()) {
+			continue
+		}
+		meta, err := f.readMeta(entry.Name())
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec, err := f.readRecord(meta)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	sortRecords(out)
+	return out, nil
+}
+
+// Summaries returns the summary of every stored environment ordered by
+// creation time. Only meta.json files are read.
+func (f *File) Summaries(ctx context.Context) ([]Summary, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(f.root)
+	if err != nil {
+		return nil, fmt.Errorf("read store directory: %w", err)
+	}
+
+	out := make([]Summary, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !validID(entry.Name()) {
+			continue
+		}
+		meta, err := f.readMeta(entry.Name())
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Summary{
+			ID:        meta.ID,
+			Revision:  len(meta.Revisions),
+			CreatedAt: meta.CreatedAt,
+			UpdatedAt: meta.UpdatedAt,
+			Details:   meta.Details,
+		})
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+// Update stores data as the next revision of id.
+func (f *File) Update(ctx context.Context, id string, data json.RawMessage, pre Precondition) (Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	meta, err := f.readMeta(id)
+	if err != nil {
+		return Record{}, err
+	}
+	if err := f.checkPrecondition(meta, pre); err != nil {
+		return Record{}, err
+	}
+	now := f.now()
+	meta.UpdatedAt = now
+	meta.Revisions = append(meta.Revisions, fileMetaRevision{
+		Number:    len(meta.Revisions) + 1,
+		CreatedAt: now,
+	})
+	meta.Details = readDetails(data)
+	if err := f.write(meta, data); err != nil {
+		return Record{}, err
+	}
+	return recordFromMeta(meta, clone(data)), nil
+}
+
+// Revisions lists every revision of id, oldest first.
+func (f *File) Revisions(ctx context.Context, id string) ([]Revision, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	meta, err := f.readMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Revision, 0, len(meta.Revisions))
+	for _, r := range meta.Revisions {
+		rev, err := f.readRevision(meta, r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rev)
+	}
+	return out, nil
+}
+
+// Revision returns revision number of id.
+func (f *File) Revision(ctx context.Context, id string, number int) (Revision, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	meta, err := f.readMeta(id)
+	if err != nil {
+		return Revision{}, err
+	}
+	if number < 1 || number > len(meta.Revisions) {
+		return Revision{}, ErrRevisionNotFound
+	}
+	return f.readRevision(meta, meta.Revisions[number-1])
+}
+
+// Delete removes the environment stored under id.
+func (f *File) Delete(ctx context.Context, id string, pre Precondition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	meta, err := f.readMeta(id)
+	if err != nil {
+		return err
+	}
+	if err := f.checkPrecondition(meta, pre); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(f.dir(id)); err != nil {
+		return fmt.Errorf("remove environment directory: %w", err)
+	}
+	return nil
+}
+
+// Close is a no-op for the file store.
+func (f *File) Close() error {
+	return nil
+}
+
+func (f *File) dir(id string) string {
+	return filepath.Join(f.root, id)
+}
+
+func (f *File) revisionPath(id string, number int) string {
+	return filepath.Join(f.dir(id), dirRevisions, strconv.Itoa(number)+".json")
+}
+
+// readMeta loads the bookkeeping file of id. The caller must hold f.mu.
+func (f *File) readMeta(id string) (fileMetaRecord, error) {
+	if !validID(id) {
+		return fileMetaRecord{}, ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(f.dir(id), fileMeta))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileMetaRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return fileMetaRecord{}, fmt.Errorf("read environment metadata: %w", err)
+	}
+	var meta fileMetaRecord
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fileMetaRecord{}, fmt.Errorf("decode environment metadata %s: %w", id, err)
+	}
+	if len(meta.Revisions) == 0 {
+		return fileMetaRecord{}, fmt.Errorf("environment metadata %s lists no revisions", id)
+	}
+	return meta, nil
+}
+
+// checkPrecondition evaluates pre against the record described by meta,
+// skipping the read when there is nothing to check. The caller must hold f.mu.
+func (f *File) checkPrecondition(meta fileMetaRecord, pre Precondition) error {
+	if pre == nil {
+		return nil
+	}
+	rec, err := f.readRecord(meta)
+	if err != nil {
+		return err
+	}
+	return pre.check(rec)
+}
+
+// readRecord loads the latest revision listed in meta. The caller must hold
+// f.mu.
+func (f *File) readRecord(meta fileMetaRecord) (Record, error) {
+	latest := meta.Revisions[len(meta.Revisions)-1].Number
+	data, err := os.ReadFile(f.revisionPath(meta.ID, latest))
+	if err != nil {
+		return Record{}, fmt.Errorf("read environment: %w", err)
+	}
+	return recordFromMeta(meta, data), nil
+}
+
+// readRevision loads one revision described by meta. The caller must hold f.mu.
+func (f *File) readRevision(meta fileMetaRecord, r fileMetaRevision) (Revision, error) {
+	data, err := os.ReadFile(f.revisionPath(meta.ID, r.Number))
+	if err != nil {
+		return Revision{}, fmt.Errorf("read revision %d: %w", r.Number, err)
+	}
+	return Revision{
+		EnvironmentID: meta.ID,
+		Number:        r.Number,
+		Data:          data,
+		CreatedAt:     r.CreatedAt,
+	}, nil
+}
+
+// write persists data as the latest revision listed in meta. The revision is
+// written before the metadata so it only becomes visible once its data
+// exists. The caller must hold f.mu for writing.
+func (f *File) write(meta fileMetaRecord, data json.RawMessage) error {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	latest := meta.Revisions[len(meta.Revisions)-1].Number
+	if err := os.MkdirAll(filepath.Join(f.dir(meta.ID), dirRevisions), 0o755); err != nil {
+		return fmt.Errorf("create revisions directory: %w", err)
+	}
+	if err := writeFileAtomic(f.revisionPath(meta.ID, latest), data); err != nil {
+		return fmt.Errorf("write revision: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(f.dir(meta.ID), fileMeta), metaBytes); err != nil {
+		return fmt.Errorf("write environment metadata: %w", err)
+	}
+	return nil
+}
+
+func recordFromMeta(meta fileMetaRecord, data json.RawMessage) Record {
+	return Record{
+		ID:        meta.ID,
+		Revision:  len(meta.Revisions),
+		Data:      data,
+		CreatedAt: meta.CreatedAt,
+		UpdatedAt: meta.UpdatedAt,
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
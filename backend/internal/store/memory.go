@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process environment store. It is safe for concurrent use.
+type Memory struct {
+	mu        sync.RWMutex
+	records   map[string]Record
+	revisions map[string][]Revision
+	details   map[string]Details
+	now       func() time.Time
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		records:   make(map[string]Record),
+		revisions: make(map[string][]Revision),
+		details:   make(map[string]Details),
+		now:       utcNow,
+	}
+}
+
+// Create stores data as revision 1 of a newly generated ID.
+func (m *Memory) Create(ctx context.Context, data json.RawMessage) (Record, error) {
+	id, err := NewID()
+	if err != nil {
+		return Record{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	rec := Record{
+		ID:        id,
+		Revision:  1,
+		Data:      clone(data),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.records[id] = rec
+	m.revisions[id] = []Revision{{EnvironmentID: id, Number: 1, Data: rec.Data, CreatedAt: now}}
+	m.details[id] = readDetails(data)
+	return copyRecord(rec), nil
+}
+
+// Get returns the environment stored under id.
+func (m *Memory) Get(ctx context.Context, id string) (Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.records[id]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return copyRecord(rec), nil
+}
+
+// List returns every stored environment ordered by creation time.
+func (m *Memory) List(ctx context.Context) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Record, 0, len(m.records))
+	for _, rec := range m.records {
+		out = append(out, copyRecord(rec))
+	}
+	sortRecords(out)
+	return out, nil
+}
+
+// Summaries returns the summary of every stored environment ordered by
+// creation time.
+func (m *Memory) Summaries(ctx context.Context) ([]Summary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Summary, 0, len(m.records))
+	for id, rec := range m.records {
+		out = append(out, copySummary(Summary{
+			ID:        id,
+			Revision:  rec.Revision,
+			CreatedAt: rec.CreatedAt,
+			UpdatedAt: rec.UpdatedAt,
+			Details:   m.details[id],
+		}))
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+// Update stores data as the next revision of id.
+func (m *Memory) Update(ctx context.Context, id string, data json.RawMessage, pre Precondition) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.records[id]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	if err := pre.check(rec); err != nil {
+		return Record{}, err
+	}
+	rec.Revision++
+	rec.Data = clone(data)
+	rec.UpdatedAt = m.now()
+	m.records[id] = rec
+	m.revisions[id] = append(m.revisions[id], Revision{
+		EnvironmentID: id,
+		Number:        rec.Revision,
+		Data:          rec.Data,
+		CreatedAt:     rec.UpdatedAt,
+	})
+	m.details[id] = readDetails(data)
+	return copyRecord(rec), nil
+}
+
+// Revisions lists every revision of id, oldest first.
+func (m *Memory) Revisions(ctx context.Context, id string) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revs, ok := m.revisions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := make([]Revision, len(revs))
+	for i, rev := range revs {
+		out[i] = copyRevision(rev)
+	}
+	return out, nil
+}
+
+// Revision returns revision number of id.
+func (m *Memory) Revision(ctx context.Context, id string, number int) (Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revs, ok := m.revisions[id]
+	if !ok {
+		return Revision{}, ErrNotFound
+	}
+	if number < 1 || number > len(revs) {
+		return Revision{}, ErrRevisionNotFound
+	}
+	return copyRevision(revs[number-1]), nil
+}
+
+// Delete removes the environment stored under id.
+func (m *Memory) Delete(ctx context.Context, id string, pre Precondition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := pre.check(rec); err != nil {
+		return err
+	}
+	delete(m.records, id)
+	delete(m.revisions, id)
+	delete(m.details, id)
+	return nil
+}
+
+// Close is a no-op for the in-memory store.
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,349 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Register the pure-Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS environments (
+	id         TEXT PRIMARY KEY,
+	revision   INTEGER NOT NULL,
+	data       BLOB NOT NULL,
+	details    BLOB NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS environment_revisions (
+	environment_id TEXT NOT NULL,
+	revision       INTEGER NOT NULL,
+	data           BLOB NOT NULL,
+	created_at     TEXT NOT NULL,
+	PRIMARY KEY (environment_id, revision)
+);
+`
+
+// SQLite stores environments in an embedded SQLite database file. The latest
+// definition lives in the environments table and every revision, including
+// the latest, in environment_revisions.
+type SQLite struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLite opens (creating if necessary) the database at path and applies
+// the schema. Use ":memory:" for a throwaway database.
+func NewSQLite(path string) (*SQLite, error) {
+	if path == "" {
+		return nil, errors.New("sqlite store requires a database path")
+	}
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	// SQLite serialises writers anyway; a single connection avoids
+	// SQLITE_BUSY errors and keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("apply sqlite schema: %w", err)
+	}
+	return &SQLite{db: db, now: utcNow}, nil
+}
+
+// Create stores data as revision 1 of a newly generated ID.
+func (s *SQLite) Create(ctx context.Context, data json.RawMessage) (Record, error) {
+	id, err := NewID()
+	if err != nil {
+		return Record{}, err
+	}
+
+	now := s.now()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO environments (id, revision, data, details, created_at, updated_at) VALUES (?, 1, ?, ?, ?, ?)`,
+			id, []byte(data), encodeDetails(data), formatTime(now), formatTime(now)); err != nil {
+			return fmt.Errorf("insert environment: %w", err)
+		}
+		return insertRevision(ctx, tx, id, 1, data, now)
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{ID: id, Revision: 1, Data: clone(data), CreatedAt: now, UpdatedAt: now}, nil
+}
+
+// Get returns the environment stored under id.
+func (s *SQLite) Get(ctx context.Context, id string) (Record, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, revision, data, created_at, updated_at FROM environments WHERE id = ?`, id)
+	rec, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNotFound
+	}
+	return rec, err
+}
+
+// List returns every stored environment ordered by creation time.
+func (s *SQLite) List(ctx context.Context) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, revision, data, created_at, updated_at FROM environments ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("query environments: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query environments: %w", err)
+	}
+	// Text timestamps sort lexically only at equal precision, so re-sort to
+	// match the other backends exactly.
+	sortRecords(out)
+	return out, nil
+}
+
+// Summaries returns the summary of every stored environment ordered by
+// creation time. Definitions are not read.
+func (s *SQLite) Summaries(ctx context.Context) ([]Summary, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, revision, details, created_at, updated_at FROM environments ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("query environments: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Summary
+	for rows.Next() {
+		// A summary has the columns of a record, with details for data
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		sum := Summary{ID: rec.ID, Revision: rec.Revision, CreatedAt: rec.CreatedAt, UpdatedAt: rec.UpdatedAt}
+		if err := json.Unmarshal(rec.Data, &sum.Details); err != nil {
+			return nil, fmt.Errorf("decode details of %s: %w", rec.ID, err)
+		}
+		out = append(out, sum)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query environments: %w", err)
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+// Update stores data as the next revision of id.
+func (s *SQLite) Update(ctx context.Context, id string, data json.RawMessage, pre Precondition) (Record, error) {
+	now := s.now()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := getTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := pre.check(current); err != nil {
+			return err
+		}
+
+		revision := current.Revision + 1
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE environments SET revision = ?, data = ?, details = ?, updated_at = ? WHERE id = ?`,
+			revision, []byte(data), encodeDetails(data), formatTime(now), id); err != nil {
+			return fmt.Errorf("update environment: %w", err)
+		}
+		return insertRevision(ctx, tx, id, revision, data, now)
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Revisions lists every revision of id, oldest first.
+func (s *SQLite) Revisions(ctx context.Context, id string) ([]Revision, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT environment_id, revision, data, created_at FROM environment_revisions
+		WHERE environment_id = ? ORDER BY revision`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Revision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	return out, nil
+}
+
+// Revision returns revision number of id.
+func (s *SQLite) Revision(ctx context.Context, id string, number int) (Revision, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT environment_id, revision, data, created_at FROM environment_revisions
+		WHERE environment_id = ? AND revision = ?`, id, number)
+	rev, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.Get(ctx, id); err != nil {
+			return Revision{}, err
+		}
+		return Revision{}, ErrRevisionNotFound
+	}
+	return rev, err
+}
+
+// Delete removes the environment stored under id.
+func (s *SQLite) Delete(ctx context.Context, id string, pre Precondition) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := getTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := pre.check(current); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM environments WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("delete environment: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM environment_revisions WHERE environment_id = ?`, id); err != nil {
+			return fmt.Errorf("delete revisions: %w", err)
+		}
+		return nil
+	})
+}
+
+// Close closes the underlying database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// inTx runs fn in a transaction, committing if it returns nil.
+func (s *SQLite) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// getTx reads the current record of id inside tx.
+func getTx(ctx context.Context, tx *sql.Tx, id string) (Record, error) {
+	row := tx.QueryRowContext(ctx,
+		`SELECT id, revision, data, created_at, updated_at FROM environments WHERE id = ?`, id)
+	rec, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("read environment: %w", err)
+	}
+	return rec, nil
+}
+
+// encodeDetails returns the details column of a definition.
+func encodeDetails(data json.RawMessage) []byte {
+	out, _ := json.Marshal(readDetails(data))
+	return out
+}
+
+func insertRevision(ctx context.Context, tx *sql.Tx, id string, number int, data json.RawMessage, created time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO environment_revisions (environment_id, revision, data, created_at) VALUES (?, ?, ?, ?)`,
+		id, number, []byte(data), formatTime(created))
+	if err != nil {
+		return fmt.Errorf("insert revision: %w", err)
+	}
+	return nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row scanner) (Record, error) {
+	var (
+		rec              Record
+		data             []byte
+		created, updated string
+	)
+	if err := row.Scan(&rec.ID, &rec.Revision, &data, &created, &updated); err != nil {
+		return Record{}, err
+	}
+
+	var err error
+	if rec.CreatedAt, err = parseTime(created); err != nil {
+		return Record{}, fmt.Errorf("parse created_at for %s: %w", rec.ID, err)
+	}
+	if rec.UpdatedAt, err = parseTime(updated); err != nil {
+		return Record{}, fmt.Errorf("parse updated_at for %s: %w", rec.ID, err)
+	}
+	rec.Data = data
+	return rec, nil
+}
+
+func scanRevision(row scanner) (Revision, error) {
+	var (
+		rev     Revision
+		data    []byte
+		created string
+	)
+	if err := row.Scan(&rev.EnvironmentID, &rev.Number, &data, &created); err != nil {
+		return Revision{}, err
+	}
+
+	var err error
+	if rev.CreatedAt, err = parseTime(created); err != nil {
+		return Revision{}, fmt.Errorf("parse created_at for %s revision %d: %w", rev.EnvironmentID, rev.Number, err)
+	}
+	rev.Data = data
+	return rev, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
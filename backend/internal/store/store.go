@@ -0,0 +1,168 @@
+// Package store persists environment definitions submitted to the API.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no environment exists for the requested ID.
+	ErrNotFound = errors.New("environment not found")
+	// ErrRevisionNotFound is returned when the environment exists but the
+	// requested revision does not.
+	ErrRevisionNotFound = errors.New("revision not found")
+	// ErrPreconditionFailed is returned when a conditional write is rejected
+	// because the stored environment no longer matches its Precondition.
+	ErrPreconditionFailed = errors.New("environment has been modified")
+)
+
+// Precondition decides whether a conditional write may go ahead given the
+// current record. Stores evaluate it under the same lock or transaction as the
+// write, so the check and the write are atomic. A nil Precondition always
+// passes. It must not retain current.Data.
+type Precondition func(current Record) bool
+
+// check returns ErrPreconditionFailed unless p is nil or accepts current.
+func (p Precondition) check(current Record) error {
+	if p != nil && !p(current) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// Backend names accepted by Open.
+const (
+	BackendMemory = "memory"
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+// EnvironmentStore persists environment definitions. Every write creates a
+// new immutable revision; revisions are numbered from 1 and the record always
+// reflects the latest one. Implementations must be safe for concurrent use and
+// must not retain or share the byte slices passed in or handed out.
+type EnvironmentStore interface {
+	// Create stores data as revision 1 of a newly generated ID.
+	Create(ctx context.Context, data json.RawMessage) (Record, error)
+	// Get returns the environment stored under id, or ErrNotFound.
+	Get(ctx context.Context, id string) (Record, error)
+	// List returns every stored environment ordered by creation time.
+	List(ctx context.Context) ([]Record, error)
+	// Summaries returns the summary of every stored environment ordered by
+	// creation time, without loading their definitions.
+	Summaries(ctx context.Context) ([]Summary, error)
+	// Update stores data as the next revision of id, or returns ErrNotFound.
+	// If pre rejects the current record it returns ErrPreconditionFailed.
+	Update(ctx context.Context, id string, data json.RawMessage, pre Precondition) (Record, error)
+	// Revisions lists every revision of id, oldest first, or returns
+	// ErrNotFound.
+	Revisions(ctx context.Context, id string) ([]Revision, error)
+	// Revision returns revision number of id. It returns ErrNotFound if the
+	// environment does not exist and ErrRevisionNotFound if the revision
+	// does not.
+	Revision(ctx context.Context, id string, number int) (Revision, error)
+	// Delete removes the environment stored under id together with all of
+	// its revisions, or returns ErrNotFound. If pre rejects the current
+	// record it returns ErrPreconditionFailed.
+	Delete(ctx context.Context, id string, pre Precondition) error
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// Open returns the store implementation named by backend. path is the
+// directory for the file backend and the database file for SQLite; it is
+// ignored by the memory backend.
+func Open(backend, path string) (EnvironmentStore, error) {
+	switch backend {
+	case "", BackendMemory:
+		return NewMemory(), nil
+	case BackendFile:
+		return NewFile(path)
+	case BackendSQLite:
+		return NewSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown environment store backend %q", backend)
+	}
+}
+
+// Record is a stored environment definition together with its bookkeeping
+// fields. Data and Revision describe the latest revision.
+type Record struct {
+	ID        string          `json:"id"`
+	Revision  int             `json:"revision"`
+	Data      json.RawMessage `json:"-"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Revision is one immutable version of an environment definition.
+type Revision struct {
+	EnvironmentID string          `json:"environment_id"`
+	Number        int             `json:"revision"`
+	Data          json.RawMessage `json:"-"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// NewID returns a random identifier in the canonical UUID v4 text form.
+func NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	s := hex.EncodeToString(b[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32], nil
+}
+
+// idPattern matches the IDs produced by NewID. Backends that derive file
+// names from IDs use it to reject anything that could escape their root.
+var idPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// validID reports whether id has the shape of an ID produced by NewID.
+func validID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
+
+// sortRecords orders records by creation time, breaking ties by ID so that
+// listings are stable.
+func sortRecords(records []Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.Before(records[j].CreatedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+}
+
+func copyRevision(rev Revision) Revision {
+	rev.Data = clone(rev.Data)
+	return rev
+}
+
+func copyRecord(rec Record) Record {
+	rec.Data = clone(rec.Data)
+	return rec
+}
+
+func clone(data json.RawMessage) json.RawMessage {
+	if data == nil {
+		return nil
+	}
+	out := make(json.RawMessage, len(data))
+	copy(out, data)
+	return out
+}
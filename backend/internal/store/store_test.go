@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := NewID()
+		require.NoError(t, err)
+		assert.Regexp(t, pattern, id)
+		assert.False(t, seen[id], "IDs should be unique")
+		seen[id] = true
+	}
+}
+
+// backends returns a fresh instance of every store implementation
+func backends(t *testing.T) map[string]EnvironmentStore {
+	t.Helper()
+
+	file, err := NewFile(filepath.Join(t.TempDir(), "environments"))
+	require.NoError(t, err)
+	sqlite, err := NewSQLite(filepath.Join(t.TempDir(), "environments.db"))
+	require.NoError(t, err)
+
+	stores := map[string]EnvironmentStore{
+		BackendMemory: NewMemory(),
+		BackendFile:   file,
+		BackendSQLite: sqlite,
+	}
+	t.Cleanup(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	})
+	return stores
+}
+
+func TestEnvironmentStore(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rec, err := s.Create(ctx, json.RawMessage(`{"a":1}`))
+			require.NoError(t, err)
+			assert.NotEmpty(t, rec.ID)
+			assert.True(t, rec.CreatedAt.Equal(rec.UpdatedAt))
+
+			got, err := s.Get(ctx, rec.ID)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"a":1}`, string(got.Data))
+			assert.True(t, rec.CreatedAt.Equal(got.CreatedAt))
+
+			// Mutating a returned record must not affect the stored copy
+			got.Data[1] = 'b'
+			again, err := s.Get(ctx, rec.ID)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"a":1}`, string(again.Data))
+
+			second, err := s.Create(ctx, json.RawMessage(`{"b":2}`))
+			require.NoError(t, err)
+
+			list, err := s.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, rec.ID, list[0].ID, "listing should be ordered by creation time")
+			assert.Equal(t, second.ID, list[1].ID)
+
+			updated, err := s.Update(ctx, rec.ID, json.RawMessage(`{"a":2}`), nil)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"a":2}`, string(updated.Data))
+			assert.True(t, rec.CreatedAt.Equal(updated.CreatedAt))
+			assert.False(t, updated.UpdatedAt.Before(rec.UpdatedAt))
+
+			require.NoError(t, s.Delete(ctx, rec.ID, nil))
+			_, err = s.Get(ctx, rec.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			list, err = s.List(ctx)
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+		})
+	}
+}
+
+func TestEnvironmentStoreSummaries(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rec, err := s.Create(ctx, json.RawMessage(`{"metadata":{"name":"reef","tags":["sea"]},"map":{"width":4,"height":3,"tiles":[{},{}]},"agents":[{}]}`))
+			require.NoError(t, err)
+			broken, err := s.Create(ctx, json.RawMessage(`{"map":"not a map"}`))
+			require.NoError(t, err)
+
+			sums, err := s.Summaries(ctx)
+			require.NoError(t, err)
+			require.Len(t, sums, 2)
+			assert.Equal(t, rec.ID, sums[0].ID)
+			assert.Equal(t, 1, sums[0].Revision)
+			assert.True(t, rec.CreatedAt.Equal(sums[0].CreatedAt))
+			assert.Equal(t, Details{Name: "reef", Tags: []string{"sea"}, Width: 4, Height: 3, TileCount: 2, AgentCount: 1}, sums[0].Details)
+			assert.Equal(t, broken.ID, sums[1].ID)
+			assert.Equal(t, Details{}, sums[1].Details, "definitions that do not decode have no details")
+
+			_, err = s.Update(ctx, rec.ID, json.RawMessage(`{"metadata":{"name":"atoll"},"map":{"width":8,"height":8}}`), nil)
+			require.NoError(t, err)
+			sums, err = s.Summaries(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, sums[0].Revision)
+			assert.Equal(t, Details{Name: "atoll", Width: 8, Height: 8}, sums[0].Details, "details follow the latest revision")
+
+			require.NoError(t, s.Delete(ctx, rec.ID, nil))
+			sums, err = s.Summaries(ctx)
+			require.NoError(t, err)
+			require.Len(t, sums, 1)
+			assert.Equal(t, broken.ID, sums[0].ID)
+		})
+	}
+}
+
+func TestEnvironmentStoreRevisions(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rec, err := s.Create(ctx, json.RawMessage(`{"v":1}`))
+			require.NoError(t, err)
+			assert.Equal(t, 1, rec.Revision)
+
+			for v := 2; v <= 3; v++ {
+				rec, err = s.Update(ctx, rec.ID, json.RawMessage(`{"v":`+string(rune('0'+v))+`}`), nil)
+				require.NoError(t, err)
+				assert.Equal(t, v, rec.Revision)
+			}
+
+			got, err := s.Get(ctx, rec.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 3, got.Revision)
+			assert.JSONEq(t, `{"v":3}`, string(got.Data))
+
+			revs, err := s.Revisions(ctx, rec.ID)
+			require.NoError(t, err)
+			require.Len(t, revs, 3)
+			for i, rev := range revs {
+				assert.Equal(t, i+1, rev.Number)
+				assert.Equal(t, rec.ID, rev.EnvironmentID)
+				assert.JSONEq(t, `{"v":`+string(rune('1'+i))+`}`, string(rev.Data))
+			}
+			assert.True(t, revs[2].CreatedAt.Equal(got.UpdatedAt))
+
+			first, err := s.Revision(ctx, rec.ID, 1)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"v":1}`, string(first.Data))
+
+			_, err = s.Revision(ctx, rec.ID, 4)
+			assert.ErrorIs(t, err, ErrRevisionNotFound)
+			_, err = s.Revision(ctx, rec.ID, 0)
+			assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+			require.NoError(t, s.Delete(ctx, rec.ID, nil))
+			_, err = s.Revisions(ctx, rec.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = s.Revision(ctx, rec.ID, 1)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestEnvironmentStorePrecondition(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			rec, err := s.Create(ctx, json.RawMessage(`{"v":1}`))
+			require.NoError(t, err)
+
+			atRevision := func(n int) Precondition {
+				return func(current Record) bool { return current.Revision == n }
+			}
+
+			_, err = s.Update(ctx, rec.ID, json.RawMessage(`{"v":2}`), atRevision(2))
+			assert.ErrorIs(t, err, ErrPreconditionFailed)
+			assert.ErrorIs(t, s.Delete(ctx, rec.ID, atRevision(2)), ErrPreconditionFailed)
+
+			// A rejected write leaves the record untouched
+			got, err := s.Get(ctx, rec.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, got.Revision)
+			assert.JSONEq(t, `{"v":1}`, string(got.Data))
+
+			var seen string
+			updated, err := s.Update(ctx, rec.ID, json.RawMessage(`{"v":2}`), func(current Record) bool {
+				seen = string(current.Data)
+				return current.Revision == 1
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 2, updated.Revision)
+			assert.JSONEq(t, `{"v":1}`, seen, "precondition should see the current data")
+
+			require.NoError(t, s.Delete(ctx, rec.ID, atRevision(2)))
+		})
+	}
+}
+
+func TestEnvironmentStoreNotFound(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, id := range []string{"missing", "00000000-0000-4000-8000-000000000000", "../etc"} {
+				_, err := s.Get(ctx, id)
+				assert.ErrorIs(t, err, ErrNotFound)
+				_, err = s.Update(ctx, id, json.RawMessage(`{}`), nil)
+				assert.ErrorIs(t, err, ErrNotFound)
+				assert.ErrorIs(t, s.Delete(ctx, id, nil), ErrNotFound)
+				_, err = s.Revisions(ctx, id)
+				assert.ErrorIs(t, err, ErrNotFound)
+				_, err = s.Revision(ctx, id, 1)
+				assert.ErrorIs(t, err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	first, err := NewFile(root)
+	require.NoError(t, err)
+	rec, err := first.Create(ctx, json.RawMessage(`{"a":1}`))
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(root, rec.ID, "revisions", "1.json"))
+	assert.FileExists(t, filepath.Join(root, rec.ID, "meta.json"))
+
+	// Stray files and half-written directories are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README"), []byte("x"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "00000000-0000-4000-8000-000000000000"), 0o755))
+
+	second, err := NewFile(root)
+	require.NoError(t, err)
+	list, err := second.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, rec.ID, list[0].ID)
+}
+
+func TestFileStoreInterruptedUpdate(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := NewFile(root)
+	require.NoError(t, err)
+	rec, err := s.Create(ctx, json.RawMessage(`{"v":1}`))
+	require.NoError(t, err)
+
+	// A crash after the next revision is written but before meta.json
+	// leaves the previous revision current
+	require.NoError(t, os.WriteFile(filepath.Join(root, rec.ID, "revisions", "2.json"), []byte(`{"v":2}`), 0o644))
+	got, err := s.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Revision)
+	assert.JSONEq(t, `{"v":1}`, string(got.Data))
+
+	updated, err := s.Update(ctx, rec.ID, json.RawMessage(`{"v":3}`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+	got, err = s.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":3}`, string(got.Data), "the next update replaces the stray revision")
+}
+
+func TestSQLiteStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "environments.db")
+
+	first, err := NewSQLite(path)
+	require.NoError(t, err)
+	rec, err := first.Create(ctx, json.RawMessage(`{"a":1}`))
+	require.NoError(t, err)
+	require.NoError(t, first.Close())
+
+	second, err := NewSQLite(path)
+	require.NoError(t, err)
+	defer second.Close()
+	got, err := second.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":1}`, string(got.Data))
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		backend string
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{backend: "", want: &Memory{}},
+		{backend: BackendMemory, want: &Memory{}},
+		{backend: BackendFile, path: t.TempDir(), want: &File{}},
+		{backend: BackendSQLite, path: filepath.Join(t.TempDir(), "env.db"), want: &SQLite{}},
+		{backend: BackendFile, path: "", wantErr: true},
+		{backend: BackendSQLite, path: "", wantErr: true},
+		{backend: "postgres", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			s, err := Open(tt.backend, tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer s.Close()
+			assert.IsType(t, tt.want, s)
+		})
+	}
+}
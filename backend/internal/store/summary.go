@@ -0,0 +1,78 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Summary is the listing form of a stored environment: its bookkeeping
+// fields and the Details of its latest definition.
+type Summary struct {
+	ID        string
+	Revision  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Details
+}
+
+// Details are what listings show of a definition. Stores read them when a
+// revision is written and keep them beside the record, so listing never
+// loads whole definitions.
+type Details struct {
+	Name        string   `json:"name,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	TileCount   int      `json:"tile_count"`
+	ObjectCount int      `json:"object_count"`
+	AgentCount  int      `json:"agent_count"`
+}
+
+// readDetails reads the Details of a definition. A definition that does not
+// decode has none.
+func readDetails(data json.RawMessage) Details {
+	var doc struct {
+		Metadata struct {
+			Name   string   `json:"name"`
+			Author string   `json:"author"`
+			Tags   []string `json:"tags"`
+		} `json:"metadata"`
+		Map struct {
+			Width  int               `json:"width"`
+			Height int               `json:"height"`
+			Tiles  []json.RawMessage `json:"tiles"`
+		} `json:"map"`
+		Objects []json.RawMessage `json:"objects"`
+		Agents  []json.RawMessage `json:"agents"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Details{}
+	}
+	return Details{
+		Name:        doc.Metadata.Name,
+		Author:      doc.Metadata.Author,
+		Tags:        doc.Metadata.Tags,
+		Width:       doc.Map.Width,
+		Height:      doc.Map.Height,
+		TileCount:   len(doc.Map.Tiles),
+		ObjectCount: len(doc.Objects),
+		AgentCount:  len(doc.Agents),
+	}
+}
+
+// sortSummaries orders summaries like sortRecords.
+func sortSummaries(summaries []Summary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].CreatedAt.Equal(summaries[j].CreatedAt) {
+			return summaries[i].CreatedAt.Before(summaries[j].CreatedAt)
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+}
+
+func copySummary(sum Summary) Summary {
+	sum.Tags = append([]string(nil), sum.Tags...)
+	return sum
+}
@@ -0,0 +1,106 @@
+package world
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYAMLToJSONMatchesJSONExample(t *testing.T) {
+	fromYAML, err := YAMLToJSON(readTestdata(t, "environment.yaml"))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(readTestdata(t, "environment.json")), string(fromYAML))
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{name: "scalars", yaml: "a: 1\nb: 1.5\nc: true\nd: null\ne: text", want: `{"a":1,"b":1.5,"c":true,"d":null,"e":"text"}`},
+		{name: "non-string keys", yaml: "1: one\ntrue: yes", want: `{"1":"one","true":"yes"}`},
+		{name: "unquoted timestamp", yaml: "created: 2025-06-21T12:00:00Z", want: `{"created":"2025-06-21T12:00:00Z"}`},
+		{name: "nested", yaml: "a:\n  - b: [1, 2]", want: `{"a":[{"b":[1,2]}]}`},
+		{name: "empty", yaml: "", wantErr: "empty YAML document"},
+		{name: "multiple documents", yaml: "a: 1\n---\nb: 2", wantErr: "expected a single YAML document"},
+		{name: "infinity", yaml: "a: .inf", wantErr: "cannot be represented in JSON"},
+		{name: "syntax", yaml: "a: [1, 2", wantErr: "yaml:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := YAMLToJSON([]byte(tt.yaml))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestJSONToYAML(t *testing.T) {
+	got, err := JSONToYAML([]byte(`{"b":1,"a":[1.5,"x",true,null],"c":{}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "b: 1\na:\n  - 1.5\n  - x\n  - true\n  - null\nc: {}\n", string(got),
+		"keys should keep their original order")
+
+	_, err = JSONToYAML([]byte(`{"a":`))
+	assert.Error(t, err)
+}
+
+func TestJSONToYAMLRoundTrip(t *testing.T) {
+	original := readTestdata(t, "environment.json")
+
+	asYAML, err := JSONToYAML(original)
+	require.NoError(t, err)
+	back, err := YAMLToJSON(asYAML)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(original), string(back))
+
+	// Strings that look like other YAML types must stay strings
+	asYAML, err = JSONToYAML([]byte(`{"v":"0.1.0","flag":"true","n":"12"}`))
+	require.NoError(t, err)
+	back, err = YAMLToJSON(asYAML)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":"0.1.0","flag":"true","n":"12"}`, string(back))
+}
+
+func TestLoadFile(t *testing.T) {
+	fromJSON, err := LoadFile("testdata/environment.json")
+	require.NoError(t, err)
+	fromYAML, err := LoadFile("testdata/environment.yaml")
+	require.NoError(t, err)
+
+	a, _ := json.Marshal(fromJSON)
+	b, _ := json.Marshal(fromYAML)
+	assert.JSONEq(t, string(a), string(b))
+
+	dir := t.TempDir()
+	txt := filepath.Join(dir, "env.txt")
+	require.NoError(t, os.WriteFile(txt, []byte("{}"), 0o644))
+	_, err = LoadFile(txt)
+	assert.ErrorContains(t, err, "unsupported environment file extension")
+
+	bad := filepath.Join(dir, "env.yml")
+	require.NoError(t, os.WriteFile(bad, []byte("map: ["), 0o644))
+	_, err = LoadFile(bad)
+	assert.ErrorContains(t, err, "parse")
+
+	invalid := filepath.Join(dir, "env.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("map: {}"), 0o644))
+	_, err = LoadFile(invalid)
+	var verr *ValidationError
+	assert.ErrorAs(t, err, &verr)
+
+	_, err = LoadFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
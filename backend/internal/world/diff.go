@@ -0,0 +1,274 @@
+package world
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Diff is a structural comparison of two environment definitions. Tiles are
+// matched by coordinate and objects and agents by ID, so reordering entries
+// is not reported as a change.
+type Diff struct {
+	Metadata []FieldChange `json:"metadata"`
+	Map      []FieldChange `json:"map"`
+	Tiles    TileDiff      `json:"tiles"`
+	Objects  EntityDiff    `json:"objects"`
+	Agents   EntityDiff    `json:"agents"`
+	// Simulation lists changes to the simulation profile and to each
+	// override, named like overrides.physics.wind.speed.
+	Simulation []FieldChange `json:"simulation"`
+}
+
+// FieldChange records a field whose value differs between the two sides.
+// A nil From or To means the field is absent on that side.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// TileDiff lists tiles added, removed or changed in place.
+type TileDiff struct {
+	Added   []EnvironmentSchemaJsonMapTilesElem `json:"added"`
+	Removed []EnvironmentSchemaJsonMapTilesElem `json:"removed"`
+	Changed []TileChange                        `json:"changed"`
+}
+
+// TileChange is a tile whose properties differ at the same coordinate.
+type TileChange struct {
+	X      int           `json:"x"`
+	Y      int           `json:"y"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// EntityDiff lists objects or agents added, removed, moved or otherwise
+// changed. An entity that both moved and changed appears in both lists.
+type EntityDiff struct {
+	Added   []string       `json:"added"`
+	Removed []string       `json:"removed"`
+	Moved   []EntityMove   `json:"moved"`
+	Changed []EntityChange `json:"changed"`
+}
+
+// Position is a point in map space.
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// EntityMove records an entity whose position changed.
+type EntityMove struct {
+	ID   string   `json:"id"`
+	From Position `json:"from"`
+	To   Position `json:"to"`
+}
+
+// EntityChange records an entity whose non-positional fields changed.
+type EntityChange struct {
+	ID     string        `json:"id"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// Empty reports whether the diff contains no changes.
+func (d Diff) Empty() bool {
+	return len(d.Metadata) == 0 && len(d.Map) == 0 &&
+		len(d.Tiles.Added) == 0 && len(d.Tiles.Removed) == 0 && len(d.Tiles.Changed) == 0 &&
+		d.Objects.empty() && d.Agents.empty() && len(d.Simulation) == 0
+}
+
+func (d EntityDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Moved) == 0 && len(d.Changed) == 0
+}
+
+// Compare returns the structural differences going from a to b.
+func Compare(a, b *EnvironmentSchemaJson) Diff {
+	d := Diff{
+		Metadata: fieldChanges(a.Metadata, b.Metadata),
+		Map: fieldChanges(
+			mapDimensions{a.Map.Width, a.Map.Height, a.Map.TileSize},
+			mapDimensions{b.Map.Width, b.Map.Height, b.Map.TileSize},
+		),
+		Tiles:      compareTiles(a.Map.Tiles, b.Map.Tiles),
+		Simulation: fieldChanges(simulationFields(a.Simulation), simulationFields(b.Simulation)),
+	}
+
+	objects := func(env *EnvironmentSchemaJson) map[string]entity {
+		out := make(map[string]entity, len(env.Objects))
+		for _, o := range env.Objects {
+			out[o.Id] = entity{pos: Position{o.Position.X, o.Position.Y, o.Position.Z}, value: o}
+		}
+		return out
+	}
+	agents := func(env *EnvironmentSchemaJson) map[string]entity {
+		out := make(map[string]entity, len(env.Agents))
+		for _, ag := range env.Agents {
+			out[ag.Id] = entity{pos: Position{ag.Position.X, ag.Position.Y, ag.Position.Z}, value: ag}
+		}
+		return out
+	}
+	d.Objects = compareEntities(objects(a), objects(b))
+	d.Agents = compareEntities(agents(a), agents(b))
+	return d
+}
+
+// mapDimensions holds the scalar map fields compared by Compare.
+type mapDimensions struct {
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	TileSize float64 `json:"tileSize"`
+}
+
+// simulationFields flattens the simulation section so each override is
+// compared on its own.
+func simulationFields(s *EnvironmentSchemaJsonSimulation) map[string]interface{} {
+	out := make(map[string]interface{})
+	if s == nil {
+		return out
+	}
+	if s.Profile != nil {
+		out["profile"] = *s.Profile
+	}
+	for key, v := range s.Overrides {
+		out["overrides."+key] = v
+	}
+	return out
+}
+
+// entity is an object or agent reduced to what compareEntities needs.
+type entity struct {
+	pos   Position
+	value interface{}
+}
+
+func compareTiles(a, b []EnvironmentSchemaJsonMapTilesElem) TileDiff {
+	index := func(tiles []EnvironmentSchemaJsonMapTilesElem) map[[2]int]EnvironmentSchemaJsonMapTilesElem {
+		out := make(map[[2]int]EnvironmentSchemaJsonMapTilesElem, len(tiles))
+		for _, t := range tiles {
+			out[[2]int{t.X, t.Y}] = t
+		}
+		return out
+	}
+	before, after := index(a), index(b)
+
+	d := TileDiff{
+		Added:   []EnvironmentSchemaJsonMapTilesElem{},
+		Removed: []EnvironmentSchemaJsonMapTilesElem{},
+		Changed: []TileChange{},
+	}
+	for cell, old := range before {
+		tile, ok := after[cell]
+		if !ok {
+			d.Removed = append(d.Removed, old)
+			continue
+		}
+		if fields := fieldChanges(old, tile); len(fields) > 0 {
+			d.Changed = append(d.Changed, TileChange{X: cell[0], Y: cell[1], Fields: fields})
+		}
+	}
+	for cell, tile := range after {
+		if _, ok := before[cell]; !ok {
+			d.Added = append(d.Added, tile)
+		}
+	}
+
+	sortTiles(d.Added)
+	sortTiles(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return tileLess(d.Changed[i].X, d.Changed[i].Y, d.Changed[j].X, d.Changed[j].Y)
+	})
+	return d
+}
+
+func compareEntities(before, after map[string]entity) EntityDiff {
+	d := EntityDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Moved:   []EntityMove{},
+		Changed: []EntityChange{},
+	}
+	for id, old := range before {
+		cur, ok := after[id]
+		if !ok {
+			d.Removed = append(d.Removed, id)
+			continue
+		}
+		if old.pos != cur.pos {
+			d.Moved = append(d.Moved, EntityMove{ID: id, From: old.pos, To: cur.pos})
+		}
+		fields := fieldChanges(old.value, cur.value)
+		kept := fields[:0]
+		for _, f := range fields {
+			if f.Field != "position" {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) > 0 {
+			d.Changed = append(d.Changed, EntityChange{ID: id, Fields: kept})
+		}
+	}
+	for id := range after {
+		if _, ok := before[id]; !ok {
+			d.Added = append(d.Added, id)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Moved, func(i, j int) bool { return d.Moved[i].ID < d.Moved[j].ID })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].ID < d.Changed[j].ID })
+	return d
+}
+
+// fieldChanges compares the JSON representations of a and b field by field.
+// Comparing through JSON treats nil and empty pointers and maps the same way
+// the stored documents do.
+func fieldChanges(a, b interface{}) []FieldChange {
+	before, after := jsonFields(a), jsonFields(b)
+
+	names := make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		names[k] = true
+	}
+	for k := range after {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	out := []FieldChange{}
+	for _, k := range sorted {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			out = append(out, FieldChange{Field: k, From: before[k], To: after[k]})
+		}
+	}
+	return out
+}
+
+func jsonFields(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func sortTiles(tiles []EnvironmentSchemaJsonMapTilesElem) {
+	sort.Slice(tiles, func(i, j int) bool {
+		return tileLess(tiles[i].X, tiles[i].Y, tiles[j].X, tiles[j].Y)
+	})
+}
+
+// tileLess orders tiles row by row.
+func tileLess(ax, ay, bx, by int) bool {
+	if ay != by {
+		return ay < by
+	}
+	return ax < bx
+}
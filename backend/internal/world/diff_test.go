@@ -0,0 +1,68 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeForDiff(t *testing.T, doc string) *EnvironmentSchemaJson {
+	t.Helper()
+	env, err := DecodeEnvironment([]byte(doc))
+	require.NoError(t, err)
+	return env
+}
+
+func TestCompareIdentical(t *testing.T) {
+	env := decodeForDiff(t, string(readTestdata(t, "environment.json")))
+	assert.True(t, Compare(env, env).Empty())
+}
+
+func TestCompareIgnoresOrder(t *testing.T) {
+	a := decodeForDiff(t, `{"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"grass"},{"x":1,"y":0,"type":"dirt"}]},
+		"objects":[{"id":"a","model":"rock","position":{"x":0,"y":0}},{"id":"b","model":"rock","position":{"x":1,"y":1}}],"agents":[]}`)
+	b := decodeForDiff(t, `{"map":{"width":2,"height":2,"tiles":[{"x":1,"y":0,"type":"dirt"},{"x":0,"y":0,"type":"grass"}]},
+		"objects":[{"id":"b","model":"rock","position":{"x":1,"y":1}},{"id":"a","model":"rock","position":{"x":0,"y":0}}],"agents":[]}`)
+	assert.True(t, Compare(a, b).Empty())
+}
+
+func TestCompare(t *testing.T) {
+	a := decodeForDiff(t, `{
+		"metadata":{"name":"before"},
+		"simulation":{"profile":"calm_sea","overrides":{"physics.wind.speed":5,"debug.show_debug_info":true}},
+		"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"grass"},{"x":1,"y":0,"type":"dirt"}]},
+		"objects":[{"id":"rock","model":"rock","position":{"x":0,"y":0}},{"id":"tree","model":"tree","position":{"x":1,"y":1}}],
+		"agents":[{"id":"bob","model":"m","behavior":"idle","position":{"x":0,"y":1}}]}`)
+	b := decodeForDiff(t, `{
+		"metadata":{"name":"after"},
+		"simulation":{"profile":"storm","overrides":{"physics.wind.speed":20,"environment.waves.height":3}},
+		"map":{"width":3,"height":2,"tiles":[{"x":0,"y":0,"type":"stone"},{"x":2,"y":1,"type":"water"}]},
+		"objects":[{"id":"rock","model":"boulder","position":{"x":0,"y":0}},{"id":"bush","model":"bush","position":{"x":2,"y":0}}],
+		"agents":[{"id":"bob","model":"m","behavior":"wander","position":{"x":1,"y":1}}]}`)
+
+	d := Compare(a, b)
+	require.False(t, d.Empty())
+
+	assert.Equal(t, []FieldChange{{Field: "name", From: "before", To: "after"}}, d.Metadata)
+	assert.Equal(t, []FieldChange{{Field: "width", From: float64(2), To: float64(3)}}, d.Map)
+
+	assert.Equal(t, []EnvironmentSchemaJsonMapTilesElem{{X: 2, Y: 1, Type: "water"}}, d.Tiles.Added)
+	assert.Equal(t, []EnvironmentSchemaJsonMapTilesElem{{X: 1, Y: 0, Type: "dirt"}}, d.Tiles.Removed)
+	assert.Equal(t, []TileChange{{X: 0, Y: 0, Fields: []FieldChange{{Field: "type", From: "grass", To: "stone"}}}}, d.Tiles.Changed)
+
+	assert.Equal(t, []string{"bush"}, d.Objects.Added)
+	assert.Equal(t, []string{"tree"}, d.Objects.Removed)
+	assert.Empty(t, d.Objects.Moved)
+	assert.Equal(t, []EntityChange{{ID: "rock", Fields: []FieldChange{{Field: "model", From: "rock", To: "boulder"}}}}, d.Objects.Changed)
+
+	assert.Equal(t, []EntityMove{{ID: "bob", From: Position{X: 0, Y: 1}, To: Position{X: 1, Y: 1}}}, d.Agents.Moved)
+	assert.Equal(t, []EntityChange{{ID: "bob", Fields: []FieldChange{{Field: "behavior", From: "idle", To: "wander"}}}}, d.Agents.Changed)
+
+	assert.Equal(t, []FieldChange{
+		{Field: "overrides.debug.show_debug_info", From: true, To: nil},
+		{Field: "overrides.environment.waves.height", From: nil, To: float64(3)},
+		{Field: "overrides.physics.wind.speed", From: float64(5), To: float64(20)},
+		{Field: "profile", From: "calm_sea", To: "storm"},
+	}, d.Simulation)
+}
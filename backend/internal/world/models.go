@@ -0,0 +1,252 @@
+package world
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Collision shapes a model can declare.
+const (
+	ShapeNone   = "none"
+	ShapeBox    = "box"
+	ShapeSphere = "sphere"
+)
+
+// Box is an axis-aligned bounding box in tile units, relative to the
+// position of the entity using the model.
+type Box struct {
+	Min Vec3 `json:"min"`
+	Max Vec3 `json:"max"`
+}
+
+// Collision is the shape the simulation uses for a model. A box uses the
+// model's bounds; a sphere is centred on the entity's position.
+type Collision struct {
+	Shape  string  `json:"shape"`
+	Radius float64 `json:"radius,omitempty"`
+}
+
+// Model describes a 3D asset that objects and agents refer to by name.
+type Model struct {
+	// Name is the value of objects[].model and agents[].model.
+	Name string `json:"name"`
+	// Asset is the path of the model file under the asset directory.
+	Asset     string    `json:"asset"`
+	Bounds    Box       `json:"bounds"`
+	Collision Collision `json:"collision"`
+	// Mass is in kilograms; zero means immovable.
+	Mass float64 `json:"mass"`
+	// Properties are the defaults for entities using the model. Properties
+	// set on an entity take precedence.
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// ModelRegistry is the set of models environments may use. Models can be
+// added but never changed or removed. It is safe for concurrent use.
+type ModelRegistry struct {
+	mu     sync.RWMutex
+	models map[string]Model
+}
+
+// NewModelRegistry checks a set of models and returns them as a registry.
+func NewModelRegistry(models []Model) (*ModelRegistry, error) {
+	r := &ModelRegistry{models: make(map[string]Model, len(models))}
+	for _, m := range models {
+		if err := r.Register(m); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds m to the registry. An empty asset defaults to the model name
+// and an empty collision shape to none. It fails if m is invalid or its name
+// is taken.
+func (r *ModelRegistry) Register(m Model) error {
+	if err := m.Check(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.models[m.Name]; dup {
+		return fmt.Errorf("model %q is defined twice", m.Name)
+	}
+	r.models[m.Name] = m.withDefaults()
+	return nil
+}
+
+func (m Model) withDefaults() Model {
+	if m.Asset == "" {
+		m.Asset = m.Name
+	}
+	if m.Collision.Shape == "" {
+		m.Collision.Shape = ShapeNone
+	}
+	return m
+}
+
+// Check reports whether m is a usable model definition once Register has
+// filled in its defaults.
+func (m Model) Check() error {
+	if m.Name == "" {
+		return errors.New("model has no name")
+	}
+	if err := m.withDefaults().check(); err != nil {
+		return fmt.Errorf("model %q: %w", m.Name, err)
+	}
+	return nil
+}
+
+func (m Model) check() error {
+	if m.Bounds.Min.X > m.Bounds.Max.X || m.Bounds.Min.Y > m.Bounds.Max.Y || m.Bounds.Min.Z > m.Bounds.Max.Z {
+		return errors.New("bounds min must not exceed max")
+	}
+	if m.Mass < 0 {
+		return errors.New("mass must not be negative")
+	}
+	switch m.Collision.Shape {
+	case ShapeNone, ShapeBox:
+	case ShapeSphere:
+		if m.Collision.Radius <= 0 {
+			return errors.New("sphere collision needs a positive radius")
+		}
+	default:
+		return fmt.Errorf("collision shape must be %s, %s or %s, got %q", ShapeNone, ShapeBox, ShapeSphere, m.Collision.Shape)
+	}
+	return nil
+}
+
+// modelFile is the layout of a model manifest.
+type modelFile struct {
+	Models map[string]modelEntry `yaml:"models"`
+}
+
+type modelEntry struct {
+	Asset  string `yaml:"asset,omitempty"`
+	Bounds struct {
+		Min Vec3 `yaml:"min"`
+		Max Vec3 `yaml:"max"`
+	} `yaml:"bounds"`
+	Collision struct {
+		Shape  string  `yaml:"shape"`
+		Radius float64 `yaml:"radius,omitempty"`
+	} `yaml:"collision"`
+	Mass       float64                `yaml:"mass"`
+	Properties map[string]interface{} `yaml:"properties,omitempty"`
+}
+
+// ParseModelRegistry reads a model manifest such as configs/models.yaml.
+// Omitted fields default as described for Register.
+func ParseModelRegistry(data []byte) (*ModelRegistry, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var f modelFile
+	if err := dec.Decode(&f); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("empty model manifest")
+		}
+		return nil, err
+	}
+
+	models := make([]Model, 0, len(f.Models))
+	for name, def := range f.Models {
+		m := Model{
+			Name:      name,
+			Asset:     def.Asset,
+			Bounds:    Box{Min: def.Bounds.Min, Max: def.Bounds.Max},
+			Collision: Collision{Shape: def.Collision.Shape, Radius: def.Collision.Radius},
+			Mass:      def.Mass,
+		}
+		if def.Properties != nil {
+			// Nested YAML maps decode with interface{} keys, which JSON
+			// cannot encode
+			props, err := jsonCompatible(def.Properties)
+			if err != nil {
+				return nil, fmt.Errorf("model %q: properties: %w", name, err)
+			}
+			m.Properties = props.(map[string]interface{})
+		}
+		models = append(models, m)
+	}
+	// Sort so that errors are reproducible
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return NewModelRegistry(models)
+}
+
+// MarshalModelManifest writes models in the layout ParseModelRegistry reads.
+func MarshalModelManifest(models []Model) ([]byte, error) {
+	f := modelFile{Models: make(map[string]modelEntry, len(models))}
+	for _, m := range models {
+		var e modelEntry
+		e.Asset = m.Asset
+		e.Bounds.Min, e.Bounds.Max = m.Bounds.Min, m.Bounds.Max
+		e.Collision.Shape, e.Collision.Radius = m.Collision.Shape, m.Collision.Radius
+		e.Mass = m.Mass
+		e.Properties = m.Properties
+		f.Models[m.Name] = e
+	}
+	return yaml.Marshal(f)
+}
+
+// LoadModelRegistry reads the model manifest at path.
+func LoadModelRegistry(path string) (*ModelRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseModelRegistry(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Lookup returns the model called name.
+func (r *ModelRegistry) Lookup(name string) (Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.models[name]
+	return m, ok
+}
+
+// Models returns every model sorted by name.
+func (r *ModelRegistry) Models() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Model, 0, len(r.models))
+	for _, m := range r.models {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Property returns the value of key for an entity using model with its own
+// properties, falling back to the model's defaults.
+func (r *ModelRegistry) Property(model string, properties map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := properties[key]; ok {
+		return v, true
+	}
+	if r == nil {
+		return nil, false
+	}
+	m, _ := r.Lookup(model)
+	v, ok := m.Properties[key]
+	return v, ok
+}
+
+// Collidable reports whether an entity using model with its own properties
+// collides: its "collision" property, or the model's default, is true. It is
+// the one rule validation, pathfinding and line of sight all use.
+func (r *ModelRegistry) Collidable(model string, properties map[string]interface{}) bool {
+	v, _ := r.Property(model, properties, "collision")
+	collision, _ := v.(bool)
+	return collision
+}
@@ -0,0 +1,113 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustModelRegistry(t *testing.T, models []Model) *ModelRegistry {
+	t.Helper()
+	r, err := NewModelRegistry(models)
+	require.NoError(t, err)
+	return r
+}
+
+func TestParseModelRegistry(t *testing.T) {
+	r, err := ParseModelRegistry([]byte(`
+models:
+  crate.glb:
+    bounds: {min: {x: -0.5, y: -0.5, z: 0}, max: {x: 0.5, y: 0.5, z: 1}}
+    collision: {shape: box}
+    mass: 20
+    properties:
+      loot: {gold: 5}
+  marker.glb:
+    asset: markers/flag.glb
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Model{
+		{
+			Name:       "crate.glb",
+			Asset:      "crate.glb",
+			Bounds:     Box{Min: Vec3{X: -0.5, Y: -0.5}, Max: Vec3{X: 0.5, Y: 0.5, Z: 1}},
+			Collision:  Collision{Shape: ShapeBox},
+			Mass:       20,
+			Properties: map[string]interface{}{"loot": map[string]interface{}{"gold": 5}},
+		},
+		{Name: "marker.glb", Asset: "markers/flag.glb", Collision: Collision{Shape: ShapeNone}},
+	}, r.Models())
+}
+
+func TestParseModelRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"empty", ``, "empty model manifest"},
+		{"unknown field", `models: {a: {weight: 1}}`, "field weight not found"},
+		{"inverted bounds", `models: {a: {bounds: {min: {x: 1}, max: {x: 0}}}}`, `model "a": bounds min must not exceed max`},
+		{"negative mass", `models: {a: {mass: -1}}`, `model "a": mass must not be negative`},
+		{"unknown shape", `models: {a: {collision: {shape: cone}}}`, `collision shape must be none, box or sphere, got "cone"`},
+		{"sphere without radius", `models: {a: {collision: {shape: sphere}}}`, "sphere collision needs a positive radius"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseModelRegistry([]byte(tt.doc))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestModelRegistryProperty(t *testing.T) {
+	r := mustModelRegistry(t, []Model{
+		{Name: "rock", Collision: Collision{Shape: ShapeBox}, Properties: map[string]interface{}{"collision": true}},
+	})
+
+	v, ok := r.Property("rock", nil, "collision")
+	assert.True(t, ok)
+	assert.Equal(t, true, v)
+
+	v, ok = r.Property("rock", map[string]interface{}{"collision": false}, "collision")
+	assert.True(t, ok)
+	assert.Equal(t, false, v, "entity properties override model defaults")
+
+	_, ok = r.Property("unknown", nil, "collision")
+	assert.False(t, ok)
+
+	var none *ModelRegistry
+	_, ok = none.Property("rock", nil, "collision")
+	assert.False(t, ok, "a nil registry has no defaults")
+}
+
+func TestModelRegistryCollidable(t *testing.T) {
+	r := mustModelRegistry(t, []Model{
+		{Name: "rock", Collision: Collision{Shape: ShapeBox}, Properties: map[string]interface{}{"collision": true}},
+		{Name: "flag"},
+	})
+
+	assert.True(t, r.Collidable("rock", nil), "model defaults apply")
+	assert.False(t, r.Collidable("rock", map[string]interface{}{"collision": false}))
+	assert.True(t, r.Collidable("flag", map[string]interface{}{"collision": true}))
+	assert.False(t, r.Collidable("flag", nil))
+	assert.False(t, r.Collidable("flag", map[string]interface{}{"collision": "yes"}), "only true collides")
+
+	var none *ModelRegistry
+	assert.False(t, none.Collidable("rock", nil))
+	assert.True(t, none.Collidable("rock", map[string]interface{}{"collision": true}))
+}
+
+func TestShippedModelsCollision(t *testing.T) {
+	models, err := LoadModelRegistry("../../configs/models.yaml")
+	require.NoError(t, err)
+	for _, m := range models.Models() {
+		if m.Collision.Shape == ShapeNone {
+			continue
+		}
+		_, ok := m.Properties["collision"]
+		assert.True(t, ok, "%s has a collision shape but no collision property", m.Name)
+	}
+}
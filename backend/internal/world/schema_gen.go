@@ -18,6 +18,9 @@ type EnvironmentSchemaJson struct {
 
 	// Objects corresponds to the JSON schema field "objects".
 	Objects []EnvironmentSchemaJsonObjectsElem `json:"objects" yaml:"objects" mapstructure:"objects"`
+
+	// Configuration simulations of this environment start from
+	Simulation *EnvironmentSchemaJsonSimulation `json:"simulation,omitempty" yaml:"simulation,omitempty" mapstructure:"simulation,omitempty"`
 }
 
 type EnvironmentSchemaJsonAgentsElem struct {
@@ -183,6 +186,9 @@ func (j *EnvironmentSchemaJsonMap) UnmarshalJSON(value []byte) error {
 	if 1 > plain.Height {
 		return fmt.Errorf("field %s: must be >= %v", "height", 1)
 	}
+	if 4096 < plain.Height {
+		return fmt.Errorf("field %s: must be <= %v", "height", 4096)
+	}
 	if v, ok := raw["tileSize"]; !ok || v == nil {
 		plain.TileSize = 1.0
 	}
@@ -192,6 +198,9 @@ func (j *EnvironmentSchemaJsonMap) UnmarshalJSON(value []byte) error {
 	if 1 > plain.Width {
 		return fmt.Errorf("field %s: must be >= %v", "width", 1)
 	}
+	if 4096 < plain.Width {
+		return fmt.Errorf("field %s: must be <= %v", "width", 4096)
+	}
 	*j = EnvironmentSchemaJsonMap(plain)
 	return nil
 }
@@ -313,6 +322,15 @@ func (j *EnvironmentSchemaJsonObjectsElem) UnmarshalJSON(value []byte) error {
 	return nil
 }
 
+// Configuration simulations of this environment start from
+type EnvironmentSchemaJsonSimulation struct {
+	// Settings applied over the profile, keyed like physics.wind.speed
+	Overrides map[string]interface{} `json:"overrides,omitempty" yaml:"overrides,omitempty" mapstructure:"overrides,omitempty"`
+
+	// Name of a configuration profile, such as storm
+	Profile *string `json:"profile,omitempty" yaml:"profile,omitempty" mapstructure:"profile,omitempty"`
+}
+
 // UnmarshalJSON implements json.Unmarshaler.
 func (j *EnvironmentSchemaJson) UnmarshalJSON(value []byte) error {
 	var raw map[string]interface{}
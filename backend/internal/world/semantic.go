@@ -0,0 +1,151 @@
+package world
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Validator checks environment definitions. The zero value validates against
+// DefaultTiles.
+type Validator struct {
+	// Tiles lists the accepted values of map.tiles[].type.
+	Tiles *TileRegistry
+	// Models lists the accepted values of objects[].model and agents[].model.
+	// When nil, model references are not checked.
+	Models *ModelRegistry
+}
+
+// Validate runs schema validation followed, if the document is structurally
+// sound, by the semantic checks of ValidateEnvironment. It returns the decoded
+// environment (nil when schema validation fails) and every violation found.
+func (v Validator) Validate(data []byte) (*EnvironmentSchemaJson, []Violation) {
+	env, err := DecodeEnvironment(data)
+	if err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			return nil, verr.Violations
+		}
+		return nil, []Violation{{Severity: SeverityError, Rule: "decode", Message: err.Error()}}
+	}
+	return env, v.ValidateEnvironment(env)
+}
+
+// ValidateEnvironment checks the cross-field rules that JSON Schema cannot
+// express: a map of at most MaxMapCells cells, tiles inside the map and
+// unique per cell, known tile types and models, unique entity IDs, agents on
+// the map and not inside collidable objects.
+func (v Validator) ValidateEnvironment(env *EnvironmentSchemaJson) []Violation {
+	var out []Violation
+	add := func(severity Severity, path, rule, format string, args ...interface{}) {
+		out = append(out, Violation{
+			Severity: severity,
+			Path:     path,
+			Rule:     rule,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	tileTypes := v.Tiles
+	if tileTypes == nil {
+		tileTypes = DefaultTiles
+	}
+
+	width, height := env.Map.Width, env.Map.Height
+	if width <= MaxMapDimension && height <= MaxMapDimension && width*height > MaxMapCells {
+		add(SeverityError, "/map", "map_size",
+			"a %dx%d map has more than %d cells", width, height, MaxMapCells)
+	}
+	tiles := make(map[[2]int]int, len(env.Map.Tiles))
+	for i, tile := range env.Map.Tiles {
+		path := "/map/tiles/" + strconv.Itoa(i)
+		if tile.X < 0 || tile.X >= width || tile.Y < 0 || tile.Y >= height {
+			add(SeverityError, path, "bounds",
+				"tile (%d,%d) is outside the %dx%d map", tile.X, tile.Y, width, height)
+		}
+		cell := [2]int{tile.X, tile.Y}
+		if first, dup := tiles[cell]; dup {
+			add(SeverityError, path, "unique",
+				"tile (%d,%d) duplicates /map/tiles/%d", tile.X, tile.Y, first)
+		} else {
+			tiles[cell] = i
+		}
+		if _, known := tileTypes.Lookup(tile.Type); !known {
+			add(SeverityError, path+"/type", "tile_type", "unknown tile type %q", tile.Type)
+		}
+	}
+
+	objectIDs := make(map[string]int, len(env.Objects))
+	var collidable []int
+	for i, obj := range env.Objects {
+		path := "/objects/" + strconv.Itoa(i)
+		if first, dup := objectIDs[obj.Id]; dup {
+			add(SeverityError, path+"/id", "unique",
+				"object id %q duplicates /objects/%d", obj.Id, first)
+		} else {
+			objectIDs[obj.Id] = i
+		}
+		if !onMap(obj.Position.X, obj.Position.Y, width, height) {
+			add(SeverityWarning, path+"/position", "bounds",
+				"object %q at (%g,%g) is outside the %dx%d map", obj.Id, obj.Position.X, obj.Position.Y, width, height)
+		}
+		v.checkModel(path, obj.Model, add)
+		if v.Models.Collidable(obj.Model, obj.Properties) {
+			collidable = append(collidable, i)
+		}
+	}
+
+	agentIDs := make(map[string]int, len(env.Agents))
+	for i, agent := range env.Agents {
+		path := "/agents/" + strconv.Itoa(i)
+		if first, dup := agentIDs[agent.Id]; dup {
+			add(SeverityError, path+"/id", "unique",
+				"agent id %q duplicates /agents/%d", agent.Id, first)
+		} else {
+			agentIDs[agent.Id] = i
+		}
+		v.checkModel(path, agent.Model, add)
+		if obj, shared := objectIDs[agent.Id]; shared {
+			add(SeverityWarning, path+"/id", "unique",
+				"agent id %q is also used by /objects/%d", agent.Id, obj)
+		}
+
+		x, y := agent.Position.X, agent.Position.Y
+		if !onMap(x, y, width, height) {
+			add(SeverityError, path+"/position", "bounds",
+				"agent %q at (%g,%g) is outside the %dx%d map", agent.Id, x, y, width, height)
+			continue
+		}
+		for _, j := range collidable {
+			obj := env.Objects[j]
+			if sameCell(x, y, obj.Position.X, obj.Position.Y) {
+				add(SeverityError, path+"/position", "collision",
+					"agent %q is inside collidable object %q", agent.Id, obj.Id)
+			}
+		}
+	}
+
+	return out
+}
+
+// checkModel reports a model reference missing from v.Models.
+func (v Validator) checkModel(path, model string, add func(Severity, string, string, string, ...interface{})) {
+	if v.Models == nil {
+		return
+	}
+	if _, ok := v.Models.Lookup(model); !ok {
+		add(SeverityError, path+"/model", "model", "unknown model %q", model)
+	}
+}
+
+// onMap reports whether the point (x, y), in tile units, lies on a map of
+// the given dimensions.
+func onMap(x, y float64, width, height int) bool {
+	return x >= 0 && x < float64(width) && y >= 0 && y < float64(height)
+}
+
+// sameCell reports whether two points fall in the same map tile.
+func sameCell(ax, ay, bx, by float64) bool {
+	return math.Floor(ax) == math.Floor(bx) && math.Floor(ay) == math.Floor(by)
+}
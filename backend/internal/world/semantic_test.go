@@ -0,0 +1,137 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorExampleIsClean(t *testing.T) {
+	env, violations := Validator{}.Validate(readTestdata(t, "environment.json"))
+	require.NotNil(t, env)
+	assert.Empty(t, violations)
+}
+
+func TestValidatorSchemaFailure(t *testing.T) {
+	env, violations := Validator{}.Validate([]byte(`{}`))
+	assert.Nil(t, env)
+	assert.Len(t, violations, 3)
+	assert.True(t, HasErrors(violations))
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator Validator
+		doc       string
+		want      []Violation
+	}{
+		{
+			name: "tile outside map",
+			doc:  `{"map":{"width":2,"height":2,"tiles":[{"x":2,"y":0,"type":"grass"},{"x":0,"y":-1,"type":"grass"}]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/tiles/0", Rule: "bounds", Message: "tile (2,0) is outside the 2x2 map"},
+				{Severity: SeverityError, Path: "/map/tiles/1", Rule: "bounds", Message: "tile (0,-1) is outside the 2x2 map"},
+			},
+		},
+		{
+			name: "too many cells",
+			doc:  `{"map":{"width":4096,"height":4096,"tiles":[]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map", Rule: "map_size", Message: "a 4096x4096 map has more than 1048576 cells"},
+			},
+		},
+		{
+			name: "duplicate tile",
+			doc:  `{"map":{"width":2,"height":2,"tiles":[{"x":1,"y":1,"type":"grass"},{"x":1,"y":1,"type":"dirt"}]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/tiles/1", Rule: "unique", Message: "tile (1,1) duplicates /map/tiles/0"},
+			},
+		},
+		{
+			name: "unknown tile type",
+			doc:  `{"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"lava"}]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/tiles/0/type", Rule: "tile_type", Message: `unknown tile type "lava"`},
+			},
+		},
+		{
+			name:      "custom tile types",
+			validator: Validator{Tiles: mustTileRegistry("lava", []TileType{{Name: "lava", Cost: 1}})},
+			doc:       `{"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"lava"},{"x":1,"y":0,"type":"grass"}]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/tiles/1/type", Rule: "tile_type", Message: `unknown tile type "grass"`},
+			},
+		},
+		{
+			name: "duplicate entity ids",
+			doc: `{"map":{"width":5,"height":5,"tiles":[]},
+				"objects":[{"id":"a","model":"m","position":{"x":1,"y":1}},{"id":"a","model":"m","position":{"x":2,"y":2}}],
+				"agents":[{"id":"b","model":"m","behavior":"x","position":{"x":3,"y":3}},{"id":"b","model":"m","behavior":"x","position":{"x":4,"y":4}},
+					{"id":"a","model":"m","behavior":"x","position":{"x":0,"y":0}}]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/objects/1/id", Rule: "unique", Message: `object id "a" duplicates /objects/0`},
+				{Severity: SeverityError, Path: "/agents/1/id", Rule: "unique", Message: `agent id "b" duplicates /agents/0`},
+				{Severity: SeverityWarning, Path: "/agents/2/id", Rule: "unique", Message: `agent id "a" is also used by /objects/0`},
+			},
+		},
+		{
+			name: "entities off map",
+			doc: `{"map":{"width":2,"height":2,"tiles":[]},
+				"objects":[{"id":"o","model":"m","position":{"x":2,"y":0}}],
+				"agents":[{"id":"a","model":"m","behavior":"x","position":{"x":-0.5,"y":1}}]}`,
+			want: []Violation{
+				{Severity: SeverityWarning, Path: "/objects/0/position", Rule: "bounds", Message: `object "o" at (2,0) is outside the 2x2 map`},
+				{Severity: SeverityError, Path: "/agents/0/position", Rule: "bounds", Message: `agent "a" at (-0.5,1) is outside the 2x2 map`},
+			},
+		},
+		{
+			name: "agent inside collidable object",
+			doc: `{"map":{"width":5,"height":5,"tiles":[]},
+				"objects":[{"id":"rock","model":"m","position":{"x":3.5,"y":0.5},"properties":{"collision":true}},
+					{"id":"bush","model":"m","position":{"x":1.5,"y":1.5},"properties":{"collision":false}}],
+				"agents":[{"id":"a","model":"m","behavior":"x","position":{"x":3.1,"y":0.9}},
+					{"id":"b","model":"m","behavior":"x","position":{"x":1.2,"y":1.2}}]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/agents/0/position", Rule: "collision", Message: `agent "a" is inside collidable object "rock"`},
+			},
+		},
+		{
+			name: "unknown models",
+			validator: Validator{Models: mustModelRegistry(t, []Model{
+				{Name: "rock", Collision: Collision{Shape: ShapeBox}, Properties: map[string]interface{}{"collision": true}},
+			})},
+			doc: `{"map":{"width":5,"height":5,"tiles":[]},
+				"objects":[{"id":"r","model":"rock","position":{"x":3.5,"y":0.5}},{"id":"t","model":"tree","position":{"x":1,"y":1}}],
+				"agents":[{"id":"a","model":"drone","behavior":"x","position":{"x":3.1,"y":0.9}}]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/objects/1/model", Rule: "model", Message: `unknown model "tree"`},
+				{Severity: SeverityError, Path: "/agents/0/model", Rule: "model", Message: `unknown model "drone"`},
+				{Severity: SeverityError, Path: "/agents/0/position", Rule: "collision", Message: `agent "a" is inside collidable object "r"`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := DecodeEnvironment([]byte(tt.doc))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tt.validator.ValidateEnvironment(env))
+		})
+	}
+}
+
+func TestValidatorShippedModels(t *testing.T) {
+	models, err := LoadModelRegistry("../../configs/models.yaml")
+	require.NoError(t, err)
+	env, violations := Validator{Models: models}.Validate(readTestdata(t, "environment.json"))
+	require.NotNil(t, env)
+	assert.Empty(t, violations, "the manifest covers the example environment")
+}
+
+func TestHasErrors(t *testing.T) {
+	assert.False(t, HasErrors(nil))
+	assert.False(t, HasErrors([]Violation{{Severity: SeverityWarning}}))
+	assert.True(t, HasErrors([]Violation{{Severity: SeverityWarning}, {Severity: SeverityError}}))
+}
@@ -0,0 +1,268 @@
+package world
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultSpatialCellSize is the width in tiles of the grid cells World uses
+// to index objects and agents.
+const DefaultSpatialCellSize = 4.0
+
+// SpatialIndex finds points by position on the map plane. Points are bucketed
+// into a uniform grid of square cells, so a query only looks at the cells it
+// overlaps and each update touches at most two cells. Z is ignored. A
+// SpatialIndex is not safe for concurrent mutation.
+type SpatialIndex struct {
+	cellSize float64
+	cells    map[gridCell][]spatialEntry
+	entries  map[string]spatialRef
+}
+
+type gridCell struct{ X, Y int }
+
+type spatialEntry struct {
+	id  string
+	pos Vec3
+}
+
+// spatialRef locates an entry: cells[cell][slot]
+type spatialRef struct {
+	cell gridCell
+	slot int
+}
+
+// NewSpatialIndex returns an empty index with cells cellSize tiles wide. The
+// best size is close to the radius of typical queries; it panics if cellSize
+// is not positive.
+func NewSpatialIndex(cellSize float64) *SpatialIndex {
+	if !(cellSize > 0) {
+		panic("world: spatial index cell size must be positive")
+	}
+	return &SpatialIndex{
+		cellSize: cellSize,
+		cells:    make(map[gridCell][]spatialEntry),
+		entries:  make(map[string]spatialRef),
+	}
+}
+
+// Len returns the number of points in the index.
+func (s *SpatialIndex) Len() int {
+	return len(s.entries)
+}
+
+// Position returns the position of id.
+func (s *SpatialIndex) Position(id string) (Vec3, bool) {
+	ref, ok := s.entries[id]
+	if !ok {
+		return Vec3{}, false
+	}
+	return s.cells[ref.cell][ref.slot].pos, true
+}
+
+// Insert adds id at p, or moves it to p if it is already present.
+func (s *SpatialIndex) Insert(id string, p Vec3) {
+	if !s.Move(id, p) {
+		s.add(spatialEntry{id: id, pos: p})
+	}
+}
+
+// Move changes the position of id and reports whether it is present.
+func (s *SpatialIndex) Move(id string, p Vec3) bool {
+	ref, ok := s.entries[id]
+	if !ok {
+		return false
+	}
+	if s.cellOf(p) == ref.cell {
+		s.cells[ref.cell][ref.slot].pos = p
+		return true
+	}
+	s.remove(ref)
+	s.add(spatialEntry{id: id, pos: p})
+	return true
+}
+
+// Remove deletes id and reports whether it was present.
+func (s *SpatialIndex) Remove(id string) bool {
+	ref, ok := s.entries[id]
+	if !ok {
+		return false
+	}
+	s.remove(ref)
+	delete(s.entries, id)
+	return true
+}
+
+func (s *SpatialIndex) add(e spatialEntry) {
+	c := s.cellOf(e.pos)
+	s.entries[e.id] = spatialRef{cell: c, slot: len(s.cells[c])}
+	s.cells[c] = append(s.cells[c], e)
+}
+
+// remove takes the entry at ref out of its cell by moving the cell's last
+// entry into its slot. The caller updates or deletes the removed entry's ref.
+func (s *SpatialIndex) remove(ref spatialRef) {
+	bucket := s.cells[ref.cell]
+	last := len(bucket) - 1
+	if ref.slot != last {
+		bucket[ref.slot] = bucket[last]
+		s.entries[bucket[ref.slot].id] = ref
+	}
+	bucket[last] = spatialEntry{}
+	if last == 0 {
+		delete(s.cells, ref.cell)
+	} else {
+		s.cells[ref.cell] = bucket[:last]
+	}
+}
+
+func (s *SpatialIndex) cellOf(p Vec3) gridCell {
+	return gridCell{int(math.Floor(p.X / s.cellSize)), int(math.Floor(p.Y / s.cellSize))}
+}
+
+// visit calls fn for every entry in the cells overlapping the area from
+// (minX, minY) to (maxX, maxY). When the area spans more cells than are
+// occupied it walks the occupied cells instead.
+func (s *SpatialIndex) visit(minX, minY, maxX, maxY float64, fn func(spatialEntry)) {
+	lo, hi := s.cellOf(Vec3{X: minX, Y: minY}), s.cellOf(Vec3{X: maxX, Y: maxY})
+	span := (float64(hi.X-lo.X) + 1) * (float64(hi.Y-lo.Y) + 1)
+	if span > float64(len(s.cells)) {
+		for c, bucket := range s.cells {
+			if c.X >= lo.X && c.X <= hi.X && c.Y >= lo.Y && c.Y <= hi.Y {
+				for _, e := range bucket {
+					fn(e)
+				}
+			}
+		}
+		return
+	}
+	for y := lo.Y; y <= hi.Y; y++ {
+		for x := lo.X; x <= hi.X; x++ {
+			for _, e := range s.cells[gridCell{x, y}] {
+				fn(e)
+			}
+		}
+	}
+}
+
+// InRect returns the IDs of the points inside r, in no particular order.
+func (s *SpatialIndex) InRect(r Rect) []string {
+	var out []string
+	if !(r.MinX < r.MaxX && r.MinY < r.MaxY) {
+		return out
+	}
+	s.visit(r.MinX, r.MinY, r.MaxX, r.MaxY, func(e spatialEntry) {
+		if r.Contains(e.pos.X, e.pos.Y) {
+			out = append(out, e.id)
+		}
+	})
+	return out
+}
+
+// InRadius returns the IDs of the points at most radius from center, in no
+// particular order.
+func (s *SpatialIndex) InRadius(center Vec3, radius float64) []string {
+	var out []string
+	if !(radius >= 0) {
+		return out
+	}
+	r2 := radius * radius
+	s.visit(center.X-radius, center.Y-radius, center.X+radius, center.Y+radius, func(e spatialEntry) {
+		if distance2(center, e.pos) <= r2 {
+			out = append(out, e.id)
+		}
+	})
+	return out
+}
+
+// Nearest returns the IDs of the k points closest to p, nearest first. Points
+// at the same distance are ordered by ID.
+func (s *SpatialIndex) Nearest(p Vec3, k int) []string {
+	if k <= 0 || len(s.entries) == 0 {
+		return nil
+	}
+	type candidate struct {
+		id    string
+		dist2 float64
+	}
+	var found []candidate
+	collect := func(e spatialEntry) {
+		found = append(found, candidate{e.id, distance2(p, e.pos)})
+	}
+	settle := func() {
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].dist2 != found[j].dist2 {
+				return found[i].dist2 < found[j].dist2
+			}
+			return found[i].id < found[j].id
+		})
+		if len(found) > k {
+			found = found[:k]
+		}
+	}
+
+	// Search rings of cells around p's cell. Every point outside ring d is
+	// more than d cells from p, so the search stops once the k-th nearest
+	// point found is closer than that. Sparse indexes are scanned whole
+	// rather than walking empty rings.
+	center := s.cellOf(p)
+	looked := 0
+	for d := 0; ; d++ {
+		ring := 8 * d
+		if d == 0 {
+			ring = 1
+		}
+		if looked+ring > len(s.cells) {
+			found = found[:0]
+			for _, bucket := range s.cells {
+				for _, e := range bucket {
+					collect(e)
+				}
+			}
+			settle()
+			break
+		}
+		looked += ring
+		s.visitRing(center, d, collect)
+		if len(found) >= k {
+			settle()
+			reach := float64(d) * s.cellSize
+			if found[k-1].dist2 <= reach*reach {
+				break
+			}
+		}
+	}
+
+	out := make([]string, len(found))
+	for i, c := range found {
+		out[i] = c.id
+	}
+	return out
+}
+
+// visitRing calls fn for every entry in the cells exactly d cells from c
+// along either axis.
+func (s *SpatialIndex) visitRing(c gridCell, d int, fn func(spatialEntry)) {
+	visitCell := func(x, y int) {
+		for _, e := range s.cells[gridCell{x, y}] {
+			fn(e)
+		}
+	}
+	if d == 0 {
+		visitCell(c.X, c.Y)
+		return
+	}
+	for x := c.X - d; x <= c.X+d; x++ {
+		visitCell(x, c.Y-d)
+		visitCell(x, c.Y+d)
+	}
+	for y := c.Y - d + 1; y < c.Y+d; y++ {
+		visitCell(c.X-d, y)
+		visitCell(c.X+d, y)
+	}
+}
+
+func distance2(a, b Vec3) float64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,125 @@
+package world
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpatialIndexUpdates(t *testing.T) {
+	s := NewSpatialIndex(2)
+	s.Insert("a", Vec3{X: 1, Y: 1})
+	s.Insert("b", Vec3{X: 1.5, Y: 1})
+	s.Insert("c", Vec3{X: 9, Y: 9})
+	assert.Equal(t, 3, s.Len())
+
+	assert.True(t, s.Move("a", Vec3{X: 8, Y: 8}))
+	assert.ElementsMatch(t, []string{"a", "c"}, s.InRect(Rect{MinX: 5, MinY: 5, MaxX: 10, MaxY: 10}))
+	assert.Equal(t, []string{"b"}, s.InRect(Rect{MaxX: 2, MaxY: 2}))
+
+	s.Insert("b", Vec3{X: 1.8, Y: 1}) // an existing ID moves
+	p, ok := s.Position("b")
+	require.True(t, ok)
+	assert.Equal(t, Vec3{X: 1.8, Y: 1}, p)
+	assert.Equal(t, 3, s.Len())
+
+	assert.True(t, s.Remove("a"))
+	assert.False(t, s.Remove("a"))
+	assert.False(t, s.Move("a", Vec3{}))
+	_, ok = s.Position("a")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"c"}, s.InRadius(Vec3{X: 8, Y: 8}, 1.5))
+}
+
+func TestSpatialIndexQueries(t *testing.T) {
+	s := NewSpatialIndex(1)
+	for id, p := range map[string]Vec3{
+		"origin": {},
+		"east":   {X: 3},
+		"north":  {Y: 3},
+		"far":    {X: 40, Y: -40},
+		"edge":   {X: 2},
+	} {
+		s.Insert(id, p)
+	}
+
+	assert.ElementsMatch(t, []string{"origin", "edge"}, s.InRect(Rect{MinX: 0, MinY: 0, MaxX: 3, MaxY: 3}), "maximum edges are excluded")
+	assert.ElementsMatch(t, []string{"origin", "east", "north", "edge"}, s.InRadius(Vec3{}, 3), "the radius is inclusive")
+	assert.Empty(t, s.InRadius(Vec3{}, -1))
+	assert.Empty(t, s.InRect(Rect{MinX: 1, MaxX: 1, MaxY: 5}))
+
+	assert.Equal(t, []string{"east", "edge", "origin"}, s.Nearest(Vec3{X: 2.5, Y: 0.5}, 3), "ties are ordered by ID")
+	assert.Equal(t, []string{"far"}, s.Nearest(Vec3{X: 100, Y: -100}, 1))
+	assert.Len(t, s.Nearest(Vec3{}, 10), 5, "k larger than the index returns everything")
+	assert.Empty(t, s.Nearest(Vec3{}, 0))
+}
+
+// TestSpatialIndexMatchesScan checks the index against a linear scan over a
+// few thousand points moving at random.
+func TestSpatialIndexMatchesScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	point := func() Vec3 { return Vec3{X: rng.Float64()*200 - 50, Y: rng.Float64() * 100} }
+
+	s := NewSpatialIndex(DefaultSpatialCellSize)
+	points := make(map[string]Vec3)
+	for i := 0; i < 5000; i++ {
+		id := fmt.Sprintf("p%d", i)
+		points[id] = point()
+		s.Insert(id, points[id])
+	}
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprintf("p%d", rng.Intn(5000))
+		if i%4 == 0 {
+			_, present := points[id]
+			assert.Equal(t, present, s.Remove(id))
+			delete(points, id)
+			continue
+		}
+		points[id] = point()
+		s.Insert(id, points[id])
+	}
+	require.Equal(t, len(points), s.Len())
+
+	for i := 0; i < 50; i++ {
+		center := point()
+		radius := rng.Float64() * 20
+		r := Rect{MinX: center.X, MinY: center.Y, MaxX: center.X + radius*3, MaxY: center.Y + radius}
+
+		var inRect, inRadius []string
+		for id, p := range points {
+			if r.Contains(p.X, p.Y) {
+				inRect = append(inRect, id)
+			}
+			if distance2(center, p) <= radius*radius {
+				inRadius = append(inRadius, id)
+			}
+		}
+		assert.ElementsMatch(t, inRect, s.InRect(r))
+		assert.ElementsMatch(t, inRadius, s.InRadius(center, radius))
+
+		k := 1 + rng.Intn(30)
+		assert.Equal(t, nearestByScan(points, center, k), s.Nearest(center, k))
+	}
+}
+
+func nearestByScan(points map[string]Vec3, p Vec3, k int) []string {
+	ids := make([]string, 0, len(points))
+	for id := range points {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		di, dj := distance2(p, points[ids[i]]), distance2(p, points[ids[j]])
+		if di != dj {
+			return di < dj
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > k {
+		ids = ids[:k]
+	}
+	return ids
+}
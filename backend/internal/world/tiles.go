@@ -0,0 +1,233 @@
+package world
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Movement is a way of getting across the map. Each tile type says which
+// movements it allows.
+type Movement int
+
+const (
+	Walk Movement = iota
+	Swim
+	Fly
+)
+
+func (m Movement) String() string {
+	switch m {
+	case Walk:
+		return "walk"
+	case Swim:
+		return "swim"
+	case Fly:
+		return "fly"
+	default:
+		return fmt.Sprintf("Movement(%d)", int(m))
+	}
+}
+
+// TileType holds the properties shared by every tile of one type.
+type TileType struct {
+	Name string `json:"name"`
+	// Cost multiplies the effort of crossing the tile on foot or swimming.
+	// Flying ignores it.
+	Cost      float64 `json:"cost"`
+	Walkable  bool    `json:"walkable"`
+	Swimmable bool    `json:"swimmable"`
+	Flyable   bool    `json:"flyable"`
+	Liquid    bool    `json:"liquid"`
+	Friction  float64 `json:"friction"`
+	// Color is the #rrggbb colour viewers draw the tile with.
+	Color string `json:"color,omitempty"`
+}
+
+// Allows reports whether the tile can be crossed with movement m.
+func (t TileType) Allows(m Movement) bool {
+	switch m {
+	case Walk:
+		return t.Walkable
+	case Swim:
+		return t.Swimmable
+	case Fly:
+		return t.Flyable
+	default:
+		return false
+	}
+}
+
+// MoveCost returns the cost of entering the tile with movement m, or false
+// if m is not allowed.
+func (t TileType) MoveCost(m Movement) (float64, bool) {
+	if !t.Allows(m) {
+		return 0, false
+	}
+	if m == Fly {
+		return 1, true
+	}
+	return t.Cost, true
+}
+
+// TileRegistry is the set of tile types a map may use. It is read-only once
+// built and safe for concurrent use.
+type TileRegistry struct {
+	types    map[string]TileType
+	fallback string
+}
+
+// DefaultTiles describes the tile types every environment could use before
+// they became configurable.
+var DefaultTiles = mustTileRegistry(DefaultTileType, []TileType{
+	{Name: "grass", Cost: 1, Walkable: true, Flyable: true, Friction: 0.8, Color: "#4caf50"},
+	{Name: "dirt", Cost: 1.2, Walkable: true, Flyable: true, Friction: 0.7, Color: "#8d6e63"},
+	{Name: "stone", Cost: 1.5, Walkable: true, Flyable: true, Friction: 0.9, Color: "#9e9e9e"},
+	{Name: "water", Cost: 3, Swimmable: true, Flyable: true, Liquid: true, Friction: 0.1, Color: "#2196f3"},
+})
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// NewTileRegistry checks a set of tile types and returns them as a registry.
+// fallback names the type of cells a map leaves out and must be one of types.
+func NewTileRegistry(fallback string, types []TileType) (*TileRegistry, error) {
+	if len(types) == 0 {
+		return nil, errors.New("no tile types defined")
+	}
+	r := &TileRegistry{types: make(map[string]TileType, len(types)), fallback: fallback}
+	for _, t := range types {
+		if t.Name == "" {
+			return nil, errors.New("tile type has no name")
+		}
+		if _, dup := r.types[t.Name]; dup {
+			return nil, fmt.Errorf("tile type %q is defined twice", t.Name)
+		}
+		if t.Cost <= 0 {
+			return nil, fmt.Errorf("tile type %q: cost must be positive", t.Name)
+		}
+		if t.Friction < 0 {
+			return nil, fmt.Errorf("tile type %q: friction must not be negative", t.Name)
+		}
+		if t.Color != "" && !colorPattern.MatchString(t.Color) {
+			return nil, fmt.Errorf("tile type %q: color must look like #rrggbb, got %q", t.Name, t.Color)
+		}
+		r.types[t.Name] = t
+	}
+	if _, ok := r.types[fallback]; !ok {
+		return nil, fmt.Errorf("default tile type %q is not defined", fallback)
+	}
+	return r, nil
+}
+
+func mustTileRegistry(fallback string, types []TileType) *TileRegistry {
+	r, err := NewTileRegistry(fallback, types)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// tileFile is the layout of a tile definitions file. Pointers tell omitted
+// properties apart so they can take their defaults.
+type tileFile struct {
+	Default   string `yaml:"default"`
+	TileTypes map[string]struct {
+		Cost      *float64 `yaml:"cost"`
+		Walkable  *bool    `yaml:"walkable"`
+		Swimmable *bool    `yaml:"swimmable"`
+		Flyable   *bool    `yaml:"flyable"`
+		Liquid    bool     `yaml:"liquid"`
+		Friction  *float64 `yaml:"friction"`
+		Color     string   `yaml:"color"`
+	} `yaml:"tile_types"`
+}
+
+// ParseTileRegistry reads a tile definitions file such as
+// configs/tile_types.yaml. Omitted properties default to a cost and friction
+// of 1, walkable and flyable, and swimmable only if liquid.
+func ParseTileRegistry(data []byte) (*TileRegistry, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var f tileFile
+	if err := dec.Decode(&f); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("empty tile definitions")
+		}
+		return nil, err
+	}
+
+	or := func(v *bool, def bool) bool {
+		if v == nil {
+			return def
+		}
+		return *v
+	}
+	orFloat := func(v *float64, def float64) float64 {
+		if v == nil {
+			return def
+		}
+		return *v
+	}
+
+	types := make([]TileType, 0, len(f.TileTypes))
+	for name, def := range f.TileTypes {
+		types = append(types, TileType{
+			Name:      name,
+			Cost:      orFloat(def.Cost, 1),
+			Walkable:  or(def.Walkable, !def.Liquid),
+			Swimmable: or(def.Swimmable, def.Liquid),
+			Flyable:   or(def.Flyable, true),
+			Liquid:    def.Liquid,
+			Friction:  orFloat(def.Friction, 1),
+			Color:     def.Color,
+		})
+	}
+	// Sort so that errors about duplicates or bad values are reproducible
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+
+	fallback := f.Default
+	if fallback == "" {
+		fallback = DefaultTileType
+	}
+	return NewTileRegistry(fallback, types)
+}
+
+// LoadTileRegistry reads the tile definitions file at path.
+func LoadTileRegistry(path string) (*TileRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseTileRegistry(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Lookup returns the tile type called name.
+func (r *TileRegistry) Lookup(name string) (TileType, bool) {
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Default returns the type of cells a map does not list.
+func (r *TileRegistry) Default() TileType {
+	return r.types[r.fallback]
+}
+
+// Types returns every tile type sorted by name.
+func (r *TileRegistry) Types() []TileType {
+	out := make([]TileType, 0, len(r.types))
+	for _, t := range r.types {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,72 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShippedTileTypesMatchDefaults(t *testing.T) {
+	r, err := LoadTileRegistry("../../configs/tile_types.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTiles.Types(), r.Types())
+	assert.Equal(t, DefaultTiles.Default(), r.Default())
+}
+
+func TestParseTileRegistry(t *testing.T) {
+	r, err := ParseTileRegistry([]byte(`
+default: sand
+tile_types:
+  sand: {}
+  lava: {cost: 5, walkable: false, flyable: true, color: "#ff5722"}
+  swamp: {liquid: true, walkable: true}
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "sand", r.Default().Name)
+	assert.Equal(t, []TileType{
+		{Name: "lava", Cost: 5, Flyable: true, Friction: 1, Color: "#ff5722"},
+		{Name: "sand", Cost: 1, Walkable: true, Flyable: true, Friction: 1},
+		{Name: "swamp", Cost: 1, Walkable: true, Swimmable: true, Flyable: true, Liquid: true, Friction: 1},
+	}, r.Types())
+
+	_, ok := r.Lookup("grass")
+	assert.False(t, ok)
+}
+
+func TestParseTileRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"empty", ``, "empty tile definitions"},
+		{"no types", `tile_types: {}`, "no tile types defined"},
+		{"unknown property", `tile_types: {grass: {speed: 2}}`, "field speed not found"},
+		{"bad cost", `tile_types: {grass: {cost: 0}}`, `tile type "grass": cost must be positive`},
+		{"bad friction", `tile_types: {grass: {friction: -1}}`, `tile type "grass": friction must not be negative`},
+		{"bad color", `tile_types: {grass: {color: green}}`, `color must look like #rrggbb, got "green"`},
+		{"missing default", `tile_types: {sand: {}}`, `default tile type "grass" is not defined`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTileRegistry([]byte(tt.doc))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestTileTypeMoveCost(t *testing.T) {
+	water, ok := DefaultTiles.Lookup("water")
+	require.True(t, ok)
+
+	_, ok = water.MoveCost(Walk)
+	assert.False(t, ok)
+	cost, ok := water.MoveCost(Swim)
+	require.True(t, ok)
+	assert.Equal(t, 3.0, cost)
+	cost, ok = water.MoveCost(Fly)
+	require.True(t, ok)
+	assert.Equal(t, 1.0, cost)
+}
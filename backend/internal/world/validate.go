@@ -0,0 +1,427 @@
+package world
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity grades a violation. Only SeverityError violations make an
+// environment invalid; warnings are reported but do not block saving.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Violation describes a single problem found in an environment definition.
+type Violation struct {
+	// Severity is SeverityError or SeverityWarning.
+	Severity Severity `json:"severity"`
+	// Path is a JSON pointer (RFC 6901) to the offending value.
+	Path string `json:"path"`
+	// Rule names the check that failed, e.g. "required" or "minimum".
+	Rule string `json:"rule"`
+	// Message is a human-readable description of the problem.
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when an environment definition breaks one or
+// more rules. It carries every violation found rather than only the first.
+type ValidationError struct {
+	Violations []Violation
+}
+
+// HasErrors reports whether any of violations has SeverityError.
+func HasErrors(violations []Violation) bool {
+	for _, v := range violations {
+		if v.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 1 {
+		v := e.Violations[0]
+		return fmt.Sprintf("invalid environment: %s: %s", displayPath(v.Path), v.Message)
+	}
+	return fmt.Sprintf("invalid environment: %d violations", len(e.Violations))
+}
+
+// DecodeEnvironment validates data against the environment schema and decodes
+// it into the generated types, applying schema defaults. If the document is
+// invalid the returned error is a *ValidationError.
+func DecodeEnvironment(data []byte) (*EnvironmentSchemaJson, error) {
+	if violations := ValidateSchema(data); len(violations) > 0 {
+		return nil, &ValidationError{Violations: violations}
+	}
+
+	var env EnvironmentSchemaJson
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, &ValidationError{Violations: []Violation{{
+			Severity: SeverityError,
+			Path:     "",
+			Rule:     "decode",
+			Message:  err.Error(),
+		}}}
+	}
+	return &env, nil
+}
+
+// ValidateSchema checks data against the rules of
+// schemas/environment.schema.json and returns every violation found. A
+// document that is not valid JSON yields a single "syntax" violation.
+//
+// The document is checked as a token stream rather than decoded into a tree,
+// so validating a large map costs little beyond the bytes themselves.
+func ValidateSchema(data []byte) []Violation {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out []Violation
+	if err := environmentSchema.validate(dec, "", &out); err != nil {
+		if err == io.EOF && len(bytes.TrimSpace(data)) > 0 {
+			// the stream ran out part way through a value
+			err = io.ErrUnexpectedEOF
+		}
+		return []Violation{{Severity: SeverityError, Path: "", Rule: "syntax", Message: err.Error()}}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return []Violation{{Severity: SeverityError, Path: "", Rule: "syntax", Message: "unexpected data after top-level value"}}
+	}
+	return out
+}
+
+// schemaNode is the subset of JSON Schema used by the environment schema.
+type schemaNode struct {
+	typ        string
+	format     string
+	required   []string
+	properties map[string]*schemaNode
+	items      *schemaNode
+	minimum    *float64
+	maximum    *float64
+}
+
+func minimum(v float64) *float64 { return &v }
+
+func maximum(v float64) *float64 { return &v }
+
+var (
+	stringNode  = &schemaNode{typ: "string"}
+	numberNode  = &schemaNode{typ: "number"}
+	integerNode = &schemaNode{typ: "integer"}
+	objectNode  = &schemaNode{typ: "object"}
+	tagsNode    = &schemaNode{typ: "array", items: stringNode}
+)
+
+func positionNode() *schemaNode {
+	return &schemaNode{
+		typ:      "object",
+		required: []string{"x", "y"},
+		properties: map[string]*schemaNode{
+			"x": numberNode,
+			"y": numberNode,
+			"z": numberNode,
+		},
+	}
+}
+
+// environmentSchema mirrors schemas/environment.schema.json. Keep the two in
+// sync when the schema changes.
+var environmentSchema = &schemaNode{
+	typ:      "object",
+	required: []string{"map", "objects", "agents"},
+	properties: map[string]*schemaNode{
+		"metadata": {
+			typ: "object",
+			properties: map[string]*schemaNode{
+				"name":    stringNode,
+				"version": stringNode,
+				"author":  stringNode,
+				"created": {typ: "string", format: "date-time"},
+				"tags":    tagsNode,
+			},
+		},
+		"map": {
+			typ:      "object",
+			required: []string{"width", "height", "tiles"},
+			properties: map[string]*schemaNode{
+				"width":    {typ: "integer", minimum: minimum(1), maximum: maximum(MaxMapDimension)},
+				"height":   {typ: "integer", minimum: minimum(1), maximum: maximum(MaxMapDimension)},
+				"tileSize": {typ: "number", minimum: minimum(0.1)},
+				"tiles": {
+					typ: "array",
+					items: &schemaNode{
+						typ:      "object",
+						required: []string{"x", "y", "type"},
+						properties: map[string]*schemaNode{
+							"x":      integerNode,
+							"y":      integerNode,
+							"type":   stringNode,
+							"height": numberNode,
+							"tags":   tagsNode,
+						},
+					},
+				},
+			},
+		},
+		"objects": {
+			typ: "array",
+			items: &schemaNode{
+				typ:      "object",
+				required: []string{"id", "model", "position"},
+				properties: map[string]*schemaNode{
+					"id":         stringNode,
+					"model":      stringNode,
+					"position":   positionNode(),
+					"rotation":   numberNode,
+					"tags":       tagsNode,
+					"properties": objectNode,
+				},
+			},
+		},
+		"agents": {
+			typ: "array",
+			items: &schemaNode{
+				typ:      "object",
+				required: []string{"id", "model", "behavior", "position"},
+				properties: map[string]*schemaNode{
+					"id":       stringNode,
+					"model":    stringNode,
+					"behavior": stringNode,
+					"position": positionNode(),
+					"facing":   numberNode,
+					"state":    objectNode,
+					"tags":     tagsNode,
+				},
+			},
+		},
+		"simulation": {
+			typ: "object",
+			properties: map[string]*schemaNode{
+				"profile":   stringNode,
+				"overrides": objectNode,
+			},
+		},
+	},
+}
+
+// validate reads the next value from dec and records its violations of n.
+// Object members are reported required-first and then by property name, so
+// the output does not depend on member order. It returns an error only if
+// the input is not valid JSON.
+func (n *schemaNode) validate(dec *json.Decoder, path string, out *[]Violation) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if !n.checkType(path, tok, out) {
+		return skipRest(dec, tok)
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '[' {
+			for i := 0; dec.More(); i++ {
+				if n.items == nil {
+					err = skipValue(dec)
+				} else {
+					err = n.items.validate(dec, path+"/"+strconv.Itoa(i), out)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token() // closing ']'
+			return err
+		}
+
+		present := make(map[string]bool)
+		children := make(map[string][]Violation)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			name := key.(string)
+			present[name] = true
+			child, ok := n.properties[name]
+			if !ok {
+				if err := skipValue(dec); err != nil {
+					return err
+				}
+				continue
+			}
+			var found []Violation
+			if err := child.validate(dec, path+"/"+escapePointer(name), &found); err != nil {
+				return err
+			}
+			children[name] = append(children[name], found...)
+		}
+		if _, err := dec.Token(); err != nil { // closing '}'
+			return err
+		}
+
+		for _, name := range n.required {
+			if !present[name] {
+				*out = append(*out, Violation{
+					Severity: SeverityError,
+					Path:     path + "/" + escapePointer(name),
+					Rule:     "required",
+					Message:  fmt.Sprintf("%s is required", name),
+				})
+			}
+		}
+		names := make([]string, 0, len(children))
+		for name := range children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			*out = append(*out, children[name]...)
+		}
+	case json.Number:
+		if n.minimum != nil {
+			if f, err := v.Float64(); err == nil && f < *n.minimum {
+				*out = append(*out, Violation{
+					Severity: SeverityError,
+					Path:     path,
+					Rule:     "minimum",
+					Message:  fmt.Sprintf("must be >= %v", *n.minimum),
+				})
+			}
+		}
+		if n.maximum != nil {
+			if f, err := v.Float64(); err == nil && f > *n.maximum {
+				*out = append(*out, Violation{
+					Severity: SeverityError,
+					Path:     path,
+					Rule:     "maximum",
+					Message:  fmt.Sprintf("must be <= %v", *n.maximum),
+				})
+			}
+		}
+	case string:
+		if n.format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				*out = append(*out, Violation{
+					Severity: SeverityError,
+					Path:     path,
+					Rule:     "format",
+					Message:  "must be an RFC 3339 date-time",
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// checkType records a "type" violation and returns false if tok does not
+// start a value of the node's JSON type.
+func (n *schemaNode) checkType(path string, tok json.Token, out *[]Violation) bool {
+	got := tokenType(tok)
+	ok := false
+	switch n.typ {
+	case "":
+		ok = true
+	case "integer":
+		// Only plain integer literals decode into Go ints, so 1.0 and 1e3
+		// are rejected even though JSON Schema would accept them.
+		if num, isNum := tok.(json.Number); isNum {
+			_, err := strconv.ParseInt(num.String(), 10, 64)
+			ok = err == nil
+		}
+	default:
+		ok = got == n.typ
+	}
+
+	if !ok {
+		*out = append(*out, Violation{
+			Severity: SeverityError,
+			Path:     path,
+			Rule:     "type",
+			Message:  fmt.Sprintf("must be %s %s, got %s", article(n.typ), n.typ, got),
+		})
+	}
+	return ok
+}
+
+// tokenType names the JSON type of the value tok starts.
+func tokenType(tok json.Token) string {
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			return "array"
+		}
+		return "object"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", tok)
+	}
+}
+
+// skipValue reads and discards the next value from dec.
+func skipValue(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	return skipRest(dec, tok)
+}
+
+// skipRest discards the remainder of the value started by tok, which is a
+// no-op unless tok opened an object or array.
+func skipRest(dec *json.Decoder, tok json.Token) error {
+	if d, ok := tok.(json.Delim); !ok || (d != '{' && d != '[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+func article(word string) string {
+	if strings.ContainsAny(word[:1], "aeiou") {
+		return "an"
+	}
+	return "a"
+}
+
+// escapePointer escapes a property name for use as a JSON pointer token.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
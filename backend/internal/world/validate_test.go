@@ -0,0 +1,179 @@
+package world
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	return data
+}
+
+func TestDecodeEnvironmentExample(t *testing.T) {
+	env, err := DecodeEnvironment(readTestdata(t, "environment.json"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, env.Map.Width)
+	assert.Len(t, env.Map.Tiles, 5)
+	assert.Len(t, env.Objects, 2)
+	assert.Len(t, env.Agents, 2)
+	assert.Equal(t, "Test Environment Alpha", *env.Metadata.Name)
+}
+
+func TestDecodeEnvironmentDefaults(t *testing.T) {
+	env, err := DecodeEnvironment([]byte(`{
+		"metadata": {},
+		"map": {"width": 1, "height": 1, "tiles": []},
+		"objects": [{"id": "o", "model": "m", "position": {"x": 0, "y": 0}}],
+		"agents": []
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, env.Map.TileSize)
+	assert.Equal(t, "0.1.0", env.Metadata.Version)
+	assert.Equal(t, 0.0, env.Objects[0].Rotation)
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []Violation
+	}{
+		{
+			name: "valid",
+			doc:  `{"map":{"width":1,"height":1,"tiles":[]},"objects":[],"agents":[]}`,
+		},
+		{
+			name: "empty object",
+			doc:  `{}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map", Rule: "required", Message: "map is required"},
+				{Severity: SeverityError, Path: "/objects", Rule: "required", Message: "objects is required"},
+				{Severity: SeverityError, Path: "/agents", Rule: "required", Message: "agents is required"},
+			},
+		},
+		{
+			name: "not an object",
+			doc:  `[]`,
+			want: []Violation{{Severity: SeverityError, Path: "", Rule: "type", Message: "must be an object, got array"}},
+		},
+		{
+			name: "syntax error",
+			doc:  `{"map":`,
+			want: []Violation{{Severity: SeverityError, Path: "", Rule: "syntax", Message: "unexpected EOF"}},
+		},
+		{
+			name: "trailing data",
+			doc:  `{} {}`,
+			want: []Violation{{Severity: SeverityError, Path: "", Rule: "syntax", Message: "unexpected data after top-level value"}},
+		},
+		{
+			name: "map bounds",
+			doc:  `{"map":{"width":0,"height":-1,"tileSize":0.05,"tiles":[]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/height", Rule: "minimum", Message: "must be >= 1"},
+				{Severity: SeverityError, Path: "/map/tileSize", Rule: "minimum", Message: "must be >= 0.1"},
+				{Severity: SeverityError, Path: "/map/width", Rule: "minimum", Message: "must be >= 1"},
+			},
+		},
+		{
+			name: "map too large",
+			doc:  `{"map":{"width":4294967296,"height":4097,"tiles":[]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/height", Rule: "maximum", Message: "must be <= 4096"},
+				{Severity: SeverityError, Path: "/map/width", Rule: "maximum", Message: "must be <= 4096"},
+			},
+		},
+		{
+			name: "nested items",
+			doc: `{"map":{"width":1,"height":1,"tiles":[{"x":"0","y":1.0,"type":"grass","tags":[1]}]},
+				"objects":[{"id":"o","position":{"x":0}}],
+				"agents":[{"id":"a","model":"m","behavior":"b","position":{"x":0,"y":0},"facing":"north"}]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/map/tiles/0/tags/0", Rule: "type", Message: "must be a string, got number"},
+				{Severity: SeverityError, Path: "/map/tiles/0/x", Rule: "type", Message: "must be an integer, got string"},
+				{Severity: SeverityError, Path: "/map/tiles/0/y", Rule: "type", Message: "must be an integer, got number"},
+				{Severity: SeverityError, Path: "/objects/0/model", Rule: "required", Message: "model is required"},
+				{Severity: SeverityError, Path: "/objects/0/position/y", Rule: "required", Message: "y is required"},
+				{Severity: SeverityError, Path: "/agents/0/facing", Rule: "type", Message: "must be a number, got string"},
+			},
+		},
+		{
+			name: "metadata format",
+			doc:  `{"metadata":{"created":"yesterday","name":null},"map":{"width":1,"height":1,"tiles":[]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/metadata/created", Rule: "format", Message: "must be an RFC 3339 date-time"},
+				{Severity: SeverityError, Path: "/metadata/name", Rule: "type", Message: "must be a string, got null"},
+			},
+		},
+		{
+			name: "simulation settings",
+			doc:  `{"simulation":{"profile":3,"overrides":["physics.gravity"]},"map":{"width":1,"height":1,"tiles":[]},"objects":[],"agents":[]}`,
+			want: []Violation{
+				{Severity: SeverityError, Path: "/simulation/overrides", Rule: "type", Message: "must be an object, got array"},
+				{Severity: SeverityError, Path: "/simulation/profile", Rule: "type", Message: "must be a string, got number"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateSchema([]byte(tt.doc))
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateSchemaLargeMap(t *testing.T) {
+	const size = 300
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"map":{"width":%d,"height":%d,"tiles":[`, size, size)
+	for i := 0; i < size*size; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if i == size*size-1 {
+			b.WriteString(`{"x":"last","y":0,"type":"grass","extra":[1,{"a":[]}]}`)
+			continue
+		}
+		fmt.Fprintf(&b, `{"x":%d,"y":%d,"type":"grass"}`, i%size, i/size)
+	}
+	b.WriteString(`]},"objects":[],"agents":[]}`)
+
+	got := ValidateSchema([]byte(b.String()))
+	assert.Equal(t, []Violation{{
+		Severity: SeverityError,
+		Path:     fmt.Sprintf("/map/tiles/%d/x", size*size-1),
+		Rule:     "type",
+		Message:  "must be an integer, got string",
+	}}, got)
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	_, err := DecodeEnvironment([]byte(`{"map":{"width":1,"height":1,"tiles":[]},"objects":[]}`))
+	require.Error(t, err)
+	assert.EqualError(t, err, "invalid environment: /agents: agents is required")
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Violations, 1)
+
+	_, err = DecodeEnvironment([]byte(`{}`))
+	assert.EqualError(t, err, "invalid environment: 3 violations")
+
+	_, err = DecodeEnvironment([]byte(`1`))
+	assert.EqualError(t, err, "invalid environment: /: must be an object, got number")
+}
+
+func TestEscapePointer(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", escapePointer("a/b~c"))
+}
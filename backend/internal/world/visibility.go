@@ -0,0 +1,272 @@
+package world
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Hit is the first thing found blocking a line of sight.
+type Hit struct {
+	// X and Y are the blocking cell.
+	X int `json:"x"`
+	Y int `json:"y"`
+	// Point is where the line enters the cell; its Z is the line's
+	// elevation there, tile height included.
+	Point Vec3 `json:"point"`
+	// Object is the ID of the blocking object, or empty if the terrain
+	// blocks.
+	Object string `json:"object,omitempty"`
+}
+
+// Sight describes how far and how wide an agent sees.
+type Sight struct {
+	// Range is the furthest distance seen in tiles on the map plane. Zero
+	// means unlimited.
+	Range float64
+	// FOV is the full width of the view cone in radians, centred on the
+	// agent's facing. Zero, or 2π and above, means all around.
+	FOV float64
+	// EyeHeight is how far above its position the agent looks from.
+	EyeHeight float64
+}
+
+// elevation returns the ground height at (x, y) plus z. Off the map the
+// ground is at zero.
+func (w *World) elevation(x, y, z float64) float64 {
+	tile, _ := w.TileAt(CellOf(x, y))
+	return tile.Height + z
+}
+
+// Raycast follows the straight line between two points and returns the first
+// cell that blocks it. The Z of each point is its height above the ground.
+// A cell blocks when its tile rises above the line, or when it holds a
+// collidable object that reaches the line: up to the object's "height"
+// property above the ground, or without limit if it has none. The cells
+// holding the two points themselves never block, and neither do cells off
+// the map, so only the part of the line over the map is walked.
+func (w *World) Raycast(from, to Vec3) (Hit, bool) {
+	z0 := w.elevation(from.X, from.Y, from.Z)
+	z1 := w.elevation(to.X, to.Y, to.Z)
+	dx, dy := to.X-from.X, to.Y-from.Y
+	enter, leave, ok := clipLine(from.X, from.Y, dx, dy, float64(w.Width), float64(w.Height))
+	if !ok {
+		return Hit{}, false
+	}
+	startX, startY := from.X+dx*enter, from.Y+dy*enter
+	x, y := CellOf(startX, startY)
+	lastX, lastY := CellOf(from.X+dx*leave, from.Y+dy*leave)
+	fromX, fromY := CellOf(from.X, from.Y)
+	endX, endY := CellOf(to.X, to.Y)
+
+	// Walk the cells the line crosses in order. tMaxX and tMaxY are how far
+	// along the line, from 0 to 1, it next crosses a vertical and a
+	// horizontal cell edge.
+	stepX, tMaxX, tDeltaX := gridStep(startX, dx)
+	stepY, tMaxY, tDeltaY := gridStep(startY, dy)
+	tMaxX += enter
+	tMaxY += enter
+	check := func(t float64) (Hit, bool) {
+		exit := math.Min(math.Min(tMaxX, tMaxY), 1)
+		low := math.Min(z0+(z1-z0)*t, z0+(z1-z0)*exit)
+		object, blocked := w.blocks(x, y, low)
+		if !blocked {
+			return Hit{}, false
+		}
+		point := Vec3{X: from.X + dx*t, Y: from.Y + dy*t, Z: z0 + (z1-z0)*t}
+		return Hit{X: x, Y: y, Point: point, Object: object}, true
+	}
+
+	// A line entering the map from outside starts in a cell that neither
+	// point holds
+	if (x != fromX || y != fromY) && (x != endX || y != endY) {
+		if hit, blocked := check(enter); blocked {
+			return hit, true
+		}
+	}
+	steps := abs(lastX-x) + abs(lastY-y)
+	for i := 0; i < steps; i++ {
+		var t float64
+		if tMaxX < tMaxY {
+			x += stepX
+			t = tMaxX
+			tMaxX += tDeltaX
+		} else {
+			y += stepY
+			t = tMaxY
+			tMaxY += tDeltaY
+		}
+		if x == endX && y == endY {
+			break
+		}
+		if hit, blocked := check(t); blocked {
+			return hit, true
+		}
+	}
+	return Hit{}, false
+}
+
+// clipLine returns the part of the line from (x, y) moving by (dx, dy) that
+// lies within the rectangle from the origin to (width, height), as how far
+// along the line, from 0 to 1, it enters and leaves. It returns false if
+// the line misses the rectangle.
+func clipLine(x, y, dx, dy, width, height float64) (float64, float64, bool) {
+	enter, leave := 0.0, 1.0
+	// Each edge as the rate the line approaches it and the distance to it
+	for _, edge := range [4][2]float64{{-dx, x}, {dx, width - x}, {-dy, y}, {dy, height - y}} {
+		rate, dist := edge[0], edge[1]
+		if rate == 0 {
+			if dist < 0 {
+				return 0, 0, false
+			}
+			continue
+		}
+		t := dist / rate
+		if rate < 0 {
+			enter = math.Max(enter, t)
+		} else {
+			leave = math.Min(leave, t)
+		}
+	}
+	return enter, leave, enter <= leave
+}
+
+// gridStep returns the direction a line moving by d from p crosses cell
+// edges in, how far along the line it first crosses one and the distance
+// between crossings.
+func gridStep(p, d float64) (int, float64, float64) {
+	switch {
+	case d > 0:
+		return 1, (math.Floor(p) + 1 - p) / d, 1 / d
+	case d < 0:
+		return -1, (p - math.Floor(p)) / -d, -1 / d
+	default:
+		return 0, math.Inf(1), math.Inf(1)
+	}
+}
+
+// blocks reports whether cell (x, y) rises above elevation z, and names the
+// object responsible if it is not the terrain.
+func (w *World) blocks(x, y int, z float64) (string, bool) {
+	tile, ok := w.TileAt(x, y)
+	if !ok {
+		return "", false
+	}
+	if tile.Height > z {
+		return "", true
+	}
+	cell := Rect{MinX: float64(x), MinY: float64(y), MaxX: float64(x + 1), MaxY: float64(y + 1)}
+	for _, o := range w.ObjectsIn(cell) {
+		if !w.Collidable(o) {
+			continue
+		}
+		top := math.Inf(1)
+		v, _ := w.ObjectProperty(o, "height")
+		if h, ok := number(v); ok {
+			top = tile.Height + h
+		}
+		if top > z {
+			return o.ID, true
+		}
+	}
+	return "", false
+}
+
+// number reads a numeric property, which JSON definitions decode as float64
+// and YAML manifests as int or float64.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// LineOfSight reports whether nothing blocks the straight line between two
+// points, as described for Raycast.
+func (w *World) LineOfSight(from, to Vec3) bool {
+	_, blocked := w.Raycast(from, to)
+	return !blocked
+}
+
+// InView reports whether p lies within the range and view cone of an agent
+// at its position, facing along a.Facing. Facing is measured in radians
+// from the positive X axis towards the positive Y axis. Obstacles are not
+// considered.
+func (s Sight) InView(a *Agent, p Vec3) bool {
+	dx, dy := p.X-a.Position.X, p.Y-a.Position.Y
+	dist := math.Hypot(dx, dy)
+	if s.Range > 0 && dist > s.Range {
+		return false
+	}
+	if s.FOV <= 0 || s.FOV >= 2*math.Pi || dist == 0 {
+		return true
+	}
+	off := math.Remainder(math.Atan2(dy, dx)-a.Facing, 2*math.Pi)
+	return math.Abs(off) <= s.FOV/2
+}
+
+// CanSee reports whether agent a sees point p: p is in view and the line
+// from a's eyes to p is clear.
+func (w *World) CanSee(a *Agent, s Sight, p Vec3) bool {
+	if !s.InView(a, p) {
+		return false
+	}
+	eye := a.Position
+	eye.Z += s.EyeHeight
+	return w.LineOfSight(eye, p)
+}
+
+// VisibleAgents returns the other agents a sees, in the order they were
+// added.
+func (w *World) VisibleAgents(a *Agent, s Sight) []*Agent {
+	var out []*Agent
+	for _, other := range w.candidateAgents(a, s) {
+		if other != a && w.CanSee(a, s, other.Position) {
+			out = append(out, other)
+		}
+	}
+	return out
+}
+
+// VisibleObjects returns the objects a sees, in the order they were added.
+// An object blocking the line to itself does not hide it.
+func (w *World) VisibleObjects(a *Agent, s Sight) []*Object {
+	var out []*Object
+	for _, o := range w.candidateObjects(a, s) {
+		if w.CanSee(a, s, o.Position) {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func (w *World) candidateAgents(a *Agent, s Sight) []*Agent {
+	if s.Range > 0 {
+		return w.AgentsWithin(a.Position, s.Range)
+	}
+	return w.agents
+}
+
+func (w *World) candidateObjects(a *Agent, s Sight) []*Object {
+	if s.Range > 0 {
+		return w.ObjectsWithin(a.Position, s.Range)
+	}
+	return w.objects
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,174 @@
+package world
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlatWorld returns an empty grass map of the given size
+func newFlatWorld(t *testing.T, width, height int) *World {
+	t.Helper()
+	w, err := New(&EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: width, Height: height}}, nil, nil)
+	require.NoError(t, err)
+	return w
+}
+
+func TestRaycastTerrain(t *testing.T) {
+	w := newFlatWorld(t, 6, 3)
+	from, to := Vec3{X: 0.5, Y: 0.5}, Vec3{X: 5.5, Y: 0.5}
+	assert.True(t, w.LineOfSight(from, to))
+
+	require.NoError(t, w.SetTile(2, 0, Tile{Type: "stone", Height: 2}))
+	hit, blocked := w.Raycast(from, to)
+	require.True(t, blocked)
+	assert.Equal(t, Hit{X: 2, Y: 0, Point: Vec3{X: 2, Y: 0.5}}, hit)
+	assert.False(t, w.LineOfSight(to, from), "lines of sight are symmetric")
+
+	assert.True(t, w.LineOfSight(Vec3{X: 0.5, Y: 0.5, Z: 2.5}, Vec3{X: 5.5, Y: 0.5, Z: 2.5}), "high enough to see over")
+	assert.False(t, w.LineOfSight(Vec3{X: 0.5, Y: 0.5, Z: 2.5}, Vec3{X: 3.5, Y: 0.5}), "the line descends below the wall")
+	assert.True(t, w.LineOfSight(Vec3{X: 2.5, Y: 0.5}, to), "the viewer's own cell never blocks")
+	assert.True(t, w.LineOfSight(Vec3{X: 0.5, Y: 1.5}, Vec3{X: 5.5, Y: 2.5}), "lines passing beside the wall are clear")
+
+	// A point's height is measured from the ground beneath it
+	require.NoError(t, w.SetTile(0, 2, Tile{Type: "stone", Height: 3}))
+	assert.True(t, w.LineOfSight(Vec3{X: 0.5, Y: 2.5}, Vec3{X: 2.5, Y: 0.5, Z: 2}))
+}
+
+func TestRaycastOffMap(t *testing.T) {
+	w := newFlatWorld(t, 6, 3)
+	require.NoError(t, w.SetTile(0, 1, Tile{Type: "stone", Height: 2}))
+	require.NoError(t, w.SetTile(4, 1, Tile{Type: "stone", Height: 2}))
+
+	hit, blocked := w.Raycast(Vec3{X: -10, Y: 1.5}, Vec3{X: 20, Y: 1.5})
+	require.True(t, blocked)
+	assert.Equal(t, Hit{X: 0, Y: 1, Point: Vec3{X: 0, Y: 1.5}}, hit, "the first cell on the map can block")
+	hit, blocked = w.Raycast(Vec3{X: 20, Y: 1.5}, Vec3{X: -10, Y: 1.5})
+	require.True(t, blocked)
+	assert.Equal(t, 4, hit.X)
+
+	assert.True(t, w.LineOfSight(Vec3{X: -10, Y: -1}, Vec3{X: 20, Y: -1}), "lines that miss the map are clear")
+	assert.True(t, w.LineOfSight(Vec3{X: 2.5, Y: 1.5}, Vec3{X: 2.5, Y: 1e12}))
+
+	start := time.Now()
+	_, blocked = w.Raycast(Vec3{X: 0.5, Y: 0.5}, Vec3{X: 3e8, Y: 0.5})
+	assert.False(t, blocked)
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "only the cells on the map are walked")
+}
+
+func TestRaycastObjects(t *testing.T) {
+	w := newFlatWorld(t, 6, 3)
+	require.NoError(t, w.AddObject(&Object{ID: "bush", Position: Vec3{X: 2.5, Y: 0.5}}))
+	from, to := Vec3{X: 0.5, Y: 0.5}, Vec3{X: 5.5, Y: 0.5}
+	assert.True(t, w.LineOfSight(from, to), "objects without collision do not block")
+
+	require.NoError(t, w.AddObject(&Object{ID: "crate", Position: Vec3{X: 3.5, Y: 0.5},
+		Properties: map[string]interface{}{"collision": true, "height": 1.0}}))
+	hit, blocked := w.Raycast(from, to)
+	require.True(t, blocked)
+	assert.Equal(t, "crate", hit.Object)
+	assert.Equal(t, 3, hit.X)
+	assert.True(t, w.LineOfSight(Vec3{X: 0.5, Y: 0.5, Z: 1.5}, Vec3{X: 5.5, Y: 0.5, Z: 1.5}), "the crate is 1 high")
+	assert.True(t, w.LineOfSight(from, Vec3{X: 3.5, Y: 0.5}), "an object does not hide itself")
+
+	require.NoError(t, w.AddObject(&Object{ID: "pillar", Position: Vec3{X: 4.5, Y: 0.5},
+		Properties: map[string]interface{}{"collision": true}}))
+	hit, _ = w.Raycast(Vec3{X: 5.5, Y: 0.5, Z: 100}, from)
+	assert.Equal(t, "pillar", hit.Object, "objects without a height have no top")
+}
+
+func TestRaycastModelDefaults(t *testing.T) {
+	models := mustModelRegistry(t, []Model{
+		{Name: "wall", Collision: Collision{Shape: ShapeBox}, Properties: map[string]interface{}{"collision": true, "height": 1.0}},
+	})
+	w, err := New(&EnvironmentSchemaJson{
+		Map:     EnvironmentSchemaJsonMap{Width: 6, Height: 3},
+		Objects: []EnvironmentSchemaJsonObjectsElem{{Id: "wall", Model: "wall", Position: EnvironmentSchemaJsonObjectsElemPosition{X: 2.5, Y: 0.5}}},
+	}, nil, models)
+	require.NoError(t, err)
+
+	wall, _ := w.ObjectByID("wall")
+	assert.True(t, w.Collidable(wall))
+	hit, blocked := w.Raycast(Vec3{X: 0.5, Y: 0.5}, Vec3{X: 5.5, Y: 0.5})
+	require.True(t, blocked, "the model makes the wall collidable")
+	assert.Equal(t, "wall", hit.Object)
+	assert.True(t, w.LineOfSight(Vec3{X: 0.5, Y: 0.5, Z: 1.5}, Vec3{X: 5.5, Y: 0.5, Z: 1.5}), "the model's height applies")
+}
+
+func TestRaycastIntegerHeight(t *testing.T) {
+	models, err := ParseModelRegistry([]byte("models:\n  rock: {properties: {collision: true, height: 4}}\n"))
+	require.NoError(t, err)
+	w, err := New(&EnvironmentSchemaJson{
+		Map:     EnvironmentSchemaJsonMap{Width: 6, Height: 3},
+		Objects: []EnvironmentSchemaJsonObjectsElem{{Id: "rock", Model: "rock", Position: EnvironmentSchemaJsonObjectsElemPosition{X: 2.5, Y: 0.5}}},
+	}, nil, models)
+	require.NoError(t, err)
+
+	from, to := Vec3{X: 0.5, Y: 0.5}, Vec3{X: 5.5, Y: 0.5}
+	assert.False(t, w.LineOfSight(from, to))
+	from.Z, to.Z = 5, 5
+	assert.True(t, w.LineOfSight(from, to), "a manifest height of 4 is 4 high")
+
+	require.NoError(t, w.AddObject(&Object{ID: "post", Position: Vec3{X: 3.5, Y: 0.5},
+		Properties: map[string]interface{}{"collision": true, "height": json.Number("6")}}))
+	hit, blocked := w.Raycast(from, to)
+	require.True(t, blocked)
+	assert.Equal(t, "post", hit.Object)
+}
+
+func TestSightInView(t *testing.T) {
+	a := &Agent{Position: Vec3{X: 5, Y: 5}, Facing: math.Pi / 2}
+	cone := Sight{Range: 3, FOV: math.Pi / 2}
+
+	assert.True(t, cone.InView(a, Vec3{X: 5, Y: 7}), "straight ahead")
+	assert.True(t, cone.InView(a, Vec3{X: 5.5, Y: 7}))
+	assert.False(t, cone.InView(a, Vec3{X: 7, Y: 5}), "to the side")
+	assert.False(t, cone.InView(a, Vec3{X: 5, Y: 3}), "behind")
+	assert.False(t, cone.InView(a, Vec3{X: 5, Y: 8.5}), "out of range")
+	assert.True(t, cone.InView(a, a.Position))
+
+	behind := &Agent{Position: Vec3{X: 5, Y: 5}, Facing: -math.Pi}
+	assert.True(t, cone.InView(behind, Vec3{X: 3, Y: 5.2}), "angles wrap around")
+
+	all := Sight{}
+	assert.True(t, all.InView(a, Vec3{X: 5, Y: -100}))
+}
+
+func TestVisibleEntities(t *testing.T) {
+	w := newTestWorld(t)
+	scout, _ := w.AgentByID("scout-01")
+	worker, _ := w.AgentByID("worker-01")
+
+	agentIDs := func(agents []*Agent) []string {
+		out := []string{}
+		for _, a := range agents {
+			out = append(out, a.ID)
+		}
+		return out
+	}
+	objectIDs := func(objects []*Object) []string {
+		out := []string{}
+		for _, o := range objects {
+			out = append(out, o.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"worker-01"}, agentIDs(w.VisibleAgents(scout, Sight{FOV: math.Pi})))
+	assert.Empty(t, w.VisibleAgents(scout, Sight{FOV: math.Pi / 4}), "the worker is 45° off the scout's facing")
+	assert.Equal(t, []string{"scout-01"}, agentIDs(w.VisibleAgents(worker, Sight{FOV: math.Pi})))
+	assert.Empty(t, w.VisibleAgents(worker, Sight{FOV: math.Pi / 2}))
+
+	assert.Equal(t, []string{"rock-001", "tree-001"}, objectIDs(w.VisibleObjects(scout, Sight{})))
+	assert.Equal(t, []string{"rock-001"}, objectIDs(w.VisibleObjects(scout, Sight{Range: 3})))
+
+	// The rock hides what is behind it
+	require.True(t, w.MoveAgent("worker-01", Vec3{X: 5.5, Y: 0.5}))
+	assert.Empty(t, w.VisibleAgents(scout, Sight{Range: 10, EyeHeight: 1}))
+	assert.True(t, w.MoveObject("rock-001", Vec3{X: 3.5, Y: 8.5}))
+	assert.Equal(t, []string{"worker-01"}, agentIDs(w.VisibleAgents(scout, Sight{Range: 10, EyeHeight: 1})))
+}
@@ -0,0 +1,455 @@
+package world
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultTileType is the type of map cells a definition does not list, unless
+// the tile registry names another.
+const DefaultTileType = "grass"
+
+const (
+	// MaxMapDimension is the largest width or height a map may have.
+	MaxMapDimension = 4096
+	// MaxMapCells is the largest number of cells a map may have. It bounds
+	// the memory of the tile grid, which New allocates in full.
+	MaxMapCells = 1 << 20
+)
+
+// Tile is one cell of the map grid.
+type Tile struct {
+	Type   string
+	Height float64
+	Tags   []string
+	// Defined is false for cells filled in with the default tile type.
+	Defined bool
+}
+
+// Vec3 is a position in tile units. Z is height above the ground.
+type Vec3 struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Object is a static entity placed on the map.
+type Object struct {
+	ID         string
+	Model      string
+	Position   Vec3
+	Rotation   float64
+	Tags       []string
+	Properties map[string]interface{}
+}
+
+// Agent is an entity driven by a behavior.
+type Agent struct {
+	ID       string
+	Model    string
+	Behavior string
+	Position Vec3
+	// Facing is the heading in radians, zero when the definition omits it.
+	Facing float64
+	Tags   []string
+	State  map[string]interface{}
+}
+
+// Rect is an axis-aligned area of the map in tile units. It includes its
+// minimum edges and excludes its maximum edges, so adjacent rectangles never
+// both contain a point.
+type Rect struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// Contains reports whether the point (x, y) lies in r.
+func (r Rect) Contains(x, y float64) bool {
+	return x >= r.MinX && x < r.MaxX && y >= r.MinY && y < r.MaxY
+}
+
+// World is the in-memory state of an environment: a dense tile grid and the
+// objects and agents on it, indexed by ID and by position. It is not safe for
+// concurrent mutation. Entities must be moved through MoveObject and
+// MoveAgent so that position queries see the change.
+type World struct {
+	Width, Height int
+	TileSize      float64
+
+	tileTypes *TileRegistry
+	models    *ModelRegistry
+	tiles     []Tile // row-major, y*Width + x
+	objects   []*Object
+	agents    []*Agent
+	// objectIndex and agentIndex map IDs to positions in objects and agents
+	objectIndex map[string]int
+	agentIndex  map[string]int
+	// objectGrid and agentGrid index entity positions by ID
+	objectGrid *SpatialIndex
+	agentGrid  *SpatialIndex
+	// terrainVersion counts changes to tiles and collidable objects
+	terrainVersion uint64
+}
+
+// New builds a World from a decoded environment definition using the given
+// tile types, or DefaultTiles if tiles is nil. Objects fall back to the
+// properties of their model in models, which may be nil. It rejects
+// definitions the grid cannot represent, such as tiles off the map, unknown
+// tile types or two entities of one kind sharing an ID; a definition that
+// passes a Validator with the same tile types always builds.
+func New(env *EnvironmentSchemaJson, tiles *TileRegistry, models *ModelRegistry) (*World, error) {
+	if tiles == nil {
+		tiles = DefaultTiles
+	}
+	width, height := env.Map.Width, env.Map.Height
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("map must be at least 1x1, got %dx%d", width, height)
+	}
+	if width > MaxMapDimension || height > MaxMapDimension {
+		return nil, fmt.Errorf("map must be at most %dx%d, got %dx%d", MaxMapDimension, MaxMapDimension, width, height)
+	}
+	if width*height > MaxMapCells {
+		return nil, fmt.Errorf("map must have at most %d cells, got %dx%d", MaxMapCells, width, height)
+	}
+	tileSize := env.Map.TileSize
+	if tileSize == 0 {
+		tileSize = 1
+	}
+
+	w := &World{
+		Width:       width,
+		Height:      height,
+		TileSize:    tileSize,
+		tileTypes:   tiles,
+		models:      models,
+		tiles:       make([]Tile, width*height),
+		objects:     make([]*Object, 0, len(env.Objects)),
+		agents:      make([]*Agent, 0, len(env.Agents)),
+		objectIndex: make(map[string]int, len(env.Objects)),
+		agentIndex:  make(map[string]int, len(env.Agents)),
+		objectGrid:  NewSpatialIndex(DefaultSpatialCellSize),
+		agentGrid:   NewSpatialIndex(DefaultSpatialCellSize),
+	}
+	fallback := tiles.Default().Name
+	for i := range w.tiles {
+		w.tiles[i] = Tile{Type: fallback}
+	}
+
+	for i, t := range env.Map.Tiles {
+		if !w.InBounds(t.X, t.Y) {
+			return nil, fmt.Errorf("tile %d at (%d,%d) is outside the %dx%d map", i, t.X, t.Y, width, height)
+		}
+		if _, ok := tiles.Lookup(t.Type); !ok {
+			return nil, fmt.Errorf("tile %d has unknown type %q", i, t.Type)
+		}
+		cell := &w.tiles[t.Y*width+t.X]
+		if cell.Defined {
+			return nil, fmt.Errorf("tile %d duplicates cell (%d,%d)", i, t.X, t.Y)
+		}
+		*cell = Tile{Type: t.Type, Tags: t.Tags, Defined: true}
+		if t.Height != nil {
+			cell.Height = *t.Height
+		}
+	}
+
+	for _, o := range env.Objects {
+		err := w.AddObject(&Object{
+			ID:         o.Id,
+			Model:      o.Model,
+			Position:   Vec3{X: o.Position.X, Y: o.Position.Y, Z: o.Position.Z},
+			Rotation:   o.Rotation,
+			Tags:       o.Tags,
+			Properties: o.Properties,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range env.Agents {
+		agent := &Agent{
+			ID:       a.Id,
+			Model:    a.Model,
+			Behavior: a.Behavior,
+			Position: Vec3{X: a.Position.X, Y: a.Position.Y, Z: a.Position.Z},
+			Tags:     a.Tags,
+			State:    a.State,
+		}
+		if a.Facing != nil {
+			agent.Facing = *a.Facing
+		}
+		if err := w.AddAgent(agent); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// InBounds reports whether the cell (x, y) is on the map.
+func (w *World) InBounds(x, y int) bool {
+	return x >= 0 && x < w.Width && y >= 0 && y < w.Height
+}
+
+// Bounds returns the whole map as a Rect.
+func (w *World) Bounds() Rect {
+	return Rect{MaxX: float64(w.Width), MaxY: float64(w.Height)}
+}
+
+// TileAt returns the tile at cell (x, y). It returns false for cells off the
+// map.
+func (w *World) TileAt(x, y int) (Tile, bool) {
+	if !w.InBounds(x, y) {
+		return Tile{}, false
+	}
+	return w.tiles[y*w.Width+x], true
+}
+
+// SetTile replaces the tile at cell (x, y). The tile is marked as defined.
+func (w *World) SetTile(x, y int, t Tile) error {
+	if !w.InBounds(x, y) {
+		return fmt.Errorf("cell (%d,%d) is outside the %dx%d map", x, y, w.Width, w.Height)
+	}
+	if _, ok := w.tileTypes.Lookup(t.Type); !ok {
+		return fmt.Errorf("unknown tile type %q", t.Type)
+	}
+	t.Defined = true
+	w.tiles[y*w.Width+x] = t
+	w.terrainVersion++
+	return nil
+}
+
+// TileTypes returns the tile types the map uses.
+func (w *World) TileTypes() *TileRegistry {
+	return w.tileTypes
+}
+
+// TerrainVersion changes whenever a tile or a collidable object changes, so
+// anything derived from the terrain, such as a path, can be cached against
+// it. Changes to an object's collision property are not noticed.
+func (w *World) TerrainVersion() uint64 {
+	return w.terrainVersion
+}
+
+// TileTypeAt returns the properties of the tile at cell (x, y). It returns
+// false for cells off the map.
+func (w *World) TileTypeAt(x, y int) (TileType, bool) {
+	tile, ok := w.TileAt(x, y)
+	if !ok {
+		return TileType{}, false
+	}
+	return w.tileTypes.Lookup(tile.Type)
+}
+
+// CanEnter reports whether cell (x, y) is on the map and can be crossed with
+// movement m. Objects are not considered.
+func (w *World) CanEnter(x, y int, m Movement) bool {
+	_, ok := w.MoveCost(x, y, m)
+	return ok
+}
+
+// MoveCost returns the cost of entering cell (x, y) with movement m, or false
+// if the cell is off the map or does not allow m.
+func (w *World) MoveCost(x, y int, m Movement) (float64, bool) {
+	t, ok := w.TileTypeAt(x, y)
+	if !ok {
+		return 0, false
+	}
+	return t.MoveCost(m)
+}
+
+// CellOf returns the cell containing the point (x, y).
+func CellOf(x, y float64) (int, int) {
+	return int(math.Floor(x)), int(math.Floor(y))
+}
+
+// Objects returns every object in the order they were added.
+func (w *World) Objects() []*Object {
+	return w.objects
+}
+
+// ObjectProperty returns the value of key for o, falling back to the
+// defaults of its model.
+func (w *World) ObjectProperty(o *Object, key string) (interface{}, bool) {
+	return w.models.Property(o.Model, o.Properties, key)
+}
+
+// Collidable reports whether agents are kept out of the cell of o and
+// whether it blocks line of sight. See ModelRegistry.Collidable.
+func (w *World) Collidable(o *Object) bool {
+	return w.models.Collidable(o.Model, o.Properties)
+}
+
+// ObjectByID returns the object with the given ID.
+func (w *World) ObjectByID(id string) (*Object, bool) {
+	i, ok := w.objectIndex[id]
+	if !ok {
+		return nil, false
+	}
+	return w.objects[i], true
+}
+
+// AddObject places o on the world. It fails if the ID is taken.
+func (w *World) AddObject(o *Object) error {
+	if _, dup := w.objectIndex[o.ID]; dup {
+		return fmt.Errorf("duplicate object id %q", o.ID)
+	}
+	w.objectIndex[o.ID] = len(w.objects)
+	w.objects = append(w.objects, o)
+	w.objectGrid.Insert(o.ID, o.Position)
+	if w.Collidable(o) {
+		w.terrainVersion++
+	}
+	return nil
+}
+
+// MoveObject sets the position of an object and reports whether it exists.
+func (w *World) MoveObject(id string, p Vec3) bool {
+	o, ok := w.ObjectByID(id)
+	if !ok {
+		return false
+	}
+	o.Position = p
+	w.objectGrid.Move(id, p)
+	if w.Collidable(o) {
+		w.terrainVersion++
+	}
+	return true
+}
+
+// RemoveObject takes an object off the world and reports whether it existed.
+func (w *World) RemoveObject(id string) bool {
+	i, ok := w.objectIndex[id]
+	if !ok {
+		return false
+	}
+	if w.Collidable(w.objects[i]) {
+		w.terrainVersion++
+	}
+	w.objects = removeAt(w.objects, w.objectIndex, i, func(o *Object) string { return o.ID })
+	w.objectGrid.Remove(id)
+	return true
+}
+
+// ObjectsIn returns the objects positioned inside r, in the order they were
+// added.
+func (w *World) ObjectsIn(r Rect) []*Object {
+	return lookup(w.objectGrid.InRect(r), w.objects, w.objectIndex)
+}
+
+// ObjectsWithin returns the objects at most radius tiles from center on the
+// map plane, in the order they were added.
+func (w *World) ObjectsWithin(center Vec3, radius float64) []*Object {
+	return lookup(w.objectGrid.InRadius(center, radius), w.objects, w.objectIndex)
+}
+
+// NearestObjects returns up to k objects closest to p on the map plane,
+// nearest first.
+func (w *World) NearestObjects(p Vec3, k int) []*Object {
+	ids := w.objectGrid.Nearest(p, k)
+	out := make([]*Object, len(ids))
+	for i, id := range ids {
+		out[i] = w.objects[w.objectIndex[id]]
+	}
+	return out
+}
+
+// Agents returns every agent in the order they were added.
+func (w *World) Agents() []*Agent {
+	return w.agents
+}
+
+// AgentByID returns the agent with the given ID.
+func (w *World) AgentByID(id string) (*Agent, bool) {
+	i, ok := w.agentIndex[id]
+	if !ok {
+		return nil, false
+	}
+	return w.agents[i], true
+}
+
+// AddAgent places a on the world. It fails if the ID is taken.
+func (w *World) AddAgent(a *Agent) error {
+	if _, dup := w.agentIndex[a.ID]; dup {
+		return fmt.Errorf("duplicate agent id %q", a.ID)
+	}
+	w.agentIndex[a.ID] = len(w.agents)
+	w.agents = append(w.agents, a)
+	w.agentGrid.Insert(a.ID, a.Position)
+	return nil
+}
+
+// MoveAgent sets the position of an agent and reports whether it exists.
+func (w *World) MoveAgent(id string, p Vec3) bool {
+	a, ok := w.AgentByID(id)
+	if !ok {
+		return false
+	}
+	a.Position = p
+	w.agentGrid.Move(id, p)
+	return true
+}
+
+// RemoveAgent takes an agent off the world and reports whether it existed.
+func (w *World) RemoveAgent(id string) bool {
+	i, ok := w.agentIndex[id]
+	if !ok {
+		return false
+	}
+	w.agents = removeAt(w.agents, w.agentIndex, i, func(a *Agent) string { return a.ID })
+	w.agentGrid.Remove(id)
+	return true
+}
+
+// AgentsIn returns the agents positioned inside r, in the order they were
+// added.
+func (w *World) AgentsIn(r Rect) []*Agent {
+	return lookup(w.agentGrid.InRect(r), w.agents, w.agentIndex)
+}
+
+// AgentsWithin returns the agents at most radius tiles from center on the
+// map plane, in the order they were added.
+func (w *World) AgentsWithin(center Vec3, radius float64) []*Agent {
+	return lookup(w.agentGrid.InRadius(center, radius), w.agents, w.agentIndex)
+}
+
+// NearestAgents returns up to k agents closest to p on the map plane,
+// nearest first.
+func (w *World) NearestAgents(p Vec3, k int) []*Agent {
+	ids := w.agentGrid.Nearest(p, k)
+	out := make([]*Agent, len(ids))
+	for i, id := range ids {
+		out[i] = w.agents[w.agentIndex[id]]
+	}
+	return out
+}
+
+// lookup maps spatial query results back to entities, restoring the order
+// they were added in.
+func lookup[T any](ids []string, all []T, index map[string]int) []T {
+	if len(ids) == 0 {
+		return nil
+	}
+	pos := make([]int, len(ids))
+	for i, id := range ids {
+		pos[i] = index[id]
+	}
+	sort.Ints(pos)
+	out := make([]T, len(pos))
+	for i, p := range pos {
+		out[i] = all[p]
+	}
+	return out
+}
+
+// removeAt deletes all[i], keeping the rest in order, and updates index.
+func removeAt[T any](all []T, index map[string]int, i int, id func(T) string) []T {
+	delete(index, id(all[i]))
+	copy(all[i:], all[i+1:])
+	var zero T
+	all[len(all)-1] = zero
+	all = all[:len(all)-1]
+	for j := i; j < len(all); j++ {
+		index[id(all[j])] = j
+	}
+	return all
+}
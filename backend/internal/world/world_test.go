@@ -0,0 +1,220 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorld(t *testing.T) *World {
+	t.Helper()
+	env, err := DecodeEnvironment(readTestdata(t, "environment.json"))
+	require.NoError(t, err)
+	w, err := New(env, nil, nil)
+	require.NoError(t, err)
+	return w
+}
+
+func TestNewWorld(t *testing.T) {
+	w := newTestWorld(t)
+
+	assert.Equal(t, 10, w.Width)
+	assert.Equal(t, 10, w.Height)
+	assert.Equal(t, 1.0, w.TileSize)
+	assert.Len(t, w.Objects(), 2)
+	assert.Len(t, w.Agents(), 2)
+}
+
+func TestWorldTileAt(t *testing.T) {
+	w := newTestWorld(t)
+
+	tile, ok := w.TileAt(3, 0)
+	require.True(t, ok)
+	assert.Equal(t, Tile{Type: "stone", Height: 0.2, Defined: true}, tile)
+
+	tile, ok = w.TileAt(9, 9)
+	require.True(t, ok, "unlisted cells are filled with the default tile")
+	assert.Equal(t, Tile{Type: DefaultTileType}, tile)
+
+	for _, cell := range [][2]int{{-1, 0}, {0, -1}, {10, 0}, {0, 10}} {
+		_, ok := w.TileAt(cell[0], cell[1])
+		assert.False(t, ok, "cell %v is off the map", cell)
+	}
+}
+
+func TestWorldMovement(t *testing.T) {
+	w := newTestWorld(t)
+
+	assert.True(t, w.CanEnter(0, 0, Walk))
+	assert.False(t, w.CanEnter(4, 0, Walk), "water is not walkable")
+	assert.True(t, w.CanEnter(4, 0, Swim))
+	assert.True(t, w.CanEnter(4, 0, Fly))
+	assert.False(t, w.CanEnter(10, 0, Fly), "off the map")
+
+	cost, ok := w.MoveCost(3, 0, Walk)
+	require.True(t, ok)
+	assert.Equal(t, 1.5, cost)
+	cost, ok = w.MoveCost(3, 0, Fly)
+	require.True(t, ok)
+	assert.Equal(t, 1.0, cost, "flying ignores terrain")
+}
+
+func TestWorldEntities(t *testing.T) {
+	w := newTestWorld(t)
+
+	rock, ok := w.ObjectByID("rock-001")
+	require.True(t, ok)
+	assert.Equal(t, Vec3{X: 3.5, Y: 0.5}, rock.Position)
+	assert.True(t, w.Collidable(rock))
+
+	tree, ok := w.ObjectByID("tree-001")
+	require.True(t, ok)
+	assert.False(t, w.Collidable(tree))
+
+	worker, ok := w.AgentByID("worker-01")
+	require.True(t, ok)
+	assert.Equal(t, "resource_gathering", worker.Behavior)
+	assert.Equal(t, 3.14, worker.Facing)
+
+	_, ok = w.AgentByID("rock-001")
+	assert.False(t, ok, "objects and agents have separate ID spaces")
+}
+
+func TestWorldQueries(t *testing.T) {
+	w := newTestWorld(t)
+
+	ids := func(objects []*Object) []string {
+		var out []string
+		for _, o := range objects {
+			out = append(out, o.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"rock-001", "tree-001"}, ids(w.ObjectsIn(w.Bounds())))
+	assert.Equal(t, []string{"rock-001"}, ids(w.ObjectsIn(Rect{MinX: 3, MinY: 0, MaxX: 4, MaxY: 1})))
+	assert.Empty(t, w.ObjectsIn(Rect{MinX: 4, MinY: 0, MaxX: 6, MaxY: 2}), "maximum edges are excluded")
+
+	agents := w.AgentsIn(Rect{MinX: 2, MinY: 2, MaxX: 3, MaxY: 3})
+	require.Len(t, agents, 1)
+	assert.Equal(t, "worker-01", agents[0].ID)
+}
+
+func TestNewWorldRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		env  EnvironmentSchemaJson
+		want string
+	}{
+		{
+			name: "tile off the map",
+			env: EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: 2, Height: 2,
+				Tiles: []EnvironmentSchemaJsonMapTilesElem{{X: 2, Y: 0, Type: "grass"}}}},
+			want: "outside the 2x2 map",
+		},
+		{
+			name: "duplicate tile",
+			env: EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: 2, Height: 2,
+				Tiles: []EnvironmentSchemaJsonMapTilesElem{{X: 1, Y: 1, Type: "grass"}, {X: 1, Y: 1, Type: "dirt"}}}},
+			want: "duplicates cell (1,1)",
+		},
+		{
+			name: "unknown tile type",
+			env: EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: 2, Height: 2,
+				Tiles: []EnvironmentSchemaJsonMapTilesElem{{X: 0, Y: 0, Type: "lava"}}}},
+			want: `unknown type "lava"`,
+		},
+		{
+			name: "duplicate agent",
+			env: EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: 2, Height: 2},
+				Agents: []EnvironmentSchemaJsonAgentsElem{{Id: "a"}, {Id: "a"}}},
+			want: `duplicate agent id "a"`,
+		},
+		{
+			name: "empty map",
+			env:  EnvironmentSchemaJson{},
+			want: "at least 1x1",
+		},
+		{
+			name: "oversized dimension",
+			env:  EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: 1 << 32, Height: 1 << 32}},
+			want: "at most 4096x4096",
+		},
+		{
+			name: "too many cells",
+			env:  EnvironmentSchemaJson{Map: EnvironmentSchemaJsonMap{Width: 4096, Height: 4096}},
+			want: "at most 1048576 cells",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&tt.env, nil, nil)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestWorldSpatialQueries(t *testing.T) {
+	w := newTestWorld(t)
+
+	agentIDs := func(agents []*Agent) []string {
+		out := []string{}
+		for _, a := range agents {
+			out = append(out, a.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"scout-01", "worker-01"}, agentIDs(w.AgentsWithin(Vec3{X: 2, Y: 2}, 2)))
+	assert.Equal(t, []string{"worker-01", "scout-01"}, agentIDs(w.NearestAgents(Vec3{X: 3, Y: 3}, 5)))
+	nearest := w.NearestObjects(Vec3{X: 6, Y: 1}, 1)
+	require.Len(t, nearest, 1)
+	assert.Equal(t, "tree-001", nearest[0].ID)
+	assert.Len(t, w.ObjectsWithin(Vec3{X: 5, Y: 1}, 1.6), 2)
+
+	// Moves, additions and removals are visible to queries
+	require.True(t, w.MoveAgent("scout-01", Vec3{X: 9, Y: 9}))
+	assert.Equal(t, []string{"worker-01"}, agentIDs(w.AgentsWithin(Vec3{X: 2, Y: 2}, 2)))
+	scout, _ := w.AgentByID("scout-01")
+	assert.Equal(t, Vec3{X: 9, Y: 9}, scout.Position)
+
+	require.NoError(t, w.AddAgent(&Agent{ID: "new-01", Position: Vec3{X: 8.5, Y: 9}}))
+	assert.ErrorContains(t, w.AddAgent(&Agent{ID: "new-01"}), `duplicate agent id "new-01"`)
+	assert.Equal(t, []string{"scout-01", "new-01"}, agentIDs(w.AgentsIn(Rect{MinX: 8, MinY: 8, MaxX: 10, MaxY: 10})))
+
+	require.True(t, w.RemoveAgent("scout-01"))
+	assert.False(t, w.RemoveAgent("scout-01"))
+	assert.False(t, w.MoveAgent("scout-01", Vec3{}))
+	assert.Equal(t, []string{"worker-01", "new-01"}, agentIDs(w.Agents()))
+	worker, ok := w.AgentByID("worker-01")
+	require.True(t, ok, "IDs still resolve after a removal")
+	assert.Equal(t, "worker-01", worker.ID)
+	assert.Equal(t, []string{"new-01"}, agentIDs(w.AgentsIn(Rect{MinX: 8, MinY: 8, MaxX: 10, MaxY: 10})))
+
+	require.True(t, w.MoveObject("rock-001", Vec3{X: 0.5, Y: 9.5}))
+	assert.Empty(t, w.ObjectsIn(Rect{MinX: 3, MinY: 0, MaxX: 4, MaxY: 1}))
+	require.True(t, w.RemoveObject("tree-001"))
+	assert.Len(t, w.ObjectsIn(w.Bounds()), 1)
+}
+
+func TestWorldSetTile(t *testing.T) {
+	w := newTestWorld(t)
+	version := w.TerrainVersion()
+
+	require.NoError(t, w.SetTile(9, 9, Tile{Type: "water", Height: -1}))
+	tile, _ := w.TileAt(9, 9)
+	assert.Equal(t, Tile{Type: "water", Height: -1, Defined: true}, tile)
+	assert.False(t, w.CanEnter(9, 9, Walk))
+	assert.NotEqual(t, version, w.TerrainVersion())
+
+	assert.ErrorContains(t, w.SetTile(10, 0, Tile{Type: "grass"}), "outside the 10x10 map")
+	assert.ErrorContains(t, w.SetTile(0, 0, Tile{Type: "lava"}), `unknown tile type "lava"`)
+
+	// Only collidable objects change the terrain
+	version = w.TerrainVersion()
+	require.True(t, w.MoveObject("tree-001", Vec3{X: 7, Y: 7}))
+	assert.Equal(t, version, w.TerrainVersion())
+	require.True(t, w.MoveObject("rock-001", Vec3{X: 7, Y: 7}))
+	assert.NotEqual(t, version, w.TerrainVersion())
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+// Listing page sizes
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// sortableTime formats UTC times at fixed precision so they order correctly
+// as strings
+const sortableTime = "2006-01-02T15:04:05.000000000Z"
+
+// environmentSummary is the listing representation of a stored environment:
+// enough to render a library view without fetching every document
+type environmentSummary struct {
+	ID          string    `json:"id"`
+	Revision    int       `json:"revision"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `json:"name,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	TileCount   int       `json:"tile_count"`
+	ObjectCount int       `json:"object_count"`
+	AgentCount  int       `json:"agent_count"`
+}
+
+// summarize builds the listing entry of a stored environment
+func summarize(sum store.Summary) environmentSummary {
+	return environmentSummary{
+		ID:          sum.ID,
+		Revision:    sum.Revision,
+		CreatedAt:   sum.CreatedAt,
+		UpdatedAt:   sum.UpdatedAt,
+		Name:        sum.Name,
+		Author:      sum.Author,
+		Tags:        sum.Tags,
+		Width:       sum.Width,
+		Height:      sum.Height,
+		TileCount:   sum.TileCount,
+		ObjectCount: sum.ObjectCount,
+		AgentCount:  sum.AgentCount,
+	}
+}
+
+// listQuery is a parsed GET /environments query string
+type listQuery struct {
+	tags   []string
+	author string
+	q      string // lower-cased substring of the name
+	sort   string // created, updated or name
+	desc   bool
+	limit  int
+	after  *listCursor
+}
+
+// listCursor marks the last entry of a page. It is handed to clients as an
+// opaque token and only valid with the sort order that produced it.
+type listCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+// parseListQuery reads the filter, sort and pagination parameters. The error
+// message is suitable for a 400 response.
+func parseListQuery(values url.Values) (listQuery, error) {
+	lq := listQuery{
+		tags:   values["tag"],
+		author: values.Get("author"),
+		q:      strings.ToLower(values.Get("q")),
+		sort:   "created",
+		limit:  defaultListLimit,
+	}
+
+	if s := values.Get("sort"); s != "" {
+		lq.desc = strings.HasPrefix(s, "-")
+		lq.sort = strings.TrimPrefix(s, "-")
+		switch lq.sort {
+		case "created", "updated", "name":
+		default:
+			return listQuery{}, errors.New("sort must be one of created, updated or name, optionally prefixed with -")
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			return listQuery{}, errors.New("limit must be an integer between 1 and " + strconv.Itoa(maxListLimit))
+		}
+		lq.limit = n
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return listQuery{}, errors.New("invalid cursor")
+		}
+		if c.Sort != lq.order() {
+			return listQuery{}, errors.New("cursor was issued for a different sort order")
+		}
+		lq.after = &c
+	}
+	return lq, nil
+}
+
+// matches reports whether sum passes every filter of lq
+func (lq listQuery) matches(sum environmentSummary) bool {
+	for _, want := range lq.tags {
+		found := false
+		for _, tag := range sum.Tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if lq.author != "" && !strings.EqualFold(sum.Author, lq.author) {
+		return false
+	}
+	if lq.q != "" && !strings.Contains(strings.ToLower(sum.Name), lq.q) {
+		return false
+	}
+	return true
+}
+
+// sortKey returns the value entries are ordered by
+func (lq listQuery) sortKey(sum environmentSummary) string {
+	switch lq.sort {
+	case "updated":
+		return sum.UpdatedAt.UTC().Format(sortableTime)
+	case "name":
+		return strings.ToLower(sum.Name)
+	default:
+		return sum.CreatedAt.UTC().Format(sortableTime)
+	}
+}
+
+// less orders two entries by sort key, breaking ties by ID
+func (lq listQuery) less(aKey, aID, bKey, bID string) bool {
+	if aKey != bKey {
+		return (aKey < bKey) != lq.desc
+	}
+	if aID != bID {
+		return (aID < bID) != lq.desc
+	}
+	return false
+}
+
+// page filters, sorts and paginates stored environments. It returns the
+// entries of the requested page and the cursor for the next one, or "" on
+// the last page.
+func (lq listQuery) page(stored []store.Summary) ([]environmentSummary, string) {
+	type entry struct {
+		key string
+		sum environmentSummary
+	}
+	var entries []entry
+	for _, st := range stored {
+		sum := summarize(st)
+		if lq.matches(sum) {
+			entries = append(entries, entry{key: lq.sortKey(sum), sum: sum})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return lq.less(entries[i].key, entries[i].sum.ID, entries[j].key, entries[j].sum.ID)
+	})
+
+	start := 0
+	if lq.after != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return lq.less(lq.after.Key, lq.after.ID, entries[i].key, entries[i].sum.ID)
+		})
+	}
+	end := start + lq.limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	out := make([]environmentSummary, 0, end-start)
+	for _, e := range entries[start:end] {
+		out = append(out, e.sum)
+	}
+	if end == len(entries) {
+		return out, ""
+	}
+	last := entries[end-1]
+	return out, encodeCursor(listCursor{Sort: lq.order(), Key: last.key, ID: last.sum.ID})
+}
+
+// order returns the sort parameter in its canonical form
+func (lq listQuery) order() string {
+	if lq.desc {
+		return "-" + lq.sort
+	}
+	return lq.sort
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return listCursor{}, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return listCursor{}, err
+	}
+	if c.ID == "" {
+		return listCursor{}, errors.New("cursor has no position")
+	}
+	return c, nil
+}
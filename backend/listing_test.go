@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listResponse struct {
+	Environments []environmentSummary `json:"environments"`
+	NextCursor   string               `json:"next_cursor"`
+}
+
+func listNames(t *testing.T, h http.Handler, query string) listResponse {
+	t.Helper()
+	rr := doRequest(t, h, http.MethodGet, "/environments?"+query, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp listResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	return resp
+}
+
+func names(summaries []environmentSummary) []string {
+	out := make([]string, 0, len(summaries))
+	for _, s := range summaries {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestListEnvironments(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+
+	for _, doc := range []string{
+		`{"metadata":{"name":"Alpha Base","author":"Solo7","tags":["prototype","desert"]},"map":{"width":4,"height":3,"tiles":[{"x":0,"y":0,"type":"grass"}]},"objects":[],"agents":[]}`,
+		`{"metadata":{"name":"bravo","author":"someone","tags":["prototype"]},"map":{"width":2,"height":2,"tiles":[]},"objects":[{"id":"o","model":"m","position":{"x":0,"y":0}}],"agents":[]}`,
+		`{"metadata":{"name":"Charlie alpha","author":"solo7"},"map":{"width":2,"height":2,"tiles":[]},"objects":[],"agents":[{"id":"a","model":"m","behavior":"idle","position":{"x":1,"y":1}}]}`,
+	} {
+		_, err := s.environments.Create(t.Context(), json.RawMessage(doc))
+		require.NoError(t, err)
+	}
+
+	t.Run("summaries", func(t *testing.T) {
+		resp := listNames(t, h, "")
+		require.Len(t, resp.Environments, 3)
+		first := resp.Environments[0]
+		assert.Equal(t, "Alpha Base", first.Name)
+		assert.Equal(t, "Solo7", first.Author)
+		assert.Equal(t, []string{"prototype", "desert"}, first.Tags)
+		assert.Equal(t, 4, first.Width)
+		assert.Equal(t, 3, first.Height)
+		assert.Equal(t, 1, first.TileCount)
+		assert.Equal(t, 1, resp.Environments[1].ObjectCount)
+		assert.Equal(t, 1, resp.Environments[2].AgentCount)
+		assert.Empty(t, resp.NextCursor)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		tests := []struct {
+			query string
+			want  []string
+		}{
+			{"tag=prototype", []string{"Alpha Base", "bravo"}},
+			{"tag=prototype&tag=desert", []string{"Alpha Base"}},
+			{"author=SOLO7", []string{"Alpha Base", "Charlie alpha"}},
+			{"q=alpha", []string{"Alpha Base", "Charlie alpha"}},
+			{"q=alpha&tag=prototype", []string{"Alpha Base"}},
+			{"tag=missing", []string{}},
+		}
+		for _, tt := range tests {
+			assert.Equal(t, tt.want, names(listNames(t, h, tt.query).Environments), tt.query)
+		}
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		assert.Equal(t, []string{"Charlie alpha", "bravo", "Alpha Base"}, names(listNames(t, h, "sort=-created").Environments))
+		assert.Equal(t, []string{"Alpha Base", "bravo", "Charlie alpha"}, names(listNames(t, h, "sort=name").Environments))
+		assert.Equal(t, []string{"Charlie alpha", "bravo", "Alpha Base"}, names(listNames(t, h, "sort=-name").Environments))
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		for _, order := range []string{"created", "-name"} {
+			var got []string
+			query := url.Values{"sort": {order}, "limit": {"2"}}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 3, "pagination should terminate")
+				resp := listNames(t, h, query.Encode())
+				got = append(got, names(resp.Environments)...)
+				if resp.NextCursor == "" {
+					break
+				}
+				query.Set("cursor", resp.NextCursor)
+			}
+			assert.Equal(t, names(listNames(t, h, "sort="+order).Environments), got, order)
+		}
+	})
+
+	t.Run("bad parameters", func(t *testing.T) {
+		first := listNames(t, h, "limit=1")
+		require.NotEmpty(t, first.NextCursor)
+
+		for _, query := range []string{
+			"sort=size",
+			"limit=0",
+			"limit=1000",
+			"limit=x",
+			"cursor=not-a-cursor",
+			"sort=name&cursor=" + first.NextCursor,
+		} {
+			rr := doRequest(t, h, http.MethodGet, "/environments?"+query, "")
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+}
@@ -1,18 +1,64 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"io"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/assets"
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/envlog"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
 )
 
+// server holds the dependencies shared by the HTTP handlers
+type server struct {
+	environments store.EnvironmentStore
+	envLog       *envlog.Writer
+	validator    world.Validator
+	// adminToken is the bearer token for /admin endpoints; empty disables them
+	adminToken string
+	// maxBodyBytes caps the size of environment, patch and upload bodies
+	maxBodyBytes int64
+	// assets serves model files; nil disables /assets and uploads
+	assets   *assets.Store
+	uploadMu sync.Mutex
+	// simConfig is the base configuration of the simulations started
+	// through /simulations, and profiles the named variations of it
+	simConfig   config.Config
+	profiles    *config.Profiles
+	simMu       sync.Mutex
+	simulations map[string]*simulation
+}
+
+// defaultMaxBodyBytes is the request body limit of a new server, matching
+// server.max_body_mb. It leaves room for a 1000x1000 map with every tile
+// listed.
+const defaultMaxBodyBytes = 64 << 20
+
+// newServer creates a server backed by the given environment store that
+// records submissions in envLog
+func newServer(environments store.EnvironmentStore, envLog *envlog.Writer) *server {
+	return &server{
+		environments: environments,
+		envLog:       envLog,
+		maxBodyBytes: defaultMaxBodyBytes,
+		simConfig:    config.Default(),
+		simulations:  make(map[string]*simulation),
+	}
+}
+
 // corsMiddleware adds CORS headers to responses
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -21,6 +67,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		if origin != "" {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			// Let the frontend read the headers it needs for conditional requests
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Location, X-Request-ID, Content-Range")
 
 			// Handle preflight requests
 			if r.Method == http.MethodOptions {
@@ -39,7 +87,6 @@ func corsMiddleware(next http.Handler) http.Handler {
 				// Cache preflight response for 24 hours
 				w.Header().Set("Access-Control-Max-Age", "86400")
 
-
 				// End the request for preflight
 				w.WriteHeader(http.StatusOK)
 				return
@@ -51,19 +98,45 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func createHandler() http.Handler {
+func createHandler(s *server) http.Handler {
 	router := mux.NewRouter()
 
 	// Register routes with explicit methods
 	healthHandler := http.HandlerFunc(healthCheck)
-	environmentHandler := http.HandlerFunc(saveEnvironment)
 
 	// Apply CORS middleware to each route
 	router.Handle("/health", healthHandler).Methods("GET")
-	router.Handle("/environments", environmentHandler).Methods("POST")
+	router.HandleFunc("/environments", s.saveEnvironment).Methods("POST")
+	router.HandleFunc("/environments", s.listEnvironments).Methods("GET")
+	router.HandleFunc("/environments/validate", s.validateEnvironment).Methods("POST")
+	router.HandleFunc("/environments/{id}", s.getEnvironment).Methods("GET")
+	router.HandleFunc("/environments/{id}", s.updateEnvironment).Methods("PUT")
+	router.HandleFunc("/environments/{id}", s.patchEnvironment).Methods("PATCH")
+	router.HandleFunc("/environments/{id}", s.deleteEnvironment).Methods("DELETE")
+	router.HandleFunc("/environments/{id}/revisions", s.listRevisions).Methods("GET")
+	router.HandleFunc("/environments/{id}/revisions/{revision}", s.getRevision).Methods("GET")
+	router.HandleFunc("/environments/{id}/diff", s.diffRevisions).Methods("GET")
+	router.HandleFunc("/environments/{id}/line-of-sight", s.lineOfSight).Methods("GET")
+	router.HandleFunc("/environments/{id}/agents/{agent}/view", s.agentView).Methods("GET")
+	router.HandleFunc("/tile-types", s.listTileTypes).Methods("GET")
+	router.HandleFunc("/models", s.listModels).Methods("GET")
+	router.HandleFunc("/models", s.requireAdmin(s.uploadModel)).Methods("POST")
+	router.HandleFunc("/assets/{path:.+}", s.serveAsset).Methods("GET", "HEAD")
+	router.HandleFunc("/simulations", s.startSimulation).Methods("POST")
+	router.HandleFunc("/simulations", s.listSimulations).Methods("GET")
+	router.HandleFunc("/simulations/{id}", s.getSimulation).Methods("GET")
+	router.HandleFunc("/simulations/{id}", s.deleteSimulation).Methods("DELETE")
+	router.HandleFunc("/simulations/{id}/config", s.patchSimulationConfig).Methods("PATCH")
+	router.HandleFunc("/profiles", s.listProfiles).Methods("GET")
+	router.HandleFunc("/admin/import", s.requireAdmin(s.importEnvironments)).Methods("POST")
+
+	// Report unknown routes in the same shape as handler errors
+	router.NotFoundHandler = http.HandlerFunc(notFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
 
-	// Apply CORS middleware to the router
-	handler := corsMiddleware(router)
+	// Apply CORS middleware to the router; request IDs wrap everything so
+	// even preflight responses carry one
+	handler := requestIDMiddleware(corsMiddleware(router))
 
 	return handler
 }
@@ -86,77 +159,245 @@ func runServer(server *http.Server) error {
 	return server.ListenAndServe()
 }
 
-func main() {
-	port := os.Getenv("PORT")
-	server := setupServer(createHandler(), port)
-	log.Fatal(runServer(server))
+// legacyEnv maps the environment variables the server read before settings
+// were layered to the keys they set. The DRIFTER_ variables win over them.
+var legacyEnv = map[string]string{
+	"PORT":                "server.port",
+	"ENV_STORE":           "server.store",
+	"ENV_STORE_PATH":      "server.store_path",
+	"ENV_LOG_FILE":        "server.log_file",
+	"ENV_LOG_MAX_SIZE_MB": "server.log_max_size_mb",
+	"ENV_LOG_MAX_AGE":     "server.log_max_age",
+	"ENV_LOG_COMPRESS":    "server.log_compress",
+	"ENV_LOG_RETAIN":      "server.log_retain",
+	"ENV_MAX_BODY_MB":     "server.max_body_mb",
+	"TILE_TYPES_FILE":     "server.tile_types_file",
+	"MODELS_FILE":         "server.models_file",
+	"ASSETS_DIR":          "server.assets_dir",
+	"ADMIN_TOKEN":         "server.admin_token",
 }
 
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+// defaultConfigFile is read when no configuration file is named, if present
+const defaultConfigFile = "configs/simulation_config.yaml"
+
+// configFlags are the flags every command accepts to configure the server:
+// -config and one per setting, such as -server.port
+type configFlags struct {
+	file   *string
+	values map[string]string
 }
 
-func saveEnvironment(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	
-	// Check Content-Type header
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "" && contentType != "application/json" {
-		http.Error(w, `{"error":"Content-Type must be application/json"}`, http.StatusUnsupportedMediaType)
-		return
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		file:   fs.String("config", "", "configuration file (env "+config.FileEnv+", default "+defaultConfigFile+" if present)"),
+		values: config.AddFlags(fs),
 	}
+}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, `{"error":"failed to read request body"}`, http.StatusBadRequest)
-		return
+// configFile returns the file named by -config, DRIFTER_CONFIG or
+// SIMULATION_CONFIG_FILE, in that order, falling back to the shipped
+// configs/simulation_config.yaml when it exists
+func (f *configFlags) configFile() string {
+	for _, path := range []string{*f.file, os.Getenv(config.FileEnv), os.Getenv("SIMULATION_CONFIG_FILE")} {
+		if path != "" {
+			return path
+		}
 	}
-	if len(body) == 0 {
-		http.Error(w, `{"error":"empty request body"}`, http.StatusBadRequest)
-		return
+	if _, err := os.Stat(defaultConfigFile); err != nil {
+		return ""
 	}
+	return defaultConfigFile
+}
 
-	// Validate JSON
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(body, &jsonData); err != nil {
-		http.Error(w, `{"error":"invalid JSON: `+err.Error()+`"}`, http.StatusBadRequest)
-		return
+// resolve layers the configuration file, the environment and the parsed
+// flags over the defaults
+func (f *configFlags) resolve() (config.Config, config.Sources, error) {
+	return config.Resolve(config.Layers{
+		File:    f.configFile(),
+		Environ: os.Environ(),
+		Aliases: legacyEnv,
+		Flags:   f.values,
+	})
+}
+
+// watchedPaths are the configuration file and profiles directory to reload
+// from when they change. The shipped ones are watched when none are named,
+// so they are picked up if they appear.
+func (f *configFlags) watchedPaths(cfg config.Config) []string {
+	file := f.configFile()
+	if file == "" {
+		file = defaultConfigFile
+	}
+	dir := cfg.Server.ProfilesDir
+	if dir == "" {
+		dir = defaultProfilesDir
 	}
+	return []string{file, dir}
+}
 
-	// Prepare log entry
-	record := map[string]interface{}{
-		"timestamp":   time.Now().UTC().Format(time.RFC3339),
-		"environment": jsonData,
+// openEnvironmentStore opens the configured store (memory, file or sqlite),
+// defaulting its path to one under data/
+func openEnvironmentStore(cfg config.Server) (store.EnvironmentStore, error) {
+	path := cfg.StorePath
+	if path == "" {
+		switch cfg.Store {
+		case store.BackendFile:
+			path = "data/environments"
+		case store.BackendSQLite:
+			path = "data/environments.db"
+		}
 	}
 
-	line, err := json.Marshal(record)
+	return store.Open(cfg.Store, path)
+}
+
+// openEnvironmentLog returns the environment log writer at server.log_file,
+// rotating it as configured
+func openEnvironmentLog(cfg config.Server) *envlog.Writer {
+	return envlog.NewWriter(cfg.LogFile, envlog.Options{
+		MaxBytes: cfg.LogMaxSizeMB << 20,
+		MaxAge:   cfg.LogMaxAge,
+		Compress: cfg.LogCompress,
+		Retain:   cfg.LogRetain,
+	})
+}
+
+// defaultProfilesDir is read when server.profiles_dir is unset, if present
+const defaultProfilesDir = "configs/profiles"
+
+// loadProfiles reads the configuration profiles in server.profiles_dir,
+// resolving them over cfg. Without it the shipped configs/profiles is used;
+// when that is missing too it returns nil and no profiles exist.
+func loadProfiles(cfg config.Config) (*config.Profiles, error) {
+	dir := cfg.Server.ProfilesDir
+	if dir == "" {
+		if _, err := os.Stat(defaultProfilesDir); err != nil {
+			return nil, nil
+		}
+		dir = defaultProfilesDir
+	}
+	return config.LoadProfiles(dir, cfg)
+}
+
+// defaultTileTypesFile is read when server.tile_types_file is unset, if
+// present
+const defaultTileTypesFile = "configs/tile_types.yaml"
+
+// loadTileTypes reads the tile definitions at server.tile_types_file.
+// Without it the shipped configs/tile_types.yaml is used, falling back to
+// the built-in world.DefaultTiles when that file is missing too.
+func loadTileTypes(cfg config.Server) (*world.TileRegistry, error) {
+	path := cfg.TileTypesFile
+	if path == "" {
+		if _, err := os.Stat(defaultTileTypesFile); err != nil {
+			return world.DefaultTiles, nil
+		}
+		path = defaultTileTypesFile
+	}
+	return world.LoadTileRegistry(path)
+}
+
+// defaultModelsFile is read when server.models_file is unset, if present
+const defaultModelsFile = "configs/models.yaml"
+
+// loadModels reads the model manifest at server.models_file, defaulting to
+// the shipped configs/models.yaml, and adds the models uploaded to the asset
+// directory. Without a manifest it returns nil and model references go
+// unchecked.
+func loadModels(cfg config.Server) (*world.ModelRegistry, error) {
+	path := cfg.ModelsFile
+	if path == "" {
+		if _, err := os.Stat(defaultModelsFile); err != nil {
+			return nil, nil
+		}
+		path = defaultModelsFile
+	}
+	models, err := world.LoadModelRegistry(path)
 	if err != nil {
-		http.Error(w, `{"error":"failed to marshal record"}`, http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	logPath := os.Getenv("ENV_LOG_FILE")
-	if logPath == "" {
-		logPath = "logs/environments.log"
+	uploaded := filepath.Join(cfg.AssetsDir, assets.ManifestName)
+	if _, err := os.Stat(uploaded); err != nil {
+		return models, nil
 	}
-	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
-		http.Error(w, "failed to prepare log directory", http.StatusInternalServerError)
-		return
+	extra, err := world.LoadModelRegistry(uploaded)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range extra.Models() {
+		if err := models.Register(m); err != nil {
+			return nil, fmt.Errorf("%s: %w", uploaded, err)
+		}
+	}
+	return models, nil
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:], os.Stdout, os.Stderr))
 	}
 
-	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	fs := flag.NewFlagSet("drifter", flag.ExitOnError)
+	flags := addConfigFlags(fs)
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with the source of each setting, then exit")
+	fs.Parse(os.Args[1:])
+
+	cfg, sources, err := flags.resolve()
 	if err != nil {
-		http.Error(w, "failed to open log", http.StatusInternalServerError)
-		return
+		log.Fatalf("invalid configuration: %v", err)
 	}
-	defer f.Close()
-	if _, err := f.Write(append(line, '\n')); err != nil {
-		http.Error(w, "failed to write log", http.StatusInternalServerError)
+	if *printConfig {
+		if err := config.Print(os.Stdout, cfg, sources); err != nil {
+			log.Fatalf("failed to print configuration: %v", err)
+		}
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "saved"})
+	environments, err := openEnvironmentStore(cfg.Server)
+	if err != nil {
+		log.Fatalf("failed to open environment store: %v", err)
+	}
+	defer environments.Close()
+
+	envLog := openEnvironmentLog(cfg.Server)
+	defer envLog.Close()
+
+	tiles, err := loadTileTypes(cfg.Server)
+	if err != nil {
+		log.Fatalf("failed to load tile types: %v", err)
+	}
+	models, err := loadModels(cfg.Server)
+	if err != nil {
+		log.Fatalf("failed to load model manifest: %v", err)
+	}
+	profiles, err := loadProfiles(cfg)
+	if err != nil {
+		log.Fatalf("failed to load configuration profiles: %v", err)
+	}
+
+	assetStore, err := assets.Open(cfg.Server.AssetsDir)
+	if err != nil {
+		log.Fatalf("failed to open asset directory: %v", err)
+	}
+	defer assetStore.Close()
+
+	s := newServer(environments, envLog)
+	s.adminToken = cfg.Server.AdminToken
+	s.maxBodyBytes = cfg.Server.MaxBodyMB << 20
+	s.validator = world.Validator{Tiles: tiles, Models: models}
+	s.assets = assetStore
+	s.simConfig = cfg
+	s.profiles = profiles
+	if cfg.Server.ReloadInterval > 0 {
+		go config.Watch(context.Background(), cfg.Server.ReloadInterval, func() { s.reloadConfig(flags.resolve) }, flags.watchedPaths(cfg)...)
+	}
+	server := setupServer(createHandler(s), strconv.Itoa(cfg.Server.Port))
+	log.Fatal(runServer(server))
+}
+
+func healthCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
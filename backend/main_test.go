@@ -88,7 +88,7 @@ func TestCreateHandler(t *testing.T) {
 		},
 	}
 
-	handler := createHandler()
+	handler := createHandler(newTestServer())
 	server := httptest.NewServer(handler)
 	defer server.Close()
 
@@ -168,18 +168,18 @@ func TestSaveEnvironmentSuccess(t *testing.T) {
 		envValue   string
 	}{
 		{
-			name:       "simple JSON object",
-			body:       `{"foo":"bar"}`,
+			name:       "minimal environment",
+			body:       validEnvironment,
 			statusCode: http.StatusCreated,
-			envKey:     "foo",
-			envValue:   "bar",
+			envKey:     "map",
+			envValue:   `"width":2`,
 		},
 		{
-			name:       "nested JSON object",
-			body:       `{"nested":{"key":"value"}}`,
+			name:       "environment with metadata",
+			body:       `{"metadata":{"name":"alpha"},"map":{"width":3,"height":3,"tiles":[{"x":0,"y":0,"type":"grass"}]},"objects":[],"agents":[]}`,
 			statusCode: http.StatusCreated,
-			envKey:     "nested",
-			envValue:   `{"key":"value"}`,
+			envKey:     "metadata",
+			envValue:   `{"name":"alpha"}`,
 		},
 	}
 
@@ -189,13 +189,13 @@ func TestSaveEnvironmentSuccess(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			rr := httptest.NewRecorder()
 
-			saveEnvironment(rr, req)
+			newTestServer().saveEnvironment(rr, req)
 
 			assert.Equal(t, tt.statusCode, rr.Code, "status code should match expected")
 
 			// Verify response body for success case
 			if tt.statusCode == http.StatusCreated {
-				var resp map[string]string
+				var resp map[string]interface{}
 				err := json.NewDecoder(rr.Body).Decode(&resp)
 				require.NoError(t, err, "should decode response body without error")
 				assert.Equal(t, "saved", resp["status"], "status should be 'saved'")
@@ -250,7 +250,7 @@ func TestSaveEnvironmentEmpty(t *testing.T) {
 		},
 		{
 			name:       "missing content type still works",
-			body:       strings.NewReader("{}"),
+			body:       strings.NewReader(validEnvironment),
 			headers:    map[string]string{},
 			statusCode: http.StatusCreated,
 		},
@@ -270,7 +270,7 @@ func TestSaveEnvironmentEmpty(t *testing.T) {
 			}
 			rr := httptest.NewRecorder()
 
-			saveEnvironment(rr, req)
+			newTestServer().saveEnvironment(rr, req)
 
 			assert.Equal(t, tt.statusCode, rr.Code, 
 				"status code should match expected for %s", tt.name)
@@ -381,11 +381,11 @@ func TestSaveEnvironmentFileErrors(t *testing.T) {
 				initialSize = info.Size()
 			}
 
-			req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(`{"test":"data"}`))
+			req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(validEnvironment))
 			req.Header.Set("Content-Type", "application/json")
 			rr := httptest.NewRecorder()
 
-			saveEnvironment(rr, req)
+			newTestServer().saveEnvironment(rr, req)
 
 			// Verify the response status code
 			assert.Equal(t, tt.statusCode, rr.Code, 
@@ -451,7 +451,7 @@ func TestSaveEnvironment_Validation(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(`{}`))
 		req.Header.Set("Content-Type", "text/plain")
 		rr := httptest.NewRecorder()
-		saveEnvironment(rr, req)
+		newTestServer().saveEnvironment(rr, req)
 
 		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
 		assert.Contains(t, rr.Body.String(), "Content-Type must be application/json")
@@ -461,7 +461,7 @@ func TestSaveEnvironment_Validation(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/environments", nil)
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		saveEnvironment(rr, req)
+		newTestServer().saveEnvironment(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		assert.Contains(t, rr.Body.String(), "empty request body")
@@ -471,11 +471,21 @@ func TestSaveEnvironment_Validation(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(`{`))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		saveEnvironment(rr, req)
+		newTestServer().saveEnvironment(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		assert.Contains(t, rr.Body.String(), "invalid JSON")
 	})
+
+	t.Run("schema_violation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/environments", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		newTestServer().saveEnvironment(rr, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"path":"/map"`)
+	})
 }
 
 func TestRunServer(t *testing.T) {
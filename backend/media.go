@@ -0,0 +1,88 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types understood by the environment endpoints
+const (
+	mediaTypeJSON       = "application/json"
+	mediaTypeYAML       = "application/yaml"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+	mediaTypeMergePatch = "application/merge-patch+json"
+)
+
+// yamlMediaTypes are the spellings of YAML accepted in Content-Type and Accept
+var yamlMediaTypes = map[string]bool{
+	"application/yaml":   true,
+	"application/x-yaml": true,
+	"text/yaml":          true,
+	"text/x-yaml":        true,
+}
+
+// requestMediaType returns the normalised media type of the request body:
+// mediaTypeJSON, mediaTypeYAML, or "" if the Content-Type is not supported.
+// A missing Content-Type is treated as JSON.
+func requestMediaType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return mediaTypeJSON
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mediaType == mediaTypeJSON:
+		return mediaTypeJSON
+	case yamlMediaTypes[mediaType]:
+		return mediaTypeYAML
+	default:
+		return ""
+	}
+}
+
+// negotiateMediaType picks the response media type from the Accept header.
+// JSON wins ties and is the fallback when nothing acceptable is offered.
+func negotiateMediaType(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return mediaTypeJSON
+	}
+
+	best, bestQ := mediaTypeJSON, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		var candidate string
+		switch {
+		case mediaType == mediaTypeJSON, mediaType == "application/*", mediaType == "*/*":
+			candidate = mediaTypeJSON
+		case yamlMediaTypes[mediaType]:
+			candidate = mediaTypeYAML
+		default:
+			continue
+		}
+
+		if q > bestQ || (q == bestQ && candidate == mediaTypeJSON) {
+			best, bestQ = candidate, q
+		}
+	}
+	if bestQ <= 0 {
+		return mediaTypeJSON
+	}
+	return best
+}
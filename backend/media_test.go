@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMediaType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"", mediaTypeJSON},
+		{"application/json", mediaTypeJSON},
+		{"application/json; charset=utf-8", mediaTypeJSON},
+		{"application/yaml", mediaTypeYAML},
+		{"application/x-yaml", mediaTypeYAML},
+		{"text/yaml; charset=utf-8", mediaTypeYAML},
+		{"text/plain", ""},
+		{"not a media type;;", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			assert.Equal(t, tt.want, requestMediaType(req))
+		})
+	}
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", mediaTypeJSON},
+		{"*/*", mediaTypeJSON},
+		{"application/json", mediaTypeJSON},
+		{"application/yaml", mediaTypeYAML},
+		{"application/x-yaml", mediaTypeYAML},
+		{"application/json;q=0.5, application/yaml", mediaTypeYAML},
+		{"application/yaml;q=0.5, application/json", mediaTypeJSON},
+		{"application/yaml, application/json", mediaTypeJSON},
+		{"text/html, application/yaml;q=0.9", mediaTypeYAML},
+		{"text/html", mediaTypeJSON},
+		{"application/yaml;q=0", mediaTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			assert.Equal(t, tt.want, negotiateMediaType(req))
+		})
+	}
+}
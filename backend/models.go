@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// listModels returns the model manifest so viewers know which assets to load.
+// Without a manifest the list is empty.
+func (s *server) listModels(w http.ResponseWriter, r *http.Request) {
+	models := []world.Model{}
+	if s.validator.Models != nil {
+		models = s.validator.Models.Models()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"models": models})
+}
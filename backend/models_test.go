@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+func TestListModels(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodGet, "/models", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"models":[]}`, rr.Body.String(), "no manifest, no models")
+
+	models, err := loadModels(config.Default().Server)
+	require.NoError(t, err)
+	require.NotNil(t, models, "the shipped manifest is loaded by default")
+	s.validator.Models = models
+
+	rr = doRequest(t, h, http.MethodGet, "/models", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Models []world.Model `json:"models"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, models.Models(), resp.Models)
+}
+
+func TestSaveEnvironmentUnknownModel(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	models, err := world.NewModelRegistry([]world.Model{{Name: "rock.glb", Collision: world.Collision{Shape: world.ShapeNone}}})
+	require.NoError(t, err)
+	s := newTestServer()
+	s.validator.Models = models
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodPost, "/environments",
+		`{"map":{"width":2,"height":2,"tiles":[]},"objects":[{"id":"o","model":"boulder.glb","position":{"x":0,"y":0}}],"agents":[]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p := decodeProblem(t, rr)
+	require.Len(t, p.Errors, 1)
+	assert.Equal(t, "/objects/0/model", p.Errors[0].Path)
+}
+
+func TestLoadModels(t *testing.T) {
+	_, err := loadModels(config.Server{ModelsFile: filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.Error(t, err, "an explicit manifest must exist")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/jsonpatch"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+// patchEnvironment applies a JSON Patch or merge patch to the latest revision
+// of an environment. The result is validated like a full PUT and stored as a
+// new revision, but only if nobody else wrote in between.
+func (s *server) patchEnvironment(w http.ResponseWriter, r *http.Request) {
+	pre, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mediaTypeJSONPatch && mediaType != mediaTypeMergePatch {
+		w.Header().Set("Accept-Patch", mediaTypeJSONPatch+", "+mediaTypeMergePatch)
+		writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+mediaTypeJSONPatch+" or "+mediaTypeMergePatch)
+		return
+	}
+
+	patch, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	rec, err := s.environments.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if pre != nil && !pre(rec) {
+		writeStoreError(w, r, store.ErrPreconditionFailed)
+		return
+	}
+
+	var patched []byte
+	if mediaType == mediaTypeJSONPatch {
+		ops, err := jsonpatch.Decode(patch)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid JSON Patch: "+err.Error())
+			return
+		}
+		if patched, err = ops.Apply(rec.Data); err != nil {
+			// The patch is well formed but does not fit the current document
+			writeError(w, r, http.StatusConflict, "patch cannot be applied: "+err.Error())
+			return
+		}
+	} else if patched, err = jsonpatch.MergePatch(rec.Data, patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid merge patch: "+err.Error())
+		return
+	}
+
+	// Both patch kinds re-marshal the document, so it starts with '{' exactly
+	// when it is an object
+	if !bytes.HasPrefix(patched, []byte("{")) {
+		writeError(w, r, http.StatusUnprocessableEntity, "patched environment must be a JSON object")
+		return
+	}
+	_, warnings, ok := s.checkEnvironment(w, r, patched)
+	if !ok {
+		return
+	}
+
+	// The patch was computed against rec; refuse to commit it on top of any
+	// other revision
+	updated, err := s.environments.Update(r.Context(), id, patched, func(current store.Record) bool {
+		return current.Revision == rec.Revision
+	})
+	if errors.Is(err, store.ErrPreconditionFailed) {
+		writeError(w, r, http.StatusConflict, "environment changed while the patch was being applied; retry")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated.Data))
+	writeJSON(w, http.StatusOK, savedResponse{Status: "updated", ID: updated.ID, Revision: updated.Revision, Warnings: warnings})
+}
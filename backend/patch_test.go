@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+)
+
+func TestPatchEnvironment(t *testing.T) {
+	s := newTestServer()
+	h := createHandler(s)
+
+	create := func(t *testing.T) store.Record {
+		rec, err := s.environments.Create(t.Context(), json.RawMessage(namedEnvironment("alpha")))
+		require.NoError(t, err)
+		return rec
+	}
+	patch := func(t *testing.T, rec store.Record, contentType, body string) *httptest.ResponseRecorder {
+		return doRequestWithHeaders(t, h, http.MethodPatch, "/environments/"+rec.ID, body, map[string]string{
+			"Content-Type": contentType,
+			"If-Match":     etag(rec.Data),
+		})
+	}
+
+	t.Run("json patch", func(t *testing.T) {
+		rec := create(t)
+		rr := patch(t, rec, mediaTypeJSONPatch, `[
+			{"op":"test","path":"/metadata/name","value":"alpha"},
+			{"op":"add","path":"/map/tiles/-","value":{"x":1,"y":1,"type":"stone"}}
+		]`)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var saved savedResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&saved))
+		assert.Equal(t, 2, saved.Revision)
+
+		got, err := s.environments.Get(t.Context(), rec.ID)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"metadata":{"name":"alpha"},"map":{"width":2,"height":2,"tiles":[{"x":1,"y":1,"type":"stone"}]},"objects":[],"agents":[]}`, string(got.Data))
+		assert.Equal(t, etag(got.Data), rr.Header().Get("ETag"))
+	})
+
+	t.Run("merge patch", func(t *testing.T) {
+		rec := create(t)
+		rr := patch(t, rec, mediaTypeMergePatch, `{"metadata":{"name":"beta"}}`)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		got, err := s.environments.Get(t.Context(), rec.ID)
+		require.NoError(t, err)
+		assert.JSONEq(t, namedEnvironment("beta"), string(got.Data))
+	})
+
+	t.Run("result is validated", func(t *testing.T) {
+		rec := create(t)
+		rr := patch(t, rec, mediaTypeJSONPatch, `[{"op":"add","path":"/map/tiles/-","value":{"x":5,"y":0,"type":"grass"}}]`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"rule":"bounds"`)
+
+		rr = patch(t, rec, mediaTypeMergePatch, `{"map":null}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+		rr = patch(t, rec, mediaTypeMergePatch, `[]`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+		got, err := s.environments.Get(t.Context(), rec.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Revision, "invalid patches should not create revisions")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		rec := create(t)
+
+		rr := patch(t, rec, mediaTypeJSON, `{}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		assert.Contains(t, rr.Header().Get("Accept-Patch"), mediaTypeMergePatch)
+
+		rr = patch(t, rec, mediaTypeJSONPatch, `[{"op":"bogus","path":"/a"}]`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		rr = patch(t, rec, mediaTypeJSONPatch, `[{"op":"test","path":"/metadata/name","value":"other"}]`)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Contains(t, rr.Body.String(), "test failed")
+
+		rr = patch(t, rec, mediaTypeMergePatch, `{`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		stale := rec
+		stale.Data = json.RawMessage(`{}`)
+		rr = patch(t, stale, mediaTypeMergePatch, `{}`)
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+		rr = doRequestWithHeaders(t, h, http.MethodPatch, "/environments/"+rec.ID, `{}`, map[string]string{"Content-Type": mediaTypeMergePatch})
+		assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+
+		rr = doRequestWithHeaders(t, h, http.MethodPatch, "/environments/missing", `{}`, map[string]string{
+			"Content-Type": mediaTypeMergePatch,
+			"If-Match":     "*",
+		})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// mediaTypeProblem is the Content-Type of every error response
+const mediaTypeProblem = "application/problem+json"
+
+// Problem types that carry more meaning than their status code. Any other
+// error is reported as about:blank, whose title is the HTTP status text.
+const (
+	problemInvalidEnvironment = "https://drifter.solo7.media/problems/invalid-environment"
+	problemBodyTooLarge       = "https://drifter.solo7.media/problems/body-too-large"
+	problemInvalidConfig      = "https://drifter.solo7.media/problems/invalid-config"
+	problemFixedSetting       = "https://drifter.solo7.media/problems/fixed-setting"
+)
+
+// problem is an RFC 7807 problem details document. Every handler reports
+// errors in this shape.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID matches the X-Request-ID response header and the server logs
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists the individual fields that were rejected
+	Errors []world.Violation `json:"errors,omitempty"`
+
+	// cause is the error behind a server error. It is logged, never sent.
+	cause error
+}
+
+// newProblem returns an about:blank problem for r with the given status
+func newProblem(r *http.Request, status int, detail string) problem {
+	return problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestID(r.Context()),
+	}
+}
+
+// writeProblem sends p as the response. Server errors are logged with the
+// request ID so a report from a client can be traced.
+func writeProblem(w http.ResponseWriter, p problem) {
+	if p.Status >= http.StatusInternalServerError {
+		if p.cause != nil {
+			log.Printf("request %s: %s %d: %s: %v", p.RequestID, p.Instance, p.Status, p.Detail, p.cause)
+		} else {
+			log.Printf("request %s: %s %d: %s", p.RequestID, p.Instance, p.Status, p.Detail)
+		}
+	}
+	w.Header().Set("Content-Type", mediaTypeProblem)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeError reports a failed request as an about:blank problem with detail
+// explaining what went wrong
+func writeError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, newProblem(r, status, detail))
+}
+
+// writeInternalError reports a 500 whose cause is logged but kept from the
+// client
+func writeInternalError(w http.ResponseWriter, r *http.Request, detail string, err error) {
+	p := newProblem(r, http.StatusInternalServerError, detail)
+	p.cause = err
+	writeProblem(w, p)
+}
+
+// writeValidationError reports an invalid environment definition with a 422
+// response listing every violation, warnings included
+func writeValidationError(w http.ResponseWriter, r *http.Request, violations []world.Violation) {
+	p := newProblem(r, http.StatusUnprocessableEntity, "environment failed validation")
+	p.Type = problemInvalidEnvironment
+	p.Title = "Invalid environment"
+	p.Errors = violations
+	writeProblem(w, p)
+}
+
+// writeConfigError reports simulation settings that were rejected with a 422
+// response listing each one. pointer locates each error in the document
+// that set the setting.
+func writeConfigError(w http.ResponseWriter, r *http.Request, err error, pointer func(config.FieldError) string) {
+	p := newProblem(r, http.StatusUnprocessableEntity, "simulation config failed validation: "+err.Error())
+	p.Type = problemInvalidConfig
+	p.Title = "Invalid simulation config"
+	var errs config.Errors
+	if errors.As(err, &errs) {
+		for _, fe := range errs {
+			p.Errors = append(p.Errors, world.Violation{
+				Severity: world.SeverityError,
+				Path:     pointer(fe),
+				Rule:     "config",
+				Message:  fe.Message,
+			})
+		}
+	}
+	writeProblem(w, p)
+}
+
+// writeFixedSettingError reports a 409 for an attempt to change settings,
+// named by their keys, that a running simulation was built with
+func writeFixedSettingError(w http.ResponseWriter, r *http.Request, keys []string) {
+	p := newProblem(r, http.StatusConflict, strings.Join(keys, ", ")+" cannot change while a simulation runs; only "+config.TunableSettings+" can")
+	p.Type = problemFixedSetting
+	p.Title = "Setting cannot change mid-run"
+	for _, key := range keys {
+		p.Errors = append(p.Errors, world.Violation{
+			Severity: world.SeverityError,
+			Path:     configPointer(key),
+			Rule:     "tunable",
+			Message:  "cannot change while a simulation runs",
+		})
+	}
+	writeProblem(w, p)
+}
+
+// writeStoreError maps a store error onto an HTTP error response. Unexpected
+// errors are only logged.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	if isNotFound(err) {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, store.ErrPreconditionFailed) {
+		writeError(w, r, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	writeInternalError(w, r, "failed to access environment store", err)
+}
+
+// notFound and methodNotAllowed replace the router's plain text responses
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, "no such endpoint")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported here")
+}
+
+type requestIDKey struct{}
+
+// maxRequestIDLength bounds client supplied request IDs
+const maxRequestIDLength = 128
+
+// requestIDMiddleware tags every request with an ID, reusing a well formed
+// X-Request-ID from the client so a call can be traced across services. The
+// ID is echoed in the response header and in error bodies.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID assigned by requestIDMiddleware, or "" outside it
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts IDs that are safe to echo into headers and logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
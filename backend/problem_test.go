@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeProblem checks that rr is a problem details response and decodes it
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) problem {
+	t.Helper()
+	resp := rr.Result()
+	defer resp.Body.Close()
+	require.Equal(t, mediaTypeProblem, resp.Header.Get("Content-Type"))
+
+	var p problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, resp.StatusCode, p.Status)
+	assert.Equal(t, resp.Header.Get("X-Request-ID"), p.RequestID)
+	assert.NotEmpty(t, p.RequestID)
+	return p
+}
+
+func TestProblemDetails(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	t.Run("bad request", func(t *testing.T) {
+		// The decoder's message quotes the offending character, which must
+		// survive encoding
+		rr := doRequest(t, h, http.MethodPost, "/environments", `{'map':1}`)
+		p := decodeProblem(t, rr)
+		assert.Equal(t, "about:blank", p.Type)
+		assert.Equal(t, "Bad Request", p.Title)
+		assert.Equal(t, "/environments", p.Instance)
+		assert.Contains(t, p.Detail, "invalid JSON: invalid character '\\''")
+	})
+
+	t.Run("field errors", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPost, "/environments", `{}`)
+		p := decodeProblem(t, rr)
+		assert.Equal(t, problemInvalidEnvironment, p.Type)
+		assert.Equal(t, http.StatusUnprocessableEntity, p.Status)
+		assert.NotEmpty(t, p.Errors)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodGet, "/environments/missing", "")
+		p := decodeProblem(t, rr)
+		assert.Equal(t, http.StatusNotFound, p.Status)
+		assert.Equal(t, "/environments/missing", p.Instance)
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodGet, "/nowhere", "")
+		assert.Equal(t, http.StatusNotFound, decodeProblem(t, rr).Status)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rr := doRequest(t, h, http.MethodPut, "/environments", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, decodeProblem(t, rr).Status)
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	h := createHandler(newTestServer())
+
+	rr := doRequest(t, h, http.MethodGet, "/health", "")
+	generated := rr.Header().Get("X-Request-ID")
+	assert.Len(t, generated, 32)
+
+	rr = doRequest(t, h, http.MethodGet, "/health", "")
+	assert.NotEqual(t, generated, rr.Header().Get("X-Request-ID"), "IDs must be unique")
+
+	rr = doRequestWithHeaders(t, h, http.MethodGet, "/environments/missing", "", map[string]string{"X-Request-ID": "trace-42"})
+	assert.Equal(t, "trace-42", rr.Header().Get("X-Request-ID"))
+	assert.Equal(t, "trace-42", decodeProblem(t, rr).RequestID)
+
+	for _, bad := range []string{"has space", strings.Repeat("x", maxRequestIDLength+1)} {
+		rr = doRequestWithHeaders(t, h, http.MethodGet, "/health", "", map[string]string{"X-Request-ID": bad})
+		assert.NotEqual(t, bad, rr.Header().Get("X-Request-ID"))
+		assert.NotEmpty(t, rr.Header().Get("X-Request-ID"))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// revisionSummary is the listing representation of an environment revision
+type revisionSummary struct {
+	Revision  int       `json:"revision"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *server) listRevisions(w http.ResponseWriter, r *http.Request) {
+	revs, err := s.environments.Revisions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	summaries := make([]revisionSummary, 0, len(revs))
+	for _, rev := range revs {
+		summaries = append(summaries, revisionSummary{Revision: rev.Number, CreatedAt: rev.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revisions": summaries})
+}
+
+func (s *server) getRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	number, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "revision must be an integer")
+		return
+	}
+
+	rev, err := s.environments.Revision(r.Context(), vars["id"], number)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeEnvironment(w, r, rev.Data, rev.CreatedAt)
+}
+
+// diffRevisions compares two revisions of an environment. The from and to
+// query parameters default to the previous and the latest revision.
+func (s *server) diffRevisions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	rec, err := s.environments.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	to, ok := revisionParam(w, r, "to", rec.Revision)
+	if !ok {
+		return
+	}
+	from, ok := revisionParam(w, r, "from", to-1)
+	if !ok {
+		return
+	}
+	if from < 1 {
+		from = 1
+	}
+
+	before, ok := s.loadRevision(w, r, id, from)
+	if !ok {
+		return
+	}
+	after, ok := s.loadRevision(w, r, id, to)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from": from,
+		"to":   to,
+		"diff": world.Compare(before, after),
+	})
+}
+
+// revisionParam reads an optional revision number from the query string. On
+// failure it writes the error response and returns false.
+func revisionParam(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, name+" must be an integer")
+		return 0, false
+	}
+	return n, true
+}
+
+// loadRevision fetches and decodes one revision. On failure it writes the
+// error response and returns false.
+func (s *server) loadRevision(w http.ResponseWriter, r *http.Request, id string, number int) (*world.EnvironmentSchemaJson, bool) {
+	rev, err := s.environments.Revision(r.Context(), id, number)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return nil, false
+	}
+	env, err := world.DecodeEnvironment(rev.Data)
+	if err != nil {
+		writeInternalError(w, r, "stored revision "+strconv.Itoa(number)+" is not a valid environment", err)
+		return nil, false
+	}
+	return env, true
+}
+
+// isNotFound reports whether err means the requested environment or revision
+// does not exist
+func isNotFound(err error) bool {
+	return errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrRevisionNotFound)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+func TestEnvironmentRevisions(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	rr := doRequest(t, h, http.MethodPost, "/environments", namedEnvironment("alpha"))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var saved savedResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&saved))
+	assert.Equal(t, 1, saved.Revision)
+	id := saved.ID
+
+	rr = doRequestWithHeaders(t, h, http.MethodPut, "/environments/"+id, namedEnvironment("beta"), map[string]string{"If-Match": "*"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&saved))
+	assert.Equal(t, 2, saved.Revision)
+
+	// List
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/revisions", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Revisions []revisionSummary `json:"revisions"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Revisions, 2)
+	assert.Equal(t, 1, list.Revisions[0].Revision)
+	assert.Equal(t, 2, list.Revisions[1].Revision)
+
+	// Earlier revisions stay readable after an update
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/revisions/1", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, namedEnvironment("alpha"), rr.Body.String())
+
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/revisions/3", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/revisions/latest", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Diff defaults to the latest revision against the one before it
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/diff", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var diff struct {
+		From int        `json:"from"`
+		To   int        `json:"to"`
+		Diff world.Diff `json:"diff"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&diff))
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 2, diff.To)
+	assert.Equal(t, []world.FieldChange{{Field: "name", From: "alpha", To: "beta"}}, diff.Diff.Metadata)
+
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/diff?from=2&to=2", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&diff))
+	assert.True(t, diff.Diff.Empty())
+
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/diff?from=x", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/environments/"+id+"/diff?from=1&to=5", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/environments/missing/revisions", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
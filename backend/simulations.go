@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/jsonpatch"
+	"github.com/solo-seven/drifter.solo7.media/internal/sim"
+	"github.com/solo-seven/drifter.solo7.media/internal/store"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// maxSimulations caps the simulations kept at once, running or not, since
+// each holds a whole world in memory
+const maxSimulations = 16
+
+// simulation is an engine started from a stored environment
+type simulation struct {
+	id            string
+	environmentID string
+	createdAt     time.Time
+	engine        *sim.Engine
+	cancel        context.CancelFunc
+	// done is closed when the engine's Run returns
+	done chan struct{}
+	// profile names the profile the run started from, if any
+	profile string
+
+	mu sync.Mutex // guards overrides and config
+	// overrides apply over the profile in order: the environment's, the
+	// request's, then those of each config patch. They are applied again
+	// when the configuration is reloaded.
+	overrides []overrideLayer
+	// config is everything the run is configured with, as last tuned, kept
+	// so the run can be reproduced
+	config config.Config
+}
+
+// overrideLayer is one set of overrides of a run, named as in its errors
+type overrideLayer struct {
+	name   string
+	values map[string]interface{}
+}
+
+// simulationStatus is the representation of a simulation
+type simulationStatus struct {
+	ID            string    `json:"id"`
+	EnvironmentID string    `json:"environment_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	sim.Status
+	Profile string        `json:"profile,omitempty"`
+	Config  config.Config `json:"config"`
+}
+
+func (sm *simulation) status() simulationStatus {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.statusLocked()
+}
+
+// statusLocked is status with sm.mu held
+func (sm *simulation) statusLocked() simulationStatus {
+	return simulationStatus{
+		ID:            sm.id,
+		EnvironmentID: sm.environmentID,
+		CreatedAt:     sm.createdAt,
+		Status:        sm.engine.Status(),
+		Profile:       sm.profile,
+		Config:        sm.config,
+	}
+}
+
+// overridePointers locate the overrides of each layer in the document that
+// set them, for error responses
+var overridePointers = map[string]string{
+	"environment": "/simulation/overrides/",
+	"request":     "/overrides/",
+}
+
+// overridePointer locates an override's error in the document that set it
+func overridePointer(fe config.FieldError) string {
+	return overridePointers[fe.Source.Name] + fe.Path
+}
+
+// configPointer locates a setting, named by its key, in a simulation's
+// config, such as /physics/wind/speed for physics.wind.speed
+func configPointer(key string) string {
+	return "/" + strings.ReplaceAll(key, ".", "/")
+}
+
+// startRequest is the body of POST /simulations
+type startRequest struct {
+	EnvironmentID string `json:"environment_id"`
+	// Profile replaces the profile named by the environment
+	Profile string `json:"profile,omitempty"`
+	// Overrides apply over the environment's own, keyed like
+	// physics.wind.speed
+	Overrides map[string]interface{} `json:"overrides,omitempty"`
+}
+
+// startSimulation builds the world of a stored environment and runs it until
+// it finishes or is deleted. The run is configured by the server's
+// simulation config, then the profile and overrides of the environment and
+// of the request. A run that neither keeps to real time nor ends is refused,
+// since it would spin until deleted.
+func (s *server) startSimulation(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	var req startRequest
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if req.EnvironmentID == "" {
+		writeError(w, r, http.StatusUnprocessableEntity, "environment_id is required")
+		return
+	}
+
+	env, ok := s.loadEnvironment(w, r, req.EnvironmentID)
+	if !ok {
+		return
+	}
+	profile, layers, cfg, ok := s.runConfig(w, r, env, req)
+	if !ok {
+		return
+	}
+	if cfg.Simulation.RealTimeFactor == 0 && cfg.Simulation.MaxDurationSeconds == 0 {
+		err := config.Errors{{Path: "simulation.max_duration_seconds", Message: "must be positive when real_time_factor is 0"}}
+		writeConfigError(w, r, err, func(fe config.FieldError) string { return configPointer(fe.Path) })
+		return
+	}
+	wld, ok := s.buildWorld(w, r, env)
+	if !ok {
+		return
+	}
+	engine, err := sim.New(wld, cfg.Simulation)
+	if err != nil {
+		writeInternalError(w, r, "invalid simulation config", err)
+		return
+	}
+	engine.SetParams(cfg)
+	id, err := store.NewID()
+	if err != nil {
+		writeInternalError(w, r, "failed to generate simulation id", err)
+		return
+	}
+
+	s.simMu.Lock()
+	defer s.simMu.Unlock()
+	if len(s.simulations) >= maxSimulations {
+		writeError(w, r, http.StatusConflict, "at most "+strconv.Itoa(maxSimulations)+" simulations can exist at once; delete one first")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sm := &simulation{
+		id:            id,
+		environmentID: req.EnvironmentID,
+		createdAt:     time.Now().UTC(),
+		profile:       profile,
+		overrides:     layers,
+		config:        cfg,
+		engine:        engine,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	s.simulations[id] = sm
+	go func() {
+		defer close(sm.done)
+		engine.Run(ctx)
+	}()
+
+	w.Header().Set("Location", "/simulations/"+id)
+	writeJSON(w, http.StatusCreated, sm.status())
+}
+
+// runConfig resolves the configuration of a run: the profile named by the
+// request, or else by the environment, over the server's config, then the
+// environment's overrides and the request's. It returns the profile and
+// overrides used. On failure it writes the error response and returns false.
+func (s *server) runConfig(w http.ResponseWriter, r *http.Request, env *world.EnvironmentSchemaJson, req startRequest) (string, []overrideLayer, config.Config, bool) {
+	var name string
+	var overrides map[string]interface{}
+	if env.Simulation != nil {
+		if env.Simulation.Profile != nil {
+			name = *env.Simulation.Profile
+		}
+		overrides = env.Simulation.Overrides
+	}
+	if req.Profile != "" {
+		name = req.Profile
+	}
+	layers := []overrideLayer{
+		{name: "environment", values: overrides},
+		{name: "request", values: req.Overrides},
+	}
+
+	s.simMu.Lock()
+	base, profiles := s.simConfig, s.profiles
+	s.simMu.Unlock()
+	cfg, err := resolveRun(base, profiles, name, layers)
+	if err != nil {
+		var errs config.Errors
+		if errors.As(err, &errs) {
+			writeConfigError(w, r, err, overridePointer)
+		} else {
+			writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		}
+		return "", nil, config.Config{}, false
+	}
+	return name, layers, cfg, true
+}
+
+// resolveRun applies a run's profile, if any, and its overrides over base
+func resolveRun(base config.Config, profiles *config.Profiles, profile string, layers []overrideLayer) (config.Config, error) {
+	cfg := base
+	if profile != "" {
+		p, ok := profiles.Get(profile)
+		if !ok {
+			return config.Config{}, fmt.Errorf("unknown profile %q", profile)
+		}
+		cfg = p.Config
+	}
+	for _, l := range layers {
+		var err error
+		if cfg, err = cfg.Override(l.name, l.values); err != nil {
+			return config.Config{}, err
+		}
+	}
+	return cfg, nil
+}
+
+// storedOnlyPrefixes are the tunable settings meant for the simulation's
+// systems. No system reads them yet, so tuning them only changes the config
+// stored with the run, and the response warns about each one tuned.
+var storedOnlyPrefixes = []string{"physics.wind.", "environment."}
+
+// tunedSimulation is the response to a config patch
+type tunedSimulation struct {
+	simulationStatus
+	Warnings []world.Violation `json:"warnings,omitempty"`
+}
+
+// storedOnlyWarnings warns about each tuned setting, named by its key, that
+// nothing in the run reads
+func storedOnlyWarnings(keys []string) []world.Violation {
+	var out []world.Violation
+	for _, key := range keys {
+		for _, p := range storedOnlyPrefixes {
+			if strings.HasPrefix(key, p) {
+				out = append(out, world.Violation{
+					Severity: world.SeverityWarning,
+					Path:     configPointer(key),
+					Rule:     "stored_only",
+					Message:  "stored with the run; no simulation system reads it yet",
+				})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// patchSimulationConfig tunes a simulation with a JSON Patch or merge patch
+// of its config. Settings under physics.wind, environment and debug apply
+// from the next tick; the others shaped the run when it was built, so
+// changing them is refused. Settings a patch removes keep their values. The
+// physics.wind and environment settings are stored only for now, which the
+// response's warnings say.
+func (s *server) patchSimulationConfig(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mediaTypeJSONPatch && mediaType != mediaTypeMergePatch {
+		w.Header().Set("Accept-Patch", mediaTypeJSONPatch+", "+mediaTypeMergePatch)
+		writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+mediaTypeJSONPatch+" or "+mediaTypeMergePatch)
+		return
+	}
+	patch, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	sm, ok := s.lookupSimulation(w, r)
+	if !ok {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	doc, err := json.Marshal(sm.config)
+	if err != nil {
+		writeInternalError(w, r, "failed to encode simulation config", err)
+		return
+	}
+	var patched []byte
+	if mediaType == mediaTypeJSONPatch {
+		ops, err := jsonpatch.Decode(patch)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid JSON Patch: "+err.Error())
+			return
+		}
+		if patched, err = ops.Apply(doc); err != nil {
+			writeError(w, r, http.StatusConflict, "patch cannot be applied: "+err.Error())
+			return
+		}
+	} else if patched, err = jsonpatch.MergePatch(doc, patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid merge patch: "+err.Error())
+		return
+	}
+	if !bytes.HasPrefix(patched, []byte("{")) {
+		writeError(w, r, http.StatusUnprocessableEntity, "patched config must be a JSON object")
+		return
+	}
+
+	// Decoding over the current config keeps the server settings, which are
+	// never sent, and any setting the patch removed
+	next := sm.config
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&next); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, "invalid simulation config: "+err.Error())
+		return
+	}
+	if err := next.Validate(); err != nil {
+		writeConfigError(w, r, err, func(fe config.FieldError) string { return configPointer(fe.Path) })
+		return
+	}
+	tuned, fixed := sm.config.Retune(next)
+	if len(fixed) > 0 {
+		writeFixedSettingError(w, r, fixed)
+		return
+	}
+
+	// Keep the changes as overrides so a reload does not undo them
+	changed := config.Diff(sm.config, tuned)
+	values := make(map[string]interface{})
+	for _, key := range changed {
+		values[key], _ = tuned.Get(key)
+	}
+	if len(values) > 0 {
+		sm.overrides = append(sm.overrides, overrideLayer{name: "patch", values: values})
+		sm.config = tuned
+		sm.engine.SetParams(tuned)
+	}
+	writeJSON(w, http.StatusOK, tunedSimulation{simulationStatus: sm.statusLocked(), Warnings: storedOnlyWarnings(changed)})
+}
+
+// reloadConfig replaces the configuration simulations start from after the
+// configuration file or a profile changed. Running simulations take up the
+// settings that can be tuned at their next tick and keep the others until
+// they are restarted. A configuration that fails to load is logged and the
+// current one kept; server settings apply on restart only.
+func (s *server) reloadConfig(resolve func() (config.Config, config.Sources, error)) {
+	cfg, _, err := resolve()
+	if err != nil {
+		log.Printf("config reload: keeping the current configuration: %v", err)
+		return
+	}
+	profiles, err := loadProfiles(cfg)
+	if err != nil {
+		log.Printf("config reload: keeping the current configuration: %v", err)
+		return
+	}
+
+	s.simMu.Lock()
+	defer s.simMu.Unlock()
+	var restart []string
+	for _, key := range config.Diff(s.simConfig, cfg) {
+		if strings.HasPrefix(key, "server.") {
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		log.Printf("config reload: %s apply on restart only", strings.Join(restart, ", "))
+	}
+	s.simConfig, s.profiles = cfg, profiles
+	for _, sm := range s.simulations {
+		sm.reload(cfg, profiles)
+	}
+	log.Printf("config reload: configuration reloaded")
+}
+
+// reload resolves the run's configuration again over base and takes up the
+// settings that can be tuned
+func (sm *simulation) reload(base config.Config, profiles *config.Profiles) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	next, err := resolveRun(base, profiles, sm.profile, sm.overrides)
+	if err != nil {
+		log.Printf("config reload: simulation %s keeps its configuration: %v", sm.id, err)
+		return
+	}
+	tuned, fixed := sm.config.Retune(next)
+	if len(fixed) > 0 {
+		log.Printf("config reload: simulation %s keeps %s until it is restarted", sm.id, strings.Join(fixed, ", "))
+	}
+	sm.config = tuned
+	sm.engine.SetParams(tuned)
+}
+
+// listProfiles returns the configuration profiles simulations can start
+// from, each resolved over the server's config
+func (s *server) listProfiles(w http.ResponseWriter, r *http.Request) {
+	s.simMu.Lock()
+	profiles := s.profiles.List()
+	s.simMu.Unlock()
+	if profiles == nil {
+		profiles = []config.Profile{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"profiles": profiles})
+}
+
+// listSimulations returns every simulation, oldest first
+func (s *server) listSimulations(w http.ResponseWriter, r *http.Request) {
+	s.simMu.Lock()
+	list := make([]simulationStatus, 0, len(s.simulations))
+	for _, sm := range s.simulations {
+		list = append(list, sm.status())
+	}
+	s.simMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"simulations": list})
+}
+
+func (s *server) getSimulation(w http.ResponseWriter, r *http.Request) {
+	sm, ok := s.lookupSimulation(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, sm.status())
+}
+
+// deleteSimulation stops a simulation and forgets it
+func (s *server) deleteSimulation(w http.ResponseWriter, r *http.Request) {
+	s.simMu.Lock()
+	id := mux.Vars(r)["id"]
+	sm, ok := s.simulations[id]
+	delete(s.simulations, id)
+	s.simMu.Unlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "simulation not found")
+		return
+	}
+
+	sm.cancel()
+	<-sm.done
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupSimulation finds the simulation named in the URL. On failure it
+// writes the error response and returns false.
+func (s *server) lookupSimulation(w http.ResponseWriter, r *http.Request) (*simulation, bool) {
+	s.simMu.Lock()
+	defer s.simMu.Unlock()
+	sm, ok := s.simulations[mux.Vars(r)["id"]]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "simulation not found")
+		return nil, false
+	}
+	return sm, true
+}
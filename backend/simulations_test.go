@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/sim"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// createEnvironment stores an environment through the API and returns its ID
+func createEnvironment(t *testing.T, h http.Handler, body string) string {
+	t.Helper()
+	rr := doRequest(t, h, http.MethodPost, "/environments", body)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var saved savedResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&saved))
+	return saved.ID
+}
+
+func decodeSimulation(t *testing.T, body []byte) simulationStatus {
+	t.Helper()
+	var status simulationStatus
+	require.NoError(t, json.Unmarshal(body, &status))
+	return status
+}
+
+func TestSimulationLifecycle(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	s.simConfig.Simulation = sim.Config{Name: "quick", TimeStep: 0.1, MaxDurationSeconds: 1}
+	h := createHandler(s)
+	envID := createEnvironment(t, h, sightEnvironment)
+
+	rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	created := decodeSimulation(t, rr.Body.Bytes())
+	assert.Equal(t, "/simulations/"+created.ID, rr.Header().Get("Location"))
+	assert.Equal(t, envID, created.EnvironmentID)
+	assert.Equal(t, "quick", created.Config.Simulation.Name)
+
+	var status simulationStatus
+	require.Eventually(t, func() bool {
+		rr := doRequest(t, h, http.MethodGet, "/simulations/"+created.ID, "")
+		status = decodeSimulation(t, rr.Body.Bytes())
+		return status.State == sim.StateFinished
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, uint64(10), status.Tick)
+	assert.InDelta(t, 1.0, status.SimTime, 1e-9)
+
+	rr = doRequest(t, h, http.MethodGet, "/simulations", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Simulations []simulationStatus `json:"simulations"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Simulations, 1)
+	assert.Equal(t, created.ID, list.Simulations[0].ID)
+
+	rr = doRequest(t, h, http.MethodDelete, "/simulations/"+created.ID, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/simulations/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodDelete, "/simulations/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestDeleteRunningSimulation(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	s.simConfig.Simulation = sim.Config{TimeStep: 0.01, RealTimeFactor: 1}
+	h := createHandler(s)
+	envID := createEnvironment(t, h, sightEnvironment)
+
+	rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	id := decodeSimulation(t, rr.Body.Bytes()).ID
+
+	require.Eventually(t, func() bool {
+		rr := doRequest(t, h, http.MethodGet, "/simulations/"+id, "")
+		status := decodeSimulation(t, rr.Body.Bytes())
+		return status.State == sim.StateRunning && status.Tick > 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	rr = doRequest(t, h, http.MethodDelete, "/simulations/"+id, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, s.simulations)
+}
+
+func TestStartSimulationRejects(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	s.simConfig.Simulation = sim.Config{TimeStep: 1, MaxDurationSeconds: 1}
+	h := createHandler(s)
+	envID := createEnvironment(t, h, validEnvironment)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"not JSON", `{`, http.StatusBadRequest},
+		{"unknown field", `{"environment_id":"` + envID + `","speed":2}`, http.StatusBadRequest},
+		{"no environment", `{}`, http.StatusUnprocessableEntity},
+		{"missing environment", `{"environment_id":"00000000-0000-4000-8000-000000000000"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, h, http.MethodPost, "/simulations", tt.body)
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+		})
+	}
+
+	for i := 0; i < maxSimulations; i++ {
+		rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+	rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestStartSimulationWithProfile(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	s.simConfig.Simulation.RealTimeFactor = 0
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "storm.yaml"), []byte("physics:\n  wind: {speed: 20, gustiness: 0.7}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "calm.yaml"), []byte("physics:\n  wind: {speed: 1}\n"), 0o644))
+	profiles, err := config.LoadProfiles(dir, s.simConfig)
+	require.NoError(t, err)
+	s.profiles = profiles
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodGet, "/profiles", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Profiles []config.Profile `json:"profiles"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Profiles, 2)
+	assert.Equal(t, "calm", list.Profiles[0].Name)
+
+	envID := createEnvironment(t, h, `{"simulation":{"profile":"storm","overrides":{"physics.gravity":3.7}},`+validEnvironment[1:])
+	rr = doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`","overrides":{"physics.wind.gustiness":0.1}}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	created := decodeSimulation(t, rr.Body.Bytes())
+	assert.Equal(t, "storm", created.Profile, "the environment's profile is used")
+	assert.Equal(t, 20.0, created.Config.Physics.Wind.Speed)
+	assert.Equal(t, 3.7, created.Config.Physics.Gravity, "the environment's overrides apply")
+	assert.Equal(t, 0.1, created.Config.Physics.Wind.Gustiness, "and the request's over them")
+	assert.NotContains(t, rr.Body.String(), "server", "server settings are not shown")
+
+	rr = doRequest(t, h, http.MethodGet, "/simulations/"+created.ID, "")
+	assert.Equal(t, created.Config, decodeSimulation(t, rr.Body.Bytes()).Config, "the resolved config is kept with the run")
+
+	rr = doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`","profile":"calm"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	created = decodeSimulation(t, rr.Body.Bytes())
+	assert.Equal(t, "calm", created.Profile, "the request's profile replaces the environment's")
+	assert.Equal(t, 1.0, created.Config.Physics.Wind.Speed)
+	assert.Equal(t, 3.7, created.Config.Physics.Gravity)
+}
+
+func TestStartSimulationRejectsConfig(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	h := createHandler(s)
+	envID := createEnvironment(t, h, `{"simulation":{"overrides":{"vessel.mass":-1}},`+validEnvironment[1:])
+	plainID := createEnvironment(t, h, validEnvironment)
+
+	rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+plainID+`","profile":"storm"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, `unknown profile "storm"`, decodeProblem(t, rr).Detail)
+
+	rr = doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p := decodeProblem(t, rr)
+	assert.Equal(t, problemInvalidConfig, p.Type)
+	assert.Equal(t, []world.Violation{{Severity: world.SeverityError, Path: "/simulation/overrides/vessel.mass", Rule: "config", Message: "must be positive, got -1"}}, p.Errors)
+
+	rr = doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+plainID+`","overrides":{"server.port":80}}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p = decodeProblem(t, rr)
+	assert.Equal(t, []world.Violation{{Severity: world.SeverityError, Path: "/overrides/server.port", Rule: "config", Message: "server settings cannot be overridden"}}, p.Errors)
+
+	rr = doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+plainID+`","overrides":{"simulation.real_time_factor":0,"simulation.max_duration_seconds":0}}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code, "a run that never waits must end")
+	p = decodeProblem(t, rr)
+	assert.Equal(t, []world.Violation{{Severity: world.SeverityError, Path: "/simulation/max_duration_seconds", Rule: "config", Message: "must be positive when real_time_factor is 0"}}, p.Errors)
+	assert.Empty(t, s.simulations)
+}
+
+func TestPatchSimulationConfig(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	s := newTestServer()
+	h := createHandler(s)
+	envID := createEnvironment(t, h, validEnvironment)
+	rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	created := decodeSimulation(t, rr.Body.Bytes())
+	path := "/simulations/" + created.ID + "/config"
+	patch := func(mediaType, body string) *httptest.ResponseRecorder {
+		return doRequestWithHeaders(t, h, http.MethodPatch, path, body, map[string]string{"Content-Type": mediaType})
+	}
+
+	rr = patch(mediaTypeMergePatch, `{"physics":{"wind":{"speed":25}},"environment":{"waves":{"height":3}}}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	tuned := decodeSimulation(t, rr.Body.Bytes())
+	assert.Equal(t, 25.0, tuned.Config.Physics.Wind.Speed)
+	assert.Equal(t, 3.0, tuned.Config.Environment.Waves.Height)
+	var warned struct {
+		Warnings []world.Violation `json:"warnings"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &warned))
+	assert.Equal(t, []world.Violation{
+		{Severity: world.SeverityWarning, Path: "/physics/wind/speed", Rule: "stored_only", Message: "stored with the run; no simulation system reads it yet"},
+		{Severity: world.SeverityWarning, Path: "/environment/waves/height", Rule: "stored_only", Message: "stored with the run; no simulation system reads it yet"},
+	}, warned.Warnings, "settings nothing reads are flagged")
+	rr = patch(mediaTypeJSONPatch, `[{"op":"replace","path":"/debug/show_debug_info","value":true}]`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "warnings", "debug settings are for the viewer")
+	tuned = decodeSimulation(t, rr.Body.Bytes())
+	assert.True(t, tuned.Config.Debug.ShowDebugInfo)
+	assert.Equal(t, 25.0, tuned.Config.Physics.Wind.Speed, "earlier patches are kept")
+
+	rr = patch(mediaTypeMergePatch, `{"simulation":{"time_step":0.5},"vessel":{"mass":10},"physics":{"wind":{"speed":1}}}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	p := decodeProblem(t, rr)
+	assert.Equal(t, problemFixedSetting, p.Type)
+	assert.Equal(t, "simulation.time_step, vessel.mass cannot change while a simulation runs; only physics.wind.*, environment.* and debug.* can", p.Detail)
+	assert.Equal(t, []world.Violation{
+		{Severity: world.SeverityError, Path: "/simulation/time_step", Rule: "tunable", Message: "cannot change while a simulation runs"},
+		{Severity: world.SeverityError, Path: "/vessel/mass", Rule: "tunable", Message: "cannot change while a simulation runs"},
+	}, p.Errors)
+
+	rr = patch(mediaTypeMergePatch, `{"physics":{"wind":{"gustiness":2}}}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p = decodeProblem(t, rr)
+	assert.Equal(t, problemInvalidConfig, p.Type)
+	assert.Equal(t, []world.Violation{{Severity: world.SeverityError, Path: "/physics/wind/gustiness", Rule: "config", Message: "must be between 0 and 1, got 2"}}, p.Errors)
+
+	rr = patch(mediaTypeMergePatch, `{"server":{"port":80}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, "server settings are not part of the config")
+	rr = patch("application/json", `{}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, mediaTypeJSONPatch+", "+mediaTypeMergePatch, rr.Header().Get("Accept-Patch"))
+	rr = doRequestWithHeaders(t, h, http.MethodPatch, "/simulations/missing/config", `{}`, map[string]string{"Content-Type": mediaTypeMergePatch})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/simulations/"+created.ID, "")
+	assert.Equal(t, tuned.Config, decodeSimulation(t, rr.Body.Bytes()).Config, "rejected patches change nothing")
+}
+
+func TestReloadConfig(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sim.yaml")
+	profilesDir := filepath.Join(dir, "profiles")
+	require.NoError(t, os.Mkdir(profilesDir, 0o755))
+	write := func(doc, profile string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(file, []byte(doc+"server: {profiles_dir: "+profilesDir+"}\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(profilesDir, "gusty.yaml"), []byte(profile), 0o644))
+	}
+	resolve := func() (config.Config, config.Sources, error) {
+		return config.Resolve(config.Layers{File: file})
+	}
+
+	write("physics:\n  wind: {speed: 5}\n", "physics:\n  wind: {gustiness: 0.5}\n")
+	s := newTestServer()
+	s.reloadConfig(resolve)
+	h := createHandler(s)
+	envID := createEnvironment(t, h, validEnvironment)
+	rr := doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`","profile":"gusty"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	created := decodeSimulation(t, rr.Body.Bytes())
+	assert.Equal(t, 5.0, created.Config.Physics.Wind.Speed)
+	assert.Equal(t, 0.5, created.Config.Physics.Wind.Gustiness)
+	rr = doRequestWithHeaders(t, h, http.MethodPatch, "/simulations/"+created.ID+"/config", `{"environment":{"waves":{"height":3}}}`,
+		map[string]string{"Content-Type": mediaTypeMergePatch})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	write("simulation: {time_step: 0.5}\nphysics:\n  wind: {speed: 9}\nenvironment:\n  waves: {height: 1}\n", "physics:\n  wind: {gustiness: 0.6}\n")
+	s.reloadConfig(resolve)
+	rr = doRequest(t, h, http.MethodGet, "/simulations/"+created.ID, "")
+	reloaded := decodeSimulation(t, rr.Body.Bytes())
+	assert.Equal(t, 9.0, reloaded.Config.Physics.Wind.Speed, "tunable settings of the file apply to running simulations")
+	assert.Equal(t, 0.6, reloaded.Config.Physics.Wind.Gustiness, "and of the profile")
+	assert.Equal(t, 3.0, reloaded.Config.Environment.Waves.Height, "patches win over the file")
+	assert.Equal(t, created.Config.Simulation.TimeStep, reloaded.Config.Simulation.TimeStep, "structural settings wait for a restart")
+
+	rr = doRequest(t, h, http.MethodPost, "/simulations", `{"environment_id":"`+envID+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, 0.5, decodeSimulation(t, rr.Body.Bytes()).Config.Simulation.TimeStep, "new simulations start from the reloaded file")
+
+	write("simulation: {time_step: -1}\n", "")
+	s.reloadConfig(resolve)
+	assert.Equal(t, 0.5, s.simConfig.Simulation.TimeStep, "an invalid file is ignored")
+	rr = doRequest(t, h, http.MethodGet, "/simulations/"+created.ID, "")
+	assert.Equal(t, reloaded.Config, decodeSimulation(t, rr.Body.Bytes()).Config)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// listTileTypes returns the tile types environments may use, with the
+// properties viewers need to draw them
+func (s *server) listTileTypes(w http.ResponseWriter, r *http.Request) {
+	tiles := s.validator.Tiles
+	if tiles == nil {
+		tiles = world.DefaultTiles
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"default":    tiles.Default().Name,
+		"tile_types": tiles.Types(),
+	})
+}
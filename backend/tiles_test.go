@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/config"
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+func TestListTileTypes(t *testing.T) {
+	h := createHandler(newTestServer())
+
+	rr := doRequest(t, h, http.MethodGet, "/tile-types", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp struct {
+		Default   string           `json:"default"`
+		TileTypes []world.TileType `json:"tile_types"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, world.DefaultTileType, resp.Default)
+	assert.Equal(t, world.DefaultTiles.Types(), resp.TileTypes)
+}
+
+func TestLoadTileTypes(t *testing.T) {
+	tiles, err := loadTileTypes(config.Server{})
+	require.NoError(t, err)
+	_, ok := tiles.Lookup("water")
+	assert.True(t, ok, "the shipped definitions are loaded by default")
+
+	path := filepath.Join(t.TempDir(), "tiles.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("default: lava\ntile_types:\n  lava: {walkable: false}\n"), 0o644))
+	tiles, err = loadTileTypes(config.Server{TileTypesFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, "lava", tiles.Default().Name)
+
+	_, err = loadTileTypes(config.Server{TileTypesFile: filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.Error(t, err, "an explicit file must exist")
+}
+
+func TestSaveEnvironmentCustomTileTypes(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	tiles, err := world.ParseTileRegistry([]byte("default: lava\ntile_types:\n  lava: {}\n"))
+	require.NoError(t, err)
+	s := newTestServer()
+	s.validator = world.Validator{Tiles: tiles}
+	h := createHandler(s)
+
+	rr := doRequest(t, h, http.MethodPost, "/environments",
+		`{"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"lava"}]},"objects":[],"agents":[]}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/environments",
+		`{"map":{"width":2,"height":2,"tiles":[{"x":0,"y":0,"type":"grass"}]},"objects":[],"agents":[]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), `unknown tile type \"grass\"`)
+}
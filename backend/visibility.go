@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/solo-seven/drifter.solo7.media/internal/world"
+)
+
+// sightMargin is how far outside the map, in tiles, the ends of a
+// line-of-sight query may lie.
+const sightMargin = 10
+
+// lineOfSight reports whether the line between the from and to query
+// parameters, each "x,y" or "x,y,z", is clear on an environment's map, and
+// what blocks it if not. Both points must lie on the map or within
+// sightMargin tiles of it. It exists for debugging sensor behaviour.
+func (s *server) lineOfSight(w http.ResponseWriter, r *http.Request) {
+	from, ok := pointParam(w, r, "from")
+	if !ok {
+		return
+	}
+	to, ok := pointParam(w, r, "to")
+	if !ok {
+		return
+	}
+	wld, ok := s.loadWorld(w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if !nearMap(wld, from) || !nearMap(wld, to) {
+		writeError(w, r, http.StatusBadRequest,
+			fmt.Sprintf("from and to must lie within %d tiles of the %dx%d map", sightMargin, wld.Width, wld.Height))
+		return
+	}
+
+	resp := map[string]interface{}{"from": from, "to": to, "visible": true}
+	if hit, blocked := wld.Raycast(from, to); blocked {
+		resp["visible"] = false
+		resp["hit"] = hit
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// agentView lists what an agent sees from where it stands in an
+// environment's definition. The range (tiles), fov (radians) and eye_height
+// query parameters describe its sight; by default it sees all around
+// without limit.
+func (s *server) agentView(w http.ResponseWriter, r *http.Request) {
+	var sight world.Sight
+	var ok bool
+	if sight.Range, ok = floatParam(w, r, "range", 0); !ok {
+		return
+	}
+	if sight.FOV, ok = floatParam(w, r, "fov", 0); !ok {
+		return
+	}
+	if sight.EyeHeight, ok = floatParam(w, r, "eye_height", 0); !ok {
+		return
+	}
+	wld, ok := s.loadWorld(w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	agent, found := wld.AgentByID(mux.Vars(r)["agent"])
+	if !found {
+		writeError(w, r, http.StatusNotFound, "agent not found")
+		return
+	}
+
+	agents := []string{}
+	for _, a := range wld.VisibleAgents(agent, sight) {
+		agents = append(agents, a.ID)
+	}
+	objects := []string{}
+	for _, o := range wld.VisibleObjects(agent, sight) {
+		objects = append(objects, o.ID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"agent":    agent.ID,
+		"position": agent.Position,
+		"facing":   agent.Facing,
+		"agents":   agents,
+		"objects":  objects,
+	})
+}
+
+// loadWorld builds the World for an environment. On failure it writes the
+// error response and returns false.
+func (s *server) loadWorld(w http.ResponseWriter, r *http.Request, id string) (*world.World, bool) {
+	env, ok := s.loadEnvironment(w, r, id)
+	if !ok {
+		return nil, false
+	}
+	return s.buildWorld(w, r, env)
+}
+
+// loadEnvironment reads and decodes a stored environment. On failure it
+// writes the error response and returns false.
+func (s *server) loadEnvironment(w http.ResponseWriter, r *http.Request, id string) (*world.EnvironmentSchemaJson, bool) {
+	rec, err := s.environments.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return nil, false
+	}
+	env, err := world.DecodeEnvironment(rec.Data)
+	if err != nil {
+		writeInternalError(w, r, "stored environment is not valid", err)
+		return nil, false
+	}
+	return env, true
+}
+
+// buildWorld builds the World for a decoded environment. On failure it
+// writes the error response and returns false.
+func (s *server) buildWorld(w http.ResponseWriter, r *http.Request, env *world.EnvironmentSchemaJson) (*world.World, bool) {
+	wld, err := world.New(env, s.validator.Tiles, s.validator.Models)
+	if err != nil {
+		// The tile types changed since the environment was saved
+		writeError(w, r, http.StatusConflict, "environment cannot be loaded: "+err.Error())
+		return nil, false
+	}
+	return wld, true
+}
+
+// pointParam reads a required "x,y" or "x,y,z" point from the query string.
+// On failure it writes the error response and returns false.
+func pointParam(w http.ResponseWriter, r *http.Request, name string) (world.Vec3, bool) {
+	parts := strings.Split(r.URL.Query().Get(name), ",")
+	var coords [3]float64
+	ok := len(parts) == 2 || len(parts) == 3
+	for i := 0; ok && i < len(parts); i++ {
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		ok = err == nil && !math.IsNaN(v) && !math.IsInf(v, 0)
+		coords[i] = v
+	}
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, name+` must be a point "x,y" or "x,y,z"`)
+		return world.Vec3{}, false
+	}
+	return world.Vec3{X: coords[0], Y: coords[1], Z: coords[2]}, true
+}
+
+// nearMap reports whether p lies on the map of wld or within sightMargin
+// tiles of it.
+func nearMap(wld *world.World, p world.Vec3) bool {
+	return p.X >= -sightMargin && p.X <= float64(wld.Width+sightMargin) &&
+		p.Y >= -sightMargin && p.Y <= float64(wld.Height+sightMargin)
+}
+
+// floatParam reads an optional non-negative number from the query string.
+// On failure it writes the error response and returns false.
+func floatParam(w http.ResponseWriter, r *http.Request, name string, def float64) (float64, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || !(f >= 0) || math.IsInf(f, 0) {
+		writeError(w, r, http.StatusBadRequest, name+" must be a non-negative number")
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sightEnvironment has a wall at (2,0) between agents a and b and a
+// collidable rock at (3,2)
+const sightEnvironment = `{
+	"map": {"width": 6, "height": 3, "tiles": [{"x": 2, "y": 0, "type": "stone", "height": 2}]},
+	"objects": [{"id": "rock", "model": "rock_large.glb", "position": {"x": 3.5, "y": 2.5}, "properties": {"collision": true}}],
+	"agents": [
+		{"id": "a", "model": "drone_scout.glb", "behavior": "idle", "position": {"x": 0.5, "y": 0.5}, "facing": 0},
+		{"id": "b", "model": "drone_scout.glb", "behavior": "idle", "position": {"x": 5.5, "y": 0.5}},
+		{"id": "c", "model": "drone_scout.glb", "behavior": "idle", "position": {"x": 0.5, "y": 2.5}}
+	]
+}`
+
+func TestLineOfSightEndpoint(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	base := "/environments/" + createEnvironment(t, h, sightEnvironment) + "/line-of-sight"
+
+	rr := doRequest(t, h, http.MethodGet, base+"?from=0.5,0.5&to=5.5,0.5", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{
+		"from": {"x": 0.5, "y": 0.5, "z": 0},
+		"to": {"x": 5.5, "y": 0.5, "z": 0},
+		"visible": false,
+		"hit": {"x": 2, "y": 0, "point": {"x": 2, "y": 0.5, "z": 0}}
+	}`, rr.Body.String())
+
+	rr = doRequest(t, h, http.MethodGet, base+"?from=0.5,2.5&to=5.5,2.5", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"object":"rock"`)
+
+	rr = doRequest(t, h, http.MethodGet, base+"?from=0.5,0.5,3&to=5.5,0.5,3", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"visible":true`)
+
+	for _, query := range []string{"?to=1,1", "?from=1&to=1,1", "?from=1,1,1,1&to=1,1", "?from=a,b&to=1,1", "?from=1,1&to=NaN,1"} {
+		rr = doRequest(t, h, http.MethodGet, base+query, "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+	rr = doRequest(t, h, http.MethodGet, base+"?from=-10,-10&to=16,13", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "points near the map are accepted")
+	for _, query := range []string{"?from=0,0&to=300000000,0", "?from=-10.5,0&to=1,1", "?from=1,1&to=1,13.5"} {
+		rr = doRequest(t, h, http.MethodGet, base+query, "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		assert.Contains(t, rr.Body.String(), "within 10 tiles of the 6x3 map", query)
+	}
+	rr = doRequest(t, h, http.MethodGet, "/environments/missing/line-of-sight?from=1,1&to=2,2", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAgentViewEndpoint(t *testing.T) {
+	t.Setenv("ENV_LOG_FILE", filepath.Join(t.TempDir(), "env.log"))
+	h := createHandler(newTestServer())
+
+	base := "/environments/" + createEnvironment(t, h, sightEnvironment) + "/agents/"
+
+	type view struct {
+		Agent   string   `json:"agent"`
+		Agents  []string `json:"agents"`
+		Objects []string `json:"objects"`
+	}
+	get := func(path string) view {
+		t.Helper()
+		rr := doRequest(t, h, http.MethodGet, base+path, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var v view
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&v))
+		return v
+	}
+
+	assert.Equal(t, view{Agent: "a", Agents: []string{"c"}, Objects: []string{"rock"}}, get("a/view"), "the wall hides b")
+	assert.Equal(t, view{Agent: "a", Agents: []string{}, Objects: []string{"rock"}}, get("a/view?fov=1.5"), "c is 90° to the side")
+	assert.Equal(t, view{Agent: "a", Agents: []string{"b", "c"}, Objects: []string{"rock"}}, get("a/view?eye_height=5"))
+	assert.Equal(t, view{Agent: "a", Agents: []string{}, Objects: []string{}}, get("a/view?range=1.5"))
+
+	rr := doRequest(t, h, http.MethodGet, base+"nobody/view", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, base+"a/view?range=-1", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
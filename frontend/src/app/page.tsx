@@ -9,7 +9,14 @@ const DEFAULT_ENV = `{
     "author": "Solo7",
     "created": "2025-06-21T12:00:00Z",
     "tags": ["test", "prototype"]
-  }
+  },
+  "map": {
+    "width": 10,
+    "height": 10,
+    "tiles": []
+  },
+  "objects": [],
+  "agents": []
 }`;
 
 export default function Home() {